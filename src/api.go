@@ -0,0 +1,298 @@
+package tga
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	kApiShutdownTimeout = 5 * time.Second
+
+	// maximum waiting time of the api requests for the client, which the harvest loop and the watchlist release between their queries
+	kApiClientLockTimeout = 30 * time.Second
+)
+
+// ReserveRequest is the body of the POST /reserve api request.
+type ReserveRequest struct {
+	ItemId string `json:"itemId"`
+	NbBags int    `json:"nbBags"` // 1 if absent
+}
+
+// HealthResponse is the body of the GET /health api response.
+type HealthResponse struct {
+	Status           string    `json:"status"` // "starting" until the stores are listed for the first time, then "ok"
+	NbStores         int       `json:"nbStores"`
+	NbOpenedOrders   int       `json:"nbOpenedOrders"`
+	StoresUpdateTime time.Time `json:"storesUpdateTime"`
+	OrdersUpdateTime time.Time `json:"ordersUpdateTime"`
+
+	Accounts []AccountHealth `json:"accounts"` // as of the end of the last iteration of the harvest loop
+}
+
+type apiErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// StartApi serves the http api on given address until ctx is done.
+func (ant *Ant) StartApi(ctx context.Context, bindAddress string) error {
+	listener, err := net.Listen("tcp", bindAddress)
+	if err != nil {
+		return fmt.Errorf("error from net.Listen: %w", err)
+	}
+	server := &http.Server{
+		Handler:     ant.apiHandler(),
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			glog.Printf("error from server.Serve: %v\n", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), kApiShutdownTimeout)
+		defer cancel()
+		err := server.Shutdown(shutdownCtx)
+		if err != nil {
+			glog.Printf("error from server.Shutdown: %v\n", err)
+		}
+	}()
+
+	glog.Printf("serving api on %v\n", listener.Addr())
+	return nil
+}
+
+func (ant *Ant) apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", ant.handleHealth)
+	mux.HandleFunc("GET /stores", ant.handleListStores)
+	mux.HandleFunc("GET /orders", ant.handleListOrders)
+	mux.HandleFunc("POST /reserve", ant.withApiAuthorization(ant.handleReserve))
+	mux.HandleFunc("POST /orders/{orderId}/cancel", ant.withApiAuthorization(ant.handleCancelOrder))
+	mux.HandleFunc("GET /{$}", ant.withDashboardAuthorization(ant.handleDashboard))
+	mux.HandleFunc("POST /dashboard/reserve", ant.withDashboardAuthorization(ant.handleDashboardReserve))
+	mux.HandleFunc("GET /metrics", ant.handleMetrics)
+	return mux
+}
+
+func writeApiJson(res http.ResponseWriter, statusCode int, value any) {
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(statusCode)
+	err := json.NewEncoder(res).Encode(value)
+	if err != nil {
+		glog.Printf("error from encoder.Encode: %v\n", err)
+	}
+}
+
+func writeApiError(res http.ResponseWriter, err error) {
+	writeApiJson(res, apiErrorStatusCode(err), apiErrorResponse{Error: err.Error()})
+}
+
+// apiErrorStatusCode returns the http status code of the api response for given error.
+func apiErrorStatusCode(err error) int {
+	switch {
+	case errors.Is(err, ErrUsage):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrOrderNotCancellable), errors.Is(err, ErrNotEnoughBags):
+		return http.StatusConflict
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ErrCaptcha), errors.Is(err, ErrForbidden), errors.Is(err, ErrAccountSwitched),
+		errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	}
+	// the too good to go query failed
+	return http.StatusBadGateway
+}
+
+// checkSameOrigin returns an error if given request may have been sent by a web page of another site, which browsers reveal
+// with the Origin header, or through a DNS rebinding attack, detected with a Host which is neither the bound address, localhost nor an ip.
+func (ant *Ant) checkSameOrigin(req *http.Request) error {
+	host := req.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	bindHost := ""
+	if ant.apiConfig != nil {
+		bindHost, _, _ = net.SplitHostPort(ant.apiConfig.BindAddress)
+	}
+	if host != "localhost" && host != bindHost && net.ParseIP(host) == nil {
+		return fmt.Errorf("unexpected host %v", req.Host)
+	}
+
+	origin := req.Header.Get("Origin")
+	if len(origin) == 0 {
+		// not sent by a browser, or by an old one for a same origin request
+		return nil
+	}
+	originUrl, err := url.Parse(origin)
+	if err != nil || originUrl.Host != req.Host {
+		return fmt.Errorf("cross origin request from %v", origin)
+	}
+	return nil
+}
+
+// hasApiToken returns true if no token is configured, or if given request holds it, as a bearer token
+// or as the password of a basic authorization, which browsers prompt for to display the dashboard.
+func (ant *Ant) hasApiToken(req *http.Request) bool {
+	if ant.apiConfig == nil || len(ant.apiConfig.Token) == 0 {
+		return true
+	}
+	token, isBearer := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !isBearer {
+		_, token, _ = req.BasicAuth()
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(ant.apiConfig.Token)) == 1
+}
+
+// withApiAuthorization wraps the handler of an api request using the client, which is refused if it comes from another site,
+// or if it does not hold the configured token.
+func (ant *Ant) withApiAuthorization(handler http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		err := ant.checkSameOrigin(req)
+		if err != nil {
+			writeApiJson(res, http.StatusForbidden, apiErrorResponse{Error: err.Error()})
+			return
+		}
+		if !ant.hasApiToken(req) {
+			writeApiJson(res, http.StatusUnauthorized, apiErrorResponse{Error: "missing or invalid api token"})
+			return
+		}
+		handler(res, req)
+	}
+}
+
+func (ant *Ant) handleHealth(res http.ResponseWriter, req *http.Request) {
+	ant.stateMutex.RLock()
+	defer ant.stateMutex.RUnlock()
+
+	health := HealthResponse{
+		Status:           "ok",
+		NbStores:         len(ant.stores),
+		NbOpenedOrders:   len(ant.openedOrders),
+		StoresUpdateTime: ant.storesUpdateTime,
+		OrdersUpdateTime: ant.ordersUpdateTime,
+		Accounts:         ant.accountsHealth,
+	}
+	if ant.storesUpdateTime.IsZero() {
+		health.Status = "starting"
+	}
+	writeApiJson(res, http.StatusOK, health)
+}
+
+func (ant *Ant) handleListStores(res http.ResponseWriter, req *http.Request) {
+	ant.stateMutex.RLock()
+	defer ant.stateMutex.RUnlock()
+
+	stores := ant.stores
+	if stores == nil {
+		stores = []Store{}
+	}
+	writeApiJson(res, http.StatusOK, stores)
+}
+
+func (ant *Ant) handleListOrders(res http.ResponseWriter, req *http.Request) {
+	ant.stateMutex.RLock()
+	defer ant.stateMutex.RUnlock()
+
+	orders := ant.openedOrders
+	if orders == nil {
+		orders = []Order{}
+	}
+	writeApiJson(res, http.StatusOK, orders)
+}
+
+func (ant *Ant) handleReserve(res http.ResponseWriter, req *http.Request) {
+	// html forms cannot send json, so that another site cannot reserve through the browser of the user
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeApiJson(res, http.StatusUnsupportedMediaType, apiErrorResponse{Error: "expected a json body"})
+		return
+	}
+
+	reserveRequest := ReserveRequest{NbBags: 1}
+	err = json.NewDecoder(req.Body).Decode(&reserveRequest)
+	if err != nil {
+		writeApiError(res, fmt.Errorf("%w: error from decoder.Decode: %w", ErrUsage, err))
+		return
+	}
+	if len(reserveRequest.ItemId) == 0 || reserveRequest.NbBags < 1 {
+		writeApiError(res, fmt.Errorf("%w: expected an item id and a positive number of bags", ErrUsage))
+		return
+	}
+
+	reservedOrder, err := ant.reserve(req.Context(), reserveRequest.ItemId, reserveRequest.NbBags)
+	if err != nil {
+		writeApiError(res, err)
+		return
+	}
+	writeApiJson(res, http.StatusCreated, reservedOrder)
+}
+
+// lockClientForRequest waits for the harvest loop and the watchlist to release the client, at most kApiClientLockTimeout.
+// The returned error wraps the error of the context, mapped to 503 by apiErrorStatusCode.
+func (ant *Ant) lockClientForRequest(ctx context.Context) error {
+	lockCtx, cancel := context.WithTimeout(ctx, kApiClientLockTimeout)
+	defer cancel()
+	err := ant.lockClient(lockCtx)
+	if err != nil {
+		return fmt.Errorf("the ant is busy, retry later: %w", err)
+	}
+	return nil
+}
+
+// reserve reserves bags of given item on request of the user, between two queries of the harvest loop.
+func (ant *Ant) reserve(ctx context.Context, itemId string, nbBags int) (ReservedOrder, error) {
+	err := ant.lockClientForRequest(ctx)
+	if err != nil {
+		return ReservedOrder{}, err
+	}
+	defer ant.unlockClient()
+
+	store, err := ant.client.GetItem(ctx, itemId)
+	if err != nil {
+		return ReservedOrder{}, fmt.Errorf("error from client.GetItem: %w", err)
+	}
+	reservedOrder, err := ant.client.ReserveOrder(ctx, store, nbBags)
+	if err != nil {
+		return reservedOrder, fmt.Errorf("error from client.ReserveOrder: %w", err)
+	}
+	// unpaid, it is cancelled after the grace period like the automatic reservations
+	ant.trackReservation(AutoReservation{Store: store, ReservedOrder: reservedOrder, Email: ant.client.emailAccount()})
+	return reservedOrder, nil
+}
+
+func (ant *Ant) handleCancelOrder(res http.ResponseWriter, req *http.Request) {
+	orderId := req.PathValue("orderId")
+
+	ctx := req.Context()
+	err := ant.lockClientForRequest(ctx)
+	if err != nil {
+		writeApiError(res, err)
+		return
+	}
+	defer ant.unlockClient()
+
+	order, err := ant.client.CancelOpenedOrder(ctx, orderId)
+	if err != nil {
+		writeApiError(res, fmt.Errorf("error from client.CancelOpenedOrder: %w", err))
+		return
+	}
+	if ant.reservationTracker != nil {
+		_, err = ant.reservationTracker.Remove(orderId)
+		if err != nil {
+			glog.Printf("error from reservationTracker.Remove: %v\n", err)
+		}
+	}
+
+	writeApiJson(res, http.StatusOK, order)
+}
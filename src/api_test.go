@@ -0,0 +1,275 @@
+package tga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func apiRequest(t *testing.T, server *httptest.Server, method, path, body string, response any) int {
+	return apiRequestWithHeaders(t, server, method, path, body, map[string]string{"Content-Type": "application/json"}, response)
+}
+
+// apiRequestWithHeaders sends an api request with given headers, "Host" setting the host of the request.
+func apiRequestWithHeaders(t *testing.T, server *httptest.Server, method, path, body string, headers map[string]string, response any) int {
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("error from http.NewRequest: %v", err)
+	}
+	for name, value := range headers {
+		if name == "Host" {
+			req.Host = value
+		} else {
+			req.Header.Set(name, value)
+		}
+	}
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("error from client.Do: %v", err)
+	}
+	defer res.Body.Close()
+	if response != nil {
+		err = json.NewDecoder(res.Body).Decode(response)
+		if err != nil {
+			t.Fatalf("error from decoder.Decode for %v %v: %v", method, path, err)
+		}
+	}
+	return res.StatusCode
+}
+
+func TestApiStateRequests(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2), NewFakeItem("2", "Sushi", 1))
+	now := time.Now()
+	server.AddOrder(NewFakeOrder("order-1", "Pizza", now.Add(2*time.Hour), now.Add(time.Hour)))
+
+	ant := &Ant{
+		client:       newTestClient(server),
+		sender:       &cancelAfterWriter{nbMaxMessages: 10, cancel: func() {}},
+		storeTracker: NewStoreTracker(nil),
+	}
+	apiServer := httptest.NewServer(ant.apiHandler())
+	defer apiServer.Close()
+
+	var health HealthResponse
+	if statusCode := apiRequest(t, apiServer, "GET", "/health", "", &health); statusCode != http.StatusOK || health.Status != "starting" {
+		t.Fatalf("expected starting health, got %v %v", statusCode, health)
+	}
+	var stores []Store
+	if statusCode := apiRequest(t, apiServer, "GET", "/stores", "", &stores); statusCode != http.StatusOK || len(stores) != 0 {
+		t.Fatalf("expected no store before first listing, got %v %v", statusCode, stores)
+	}
+
+	ant.harvestOnce(context.Background())
+	nbRequests := len(server.RequestedPaths())
+
+	apiRequest(t, apiServer, "GET", "/health", "", &health)
+	if health.Status != "ok" || health.NbStores != 2 || health.NbOpenedOrders != 1 {
+		t.Fatalf("unexpected health %v", health)
+	}
+	apiRequest(t, apiServer, "GET", "/stores", "", &stores)
+	if expectedIds := []string{"1", "2"}; !reflect.DeepEqual(storeIds(stores), expectedIds) {
+		t.Fatalf("expected stores %v, got %v", expectedIds, storeIds(stores))
+	}
+	var orders []Order
+	apiRequest(t, apiServer, "GET", "/orders", "", &orders)
+	if len(orders) != 1 || orders[0].Id != "order-1" || orders[0].StoreName != "Pizza" {
+		t.Fatalf("unexpected orders %v", orders)
+	}
+	if nbNewRequests := len(server.RequestedPaths()) - nbRequests; nbNewRequests != 0 {
+		t.Fatalf("expected state requests to be served without query, got %v queries", nbNewRequests)
+	}
+}
+
+func TestApiReserveAndCancel(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+	now := time.Now()
+	server.AddOrder(NewFakeOrder("order-old", "Pizza", now.Add(time.Hour), now.Add(-time.Minute)))
+
+	ant := &Ant{
+		client:             newTestClient(server),
+		sender:             &cancelAfterWriter{nbMaxMessages: 100, cancel: func() {}},
+		storeTracker:       NewStoreTracker(nil),
+		reservationTracker: &ReservationTracker{},
+	}
+	apiServer := httptest.NewServer(ant.apiHandler())
+	defer apiServer.Close()
+
+	// requests are served between the iterations of the running harvest loop
+	ctx, cancel := context.WithCancel(context.Background())
+	harvestDone := make(chan struct{})
+	go func() {
+		ant.harvest(ctx)
+		close(harvestDone)
+	}()
+	defer func() {
+		cancel()
+		<-harvestDone
+	}()
+
+	var reservedOrder ReservedOrder
+	statusCode := apiRequest(t, apiServer, "POST", "/reserve", `{"itemId": "1", "nbBags": 2}`, &reservedOrder)
+	if statusCode != http.StatusCreated || reservedOrder.StoreId != "1" || reservedOrder.Quantity != 2 {
+		t.Fatalf("unexpected reservation %v %v", statusCode, reservedOrder)
+	}
+
+	var errorResponse apiErrorResponse
+	statusCode = apiRequest(t, apiServer, "POST", "/reserve", `{"itemId": "1"}`, &errorResponse)
+	if statusCode != http.StatusConflict || !strings.Contains(errorResponse.Error, "not enough available bags") {
+		t.Fatalf("expected conflict for sold out item, got %v %v", statusCode, errorResponse)
+	}
+	statusCode = apiRequest(t, apiServer, "POST", "/reserve", `{"nbBags": 1}`, &errorResponse)
+	if statusCode != http.StatusBadRequest {
+		t.Fatalf("expected bad request without item id, got %v %v", statusCode, errorResponse)
+	}
+
+	statusCode = apiRequest(t, apiServer, "POST", "/orders/order-old/cancel", "", &errorResponse)
+	if statusCode != http.StatusConflict {
+		t.Fatalf("expected conflict for order past its cancellation deadline, got %v %v", statusCode, errorResponse)
+	}
+	var cancelledOrder Order
+	statusCode = apiRequest(t, apiServer, "POST", "/orders/"+reservedOrder.Id+"/cancel", "", &cancelledOrder)
+	if statusCode != http.StatusConflict {
+		// reservations of the fake server are not listed in the opened orders
+		t.Fatalf("expected conflict for unknown opened order, got %v %v", statusCode, cancelledOrder)
+	}
+
+	server.AddOrder(NewFakeOrder(reservedOrder.Id, "Bakery", now.Add(2*time.Hour), now.Add(time.Hour)))
+	statusCode = apiRequest(t, apiServer, "POST", "/orders/"+reservedOrder.Id+"/cancel", "", &cancelledOrder)
+	if statusCode != http.StatusOK || cancelledOrder.Id != reservedOrder.Id {
+		t.Fatalf("unexpected cancelled order %v %v", statusCode, cancelledOrder)
+	}
+	if reservations := ant.reservationTracker.Reservations(); len(reservations) != 0 {
+		t.Fatalf("expected cancelled reservation to be untracked, got %v", reservations)
+	}
+}
+
+func TestApiRejectsForeignRequests(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+
+	ant := &Ant{
+		client:             newTestClient(server),
+		sender:             &cancelAfterWriter{nbMaxMessages: 10, cancel: func() {}},
+		storeTracker:       NewStoreTracker(nil),
+		reservationTracker: &ReservationTracker{},
+		apiConfig:          &ApiConfig{BindAddress: "127.0.0.1:8080", Token: "secret"},
+	}
+	apiServer := httptest.NewServer(ant.apiHandler())
+	defer apiServer.Close()
+
+	body := `{"itemId": "1"}`
+	for _, testCase := range []struct {
+		headers            map[string]string
+		expectedStatusCode int
+	}{
+		{map[string]string{"Content-Type": "text/plain", "Authorization": "Bearer secret"}, http.StatusUnsupportedMediaType},
+		{map[string]string{"Content-Type": "application/json", "Authorization": "Bearer secret", "Origin": "https://evil.example"}, http.StatusForbidden},
+		{map[string]string{"Content-Type": "application/json", "Authorization": "Bearer secret", "Host": "evil.example:8080"}, http.StatusForbidden},
+		{map[string]string{"Content-Type": "application/json"}, http.StatusUnauthorized},
+		{map[string]string{"Content-Type": "application/json", "Authorization": "Bearer wrong"}, http.StatusUnauthorized},
+	} {
+		var errorResponse apiErrorResponse
+		statusCode := apiRequestWithHeaders(t, apiServer, "POST", "/reserve", body, testCase.headers, &errorResponse)
+		if statusCode != testCase.expectedStatusCode {
+			t.Fatalf("expected status %v for headers %v, got %v %v", testCase.expectedStatusCode, testCase.headers, statusCode, errorResponse)
+		}
+	}
+	if nbRequests := server.NbRequests(kApiCreateOrder + "/1"); nbRequests != 0 {
+		t.Fatalf("expected no reservation, got %v", nbRequests)
+	}
+
+	var reservedOrder ReservedOrder
+	headers := map[string]string{"Content-Type": "application/json; charset=utf-8", "Authorization": "Bearer secret", "Origin": apiServer.URL}
+	statusCode := apiRequestWithHeaders(t, apiServer, "POST", "/reserve", body, headers, &reservedOrder)
+	if statusCode != http.StatusCreated || reservedOrder.StoreId != "1" {
+		t.Fatalf("unexpected reservation %v %v", statusCode, reservedOrder)
+	}
+
+	// state requests stay readable without token
+	var health HealthResponse
+	if statusCode := apiRequest(t, apiServer, "GET", "/health", "", &health); statusCode != http.StatusOK {
+		t.Fatalf("expected health without token, got %v", statusCode)
+	}
+}
+
+func TestApiRequestGivesUpWhenClientIsBusy(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+
+	ant := &Ant{
+		client:       newTestClient(server),
+		sender:       &cancelAfterWriter{nbMaxMessages: 10, cancel: func() {}},
+		storeTracker: NewStoreTracker(nil),
+	}
+
+	// the harvest loop holds the client, for instance while waiting for a slow response
+	err := ant.lockClient(context.Background())
+	if err != nil {
+		t.Fatalf("error from lockClient: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = ant.reserve(ctx, "1", 1)
+	if !errors.Is(err, context.DeadlineExceeded) || apiErrorStatusCode(err) != http.StatusServiceUnavailable {
+		t.Fatalf("expected the request to give up with status 503, got %v", err)
+	}
+	if nbRequests := server.NbRequests(kApiCreateOrder + "/1"); nbRequests != 0 {
+		t.Fatalf("expected no reservation, got %v", nbRequests)
+	}
+
+	ant.unlockClient()
+	_, err = ant.reserve(context.Background(), "1", 1)
+	if err != nil {
+		t.Fatalf("error from reserve once the client is released: %v", err)
+	}
+}
+
+func TestApiRequestWhileHarvestPauses(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+	server.EnqueueStatus(kApiItemEndpoint, http.StatusTooManyRequests)
+
+	client := newTestClient(server)
+	// a single account, which backs off without switching
+	client.Config.Accounts = client.Config.Accounts[:1]
+	client.Config.RetryConfig = RetryConfig{InitialBackoff: Duration{Duration: time.Hour}, MaxBackoff: Duration{Duration: time.Hour}}
+	ant := &Ant{
+		client:       client,
+		sender:       &cancelAfterWriter{nbMaxMessages: 10, cancel: func() {}},
+		storeTracker: NewStoreTracker(nil),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	harvestDone := make(chan struct{})
+	go func() {
+		ant.harvest(ctx)
+		close(harvestDone)
+	}()
+	defer func() {
+		cancel()
+		<-harvestDone
+	}()
+	// the harvest loop holds the client until its search is rate limited, then backs off for an hour
+	for server.NbRequests(kApiItemEndpoint) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	requestCtx, requestCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer requestCancel()
+	reservedOrder, err := ant.reserve(requestCtx, "1", 1)
+	if err != nil {
+		t.Fatalf("error from reserve while the harvest loop backs off: %v", err)
+	}
+	if reservedOrder.StoreId != "1" || reservedOrder.Quantity != 1 {
+		t.Fatalf("expected one bag reserved in store 1, got %v", reservedOrder)
+	}
+}
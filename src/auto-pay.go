@@ -0,0 +1,249 @@
+package tga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+var (
+	ErrSpendingLimit     = errors.New("spending limit reached")
+	ErrOrderNotCancelled = errors.New("order not cancelled")
+	ErrPaymentUncertain  = errors.New("payment outcome unknown")
+)
+
+const (
+	kDefaultPaymentPollingPeriod = 5 * time.Second
+	kDefaultPaymentTimeout       = 2 * time.Minute
+
+	kSpendingFileName = "spending.json"
+)
+
+func (paymentConfig *PaymentConfig) paymentProvider() PaymentProvider {
+	if paymentConfig.PaymentProvider == nil {
+		return Adyen
+	}
+	return *paymentConfig.PaymentProvider
+}
+
+func (paymentConfig *PaymentConfig) paymentPollingPeriod() time.Duration {
+	if paymentConfig.PaymentPollingPeriod.Duration <= 0 {
+		return kDefaultPaymentPollingPeriod
+	}
+	return paymentConfig.PaymentPollingPeriod.Duration
+}
+
+func (paymentConfig *PaymentConfig) paymentTimeout() time.Duration {
+	if paymentConfig.PaymentTimeout.Duration <= 0 {
+		return kDefaultPaymentTimeout
+	}
+	return paymentConfig.PaymentTimeout.Duration
+}
+
+// SpendingRecord is an automatic payment, recorded before requesting it and removed only if it failed for sure,
+// so that payments of uncertain outcome count in the limits.
+type SpendingRecord struct {
+	OrderId string    `json:"orderId"`
+	Amount  float64   `json:"amount"`
+	Time    time.Time `json:"time"`
+}
+
+// SpendingTracker keeps the history of the automatic payments in a file, to enforce the spending limits across restarts.
+type SpendingTracker struct {
+	filePath string
+	records  []SpendingRecord
+}
+
+// LoadSpendingTracker creates a tracker persisted in given file, loading its records from it if it exists.
+func LoadSpendingTracker(filePath string) (*SpendingTracker, error) {
+	spendingTracker := &SpendingTracker{filePath: filePath}
+
+	fileData, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return spendingTracker, nil
+	}
+	if err != nil {
+		return spendingTracker, fmt.Errorf("error from os.ReadFile: %w", err)
+	}
+
+	err = json.Unmarshal(fileData, &spendingTracker.records)
+	if err != nil {
+		return spendingTracker, fmt.Errorf("error from json.Unmarshal: %w", err)
+	}
+	return spendingTracker, nil
+}
+
+// Add records a new payment and saves the records.
+func (spendingTracker *SpendingTracker) Add(record SpendingRecord) error {
+	spendingTracker.records = append(spendingTracker.records, record)
+	return spendingTracker.save()
+}
+
+// Remove forgets the payment of given order, if any, and saves the records.
+func (spendingTracker *SpendingTracker) Remove(orderId string) error {
+	spendingTracker.records = slices.DeleteFunc(spendingTracker.records, func(record SpendingRecord) bool {
+		return record.OrderId == orderId
+	})
+	return spendingTracker.save()
+}
+
+func (spendingTracker *SpendingTracker) save() error {
+	if len(spendingTracker.filePath) == 0 {
+		return nil
+	}
+	fileData, err := json.MarshalIndent(spendingTracker.records, "", " ")
+	if err != nil {
+		return fmt.Errorf("error from json.MarshalIndent: %w", err)
+	}
+	err = writeFileAtomically(spendingTracker.filePath, fileData)
+	if err != nil {
+		return fmt.Errorf("error from writeFileAtomically: %w", err)
+	}
+	return nil
+}
+
+// spentSince returns the amount spent and the number of payments since given time.
+func (spendingTracker *SpendingTracker) spentSince(since time.Time) (float64, int) {
+	amount := 0.0
+	nbPayments := 0
+	for _, record := range spendingTracker.records {
+		if !record.Time.Before(since) {
+			amount += record.Amount
+			nbPayments++
+		}
+	}
+	return amount, nbPayments
+}
+
+// CheckLimits returns an error wrapping ErrSpendingLimit if paying given amount now would exceed a limit.
+// Days and weeks (starting on monday) are counted in the local time zone.
+func (spendingTracker *SpendingTracker) CheckLimits(paymentConfig *PaymentConfig, amount float64, now time.Time) error {
+	year, month, day := now.Date()
+	dayStart := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	weekStart := dayStart.AddDate(0, 0, -(int(now.Weekday())+6)%7)
+
+	daySpending, dayNbPayments := spendingTracker.spentSince(dayStart)
+	weekSpending, _ := spendingTracker.spentSince(weekStart)
+
+	if paymentConfig.MaxNbOrdersPerDay > 0 && dayNbPayments+1 > paymentConfig.MaxNbOrdersPerDay {
+		return fmt.Errorf("%w: already %v paid order(s) today", ErrSpendingLimit, dayNbPayments)
+	}
+	if paymentConfig.MaxDailySpending > 0 && daySpending+amount > paymentConfig.MaxDailySpending {
+		return fmt.Errorf("%w: %.2f already spent today, cannot spend %.2f more", ErrSpendingLimit, daySpending, amount)
+	}
+	if paymentConfig.MaxWeeklySpending > 0 && weekSpending+amount > paymentConfig.MaxWeeklySpending {
+		return fmt.Errorf("%w: %.2f already spent this week, cannot spend %.2f more", ErrSpendingLimit, weekSpending, amount)
+	}
+	return nil
+}
+
+// AutoPayer pays the orders reserved automatically, cancelling them if the payment is not possible.
+type AutoPayer struct {
+	config          *PaymentConfig
+	spendingTracker *SpendingTracker
+}
+
+func NewAutoPayer(config *PaymentConfig, spendingTracker *SpendingTracker) *AutoPayer {
+	return &AutoPayer{
+		config:          config,
+		spendingTracker: spendingTracker,
+	}
+}
+
+// SelectPaymentMethod returns the configured payment method, or the preferred one if none is configured.
+func SelectPaymentMethod(ctx context.Context, client *TooGooToGoClient, paymentConfig *PaymentConfig) (PaymentMethod, error) {
+	paymentMethods, err := client.PaymentMethods(ctx, paymentConfig.paymentProvider())
+	if err != nil {
+		return PaymentMethod{}, fmt.Errorf("error from client.PaymentMethods: %w", err)
+	}
+	for _, paymentMethod := range paymentMethods {
+		if len(paymentConfig.PaymentMethod) == 0 {
+			if paymentMethod.IsPreferred {
+				return paymentMethod, nil
+			}
+		} else if paymentMethod.Id == paymentConfig.PaymentMethod || paymentMethod.DisplayValue == paymentConfig.PaymentMethod {
+			return paymentMethod, nil
+		}
+	}
+	if len(paymentConfig.PaymentMethod) == 0 {
+		return PaymentMethod{}, fmt.Errorf("no preferred payment method among %v", len(paymentMethods))
+	}
+	return PaymentMethod{}, fmt.Errorf("payment method %v not found among %v", paymentConfig.PaymentMethod, len(paymentMethods))
+}
+
+// Pay pays given reservation if the spending limits allow it, and waits for the payment completion.
+// The reserved order is cancelled if it is not paid for sure (payment not attempted, or failed), the returned error
+// wraps ErrOrderNotCancelled if the cancellation failed.
+// Otherwise (payment timeout, error after the payment request), the payment may still be captured: the order is kept
+// and the returned error wraps ErrPaymentUncertain, for the user to check.
+// The order is neither paid nor cancelled if the client does not use the account of the reservation anymore.
+func (autoPayer *AutoPayer) Pay(ctx context.Context, client *TooGooToGoClient, reservation AutoReservation) (OrderPayment, error) {
+	err := client.checkSameAccount(reservation.Email, "paying order "+reservation.ReservedOrder.Id)
+	if err != nil {
+		return OrderPayment{}, fmt.Errorf("%w: %w", ErrOrderNotCancelled, err)
+	}
+	orderPayment, err := autoPayer.pay(ctx, client, reservation)
+	if err != nil && !errors.Is(err, ErrPaymentUncertain) {
+		glog.Printf("cancelling order %v after payment error: %v\n", reservation.ReservedOrder.Id, err)
+		cancelErr := client.CancelOrder(ctx, reservation.ReservedOrder.Id)
+		if cancelErr != nil {
+			return orderPayment, errors.Join(err, fmt.Errorf("%w, error from client.CancelOrder: %w", ErrOrderNotCancelled, cancelErr))
+		}
+	}
+	return orderPayment, err
+}
+
+func (autoPayer *AutoPayer) pay(ctx context.Context, client *TooGooToGoClient, reservation AutoReservation) (OrderPayment, error) {
+	amount := reservation.Store.Price.FloatAmount() * float64(reservation.ReservedOrder.Quantity)
+
+	err := autoPayer.spendingTracker.CheckLimits(autoPayer.config, amount, time.Now())
+	if err != nil {
+		return OrderPayment{}, err
+	}
+
+	paymentMethod, err := SelectPaymentMethod(ctx, client, autoPayer.config)
+	if err != nil {
+		return OrderPayment{}, fmt.Errorf("error from SelectPaymentMethod: %w", err)
+	}
+
+	// recorded before paying, as the payment may be captured even if its outcome is unknown
+	orderId := reservation.ReservedOrder.Id
+	err = autoPayer.spendingTracker.Add(SpendingRecord{
+		OrderId: orderId,
+		Amount:  amount,
+		Time:    time.Now(),
+	})
+	if err != nil {
+		autoPayer.removeSpending(orderId)
+		return OrderPayment{}, fmt.Errorf("error from spendingTracker.Add, cannot enforce spending limits: %w", err)
+	}
+
+	// from now on, the payment request may have been received even if an error is returned
+	orderPayment, err := client.PayOrder(ctx, orderId, paymentMethod)
+	if err != nil {
+		return orderPayment, fmt.Errorf("%w, error from client.PayOrder: %w", ErrPaymentUncertain, err)
+	}
+
+	paymentStatus, err := client.WaitForPayment(ctx, orderPayment.Id, autoPayer.config.paymentTimeout())
+	orderPayment.State = paymentStatus.State
+	if errors.Is(err, ErrPaymentFailed) {
+		autoPayer.removeSpending(orderId)
+		return orderPayment, fmt.Errorf("error from client.WaitForPayment: %w", err)
+	}
+	if err != nil {
+		return orderPayment, fmt.Errorf("%w, error from client.WaitForPayment: %w", ErrPaymentUncertain, err)
+	}
+
+	return orderPayment, nil
+}
+
+func (autoPayer *AutoPayer) removeSpending(orderId string) {
+	err := autoPayer.spendingTracker.Remove(orderId)
+	if err != nil {
+		glog.Printf("error from spendingTracker.Remove: %v\n", err)
+	}
+}
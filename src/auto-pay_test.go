@@ -0,0 +1,193 @@
+package tga
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func reserveForPaymentTest(t *testing.T, server *FakeTooGoodToGoServer, client *TooGooToGoClient) AutoReservation {
+	autoReserver, err := NewAutoReserver([]ReserveRule{{Name: "any", MaxBags: 2}})
+	if err != nil {
+		t.Fatalf("error from NewAutoReserver: %v", err)
+	}
+	stores, err := client.ListStores(context.Background())
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+	reservations, err := autoReserver.Reserve(context.Background(), client, stores)
+	if err != nil || len(reservations) != 1 {
+		t.Fatalf("expected 1 reservation, got %v and error %v", reservations, err)
+	}
+	return reservations[0]
+}
+
+func newTestPaymentConfig() *PaymentConfig {
+	return &PaymentConfig{
+		AutoPay:              true,
+		PaymentPollingPeriod: Duration{Duration: time.Millisecond},
+		PaymentTimeout:       Duration{Duration: time.Second},
+		MaxDailySpending:     10,
+	}
+}
+
+func TestAutoPayerPay(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 3))
+	server.SetPaymentStates("AUTHORIZATION_INITIATED", "AUTHORIZATION_INITIATED", "CAPTURED")
+
+	client := newTestClient(server)
+	filePath := filepath.Join(t.TempDir(), kSpendingFileName)
+	spendingTracker, err := LoadSpendingTracker(filePath)
+	if err != nil {
+		t.Fatalf("error from LoadSpendingTracker: %v", err)
+	}
+	autoPayer := NewAutoPayer(newTestPaymentConfig(), spendingTracker)
+
+	orderPayment, err := autoPayer.Pay(context.Background(), client, reserveForPaymentTest(t, server, client))
+	if err != nil {
+		t.Fatalf("error from Pay: %v", err)
+	}
+	if orderPayment.Id != "payment-order-1" || orderPayment.State != Captured {
+		t.Fatalf("unexpected order payment %v", orderPayment)
+	}
+	if nbPaymentRequests := server.NbRequests(kApiPayment + "/payment-order-1"); nbPaymentRequests != 3 {
+		t.Fatalf("expected 3 payment requests, got %v", nbPaymentRequests)
+	}
+
+	spendingTracker, err = LoadSpendingTracker(filePath)
+	if err != nil {
+		t.Fatalf("error from LoadSpendingTracker: %v", err)
+	}
+	if len(spendingTracker.records) != 1 || spendingTracker.records[0].OrderId != "order-1" || spendingTracker.records[0].Amount != 7.98 {
+		t.Fatalf("unexpected spending records %v", spendingTracker.records)
+	}
+
+	// 7.98 already spent today, a new payment of 3.99 would exceed the daily limit of 10
+	autoPayer = NewAutoPayer(newTestPaymentConfig(), spendingTracker)
+	_, err = autoPayer.Pay(context.Background(), client, reserveForPaymentTest(t, server, client))
+	if !errors.Is(err, ErrSpendingLimit) {
+		t.Fatalf("expected spending limit error, got %v", err)
+	}
+	if expectedIds := []string{"order-2"}; !reflect.DeepEqual(server.AbortedOrderIds(), expectedIds) {
+		t.Fatalf("expected aborted orders %v, got %v", expectedIds, server.AbortedOrderIds())
+	}
+}
+
+func TestAutoPayerCancelsFailedPayment(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 1))
+	server.SetPaymentStates("AUTHORIZATION_INITIATED", "FAILED")
+
+	client := newTestClient(server)
+	spendingTracker := &SpendingTracker{}
+	autoPayer := NewAutoPayer(newTestPaymentConfig(), spendingTracker)
+
+	_, err := autoPayer.Pay(context.Background(), client, reserveForPaymentTest(t, server, client))
+	if err == nil {
+		t.Fatalf("expected a payment error")
+	}
+	if expectedIds := []string{"order-1"}; !reflect.DeepEqual(server.AbortedOrderIds(), expectedIds) {
+		t.Fatalf("expected aborted orders %v, got %v", expectedIds, server.AbortedOrderIds())
+	}
+	if len(spendingTracker.records) != 0 {
+		t.Fatalf("expected no spending record, got %v", spendingTracker.records)
+	}
+}
+
+func TestAutoPayerKeepsOrderOfUncertainPayment(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 6))
+	server.SetPaymentStates("AUTHORIZATION_INITIATED")
+
+	client := newTestClient(server)
+	paymentConfig := newTestPaymentConfig()
+	paymentConfig.PaymentTimeout = Duration{Duration: 10 * time.Millisecond}
+	paymentConfig.MaxDailySpending = 20
+	spendingTracker := &SpendingTracker{}
+	autoPayer := NewAutoPayer(paymentConfig, spendingTracker)
+
+	_, err := autoPayer.Pay(context.Background(), client, reserveForPaymentTest(t, server, client))
+	if !errors.Is(err, ErrPaymentUncertain) || !errors.Is(err, ErrPaymentTimeout) {
+		t.Fatalf("expected uncertain payment after timeout, got %v", err)
+	}
+
+	server.EnqueueStatus("order/v7/order-2/pay", http.StatusBadGateway)
+	_, err = autoPayer.Pay(context.Background(), client, reserveForPaymentTest(t, server, client))
+	if !errors.Is(err, ErrPaymentUncertain) || !errors.Is(err, ErrServer) {
+		t.Fatalf("expected uncertain payment after server error, got %v", err)
+	}
+
+	if len(server.AbortedOrderIds()) != 0 {
+		t.Fatalf("expected no aborted order, got %v", server.AbortedOrderIds())
+	}
+	// the payments may still be captured, they count in the limits
+	if len(spendingTracker.records) != 2 || spendingTracker.records[0].OrderId != "order-1" || spendingTracker.records[1].OrderId != "order-2" {
+		t.Fatalf("expected the uncertain payments to be recorded, got %v", spendingTracker.records)
+	}
+	_, err = autoPayer.Pay(context.Background(), client, reserveForPaymentTest(t, server, client))
+	if !errors.Is(err, ErrSpendingLimit) {
+		t.Fatalf("expected spending limit error, got %v", err)
+	}
+}
+
+func TestAutoPayerUnknownPaymentMethod(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 1))
+
+	client := newTestClient(server)
+	paymentConfig := newTestPaymentConfig()
+	paymentConfig.PaymentMethod = "•••• 9999"
+	autoPayer := NewAutoPayer(paymentConfig, &SpendingTracker{})
+
+	_, err := autoPayer.Pay(context.Background(), client, reserveForPaymentTest(t, server, client))
+	if err == nil {
+		t.Fatalf("expected an error for an unknown payment method")
+	}
+	if server.NbRequests("order/v7/order-1/pay") != 0 {
+		t.Fatalf("expected no payment request")
+	}
+	if expectedIds := []string{"order-1"}; !reflect.DeepEqual(server.AbortedOrderIds(), expectedIds) {
+		t.Fatalf("expected aborted orders %v, got %v", expectedIds, server.AbortedOrderIds())
+	}
+}
+
+func TestSpendingTrackerCheckLimits(t *testing.T) {
+	// Wednesday 24 May 2023
+	now := time.Date(2023, time.May, 24, 12, 0, 0, 0, time.UTC)
+	spendingTracker := &SpendingTracker{
+		records: []SpendingRecord{
+			{OrderId: "1", Amount: 5, Time: time.Date(2023, time.May, 21, 19, 0, 0, 0, time.UTC)}, // previous week
+			{OrderId: "2", Amount: 4, Time: time.Date(2023, time.May, 22, 19, 0, 0, 0, time.UTC)},
+			{OrderId: "3", Amount: 3, Time: time.Date(2023, time.May, 24, 9, 0, 0, 0, time.UTC)},
+		},
+	}
+
+	testCases := []struct {
+		paymentConfig PaymentConfig
+		amount        float64
+		expectedOk    bool
+	}{
+		{PaymentConfig{}, 100, true},
+		{PaymentConfig{MaxDailySpending: 7}, 4, true},
+		{PaymentConfig{MaxDailySpending: 7}, 4.5, false},
+		{PaymentConfig{MaxWeeklySpending: 10}, 3, true},
+		{PaymentConfig{MaxWeeklySpending: 10}, 3.5, false},
+		{PaymentConfig{MaxNbOrdersPerDay: 2}, 1, true},
+		{PaymentConfig{MaxNbOrdersPerDay: 1}, 1, false},
+	}
+
+	for testPos, testCase := range testCases {
+		err := spendingTracker.CheckLimits(&testCase.paymentConfig, testCase.amount, now)
+		if (err == nil) != testCase.expectedOk {
+			t.Fatalf("expected ok %v for test case %v, got error %v", testCase.expectedOk, testPos, err)
+		}
+		if err != nil && !errors.Is(err, ErrSpendingLimit) {
+			t.Fatalf("expected spending limit error for test case %v, got %v", testPos, err)
+		}
+	}
+}
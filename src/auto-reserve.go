@@ -0,0 +1,246 @@
+package tga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	kReservedWindowsFileName = "reservedWindows.json"
+
+	// kept that long when the end of the pickup window of the store is unknown
+	kUnknownReservedWindowRetention = 24 * time.Hour
+)
+
+// AutoReservation is an order reserved automatically by a rule.
+type AutoReservation struct {
+	RuleName      string
+	Store         Store
+	ReservedOrder ReservedOrder
+	Email         string // account owning the order
+}
+
+func (r *AutoReservation) String() string {
+	return fmt.Sprintf("auto reserved by rule '%v': %v\n%v", r.RuleName, r.ReservedOrder.String(), r.Store.String())
+}
+
+type compiledReserveRule struct {
+	ReserveRule
+	storeIds        map[string]bool
+	storeNameRegexp *regexp.Regexp
+	weekdays        map[time.Weekday]bool
+}
+
+// AutoReserver reserves the stores matching the configured rules, at most once per store and pickup window.
+// The reserved windows can be kept in a file, so that a restart does not reserve (and pay) the same stores again.
+type AutoReserver struct {
+	rules           []compiledReserveRule
+	filePath        string
+	reservedWindows map[string]time.Time // reserved window key -> end of the pickup window
+}
+
+// NewAutoReserver validates given rules, returning an error if a pattern or a weekday is invalid.
+func NewAutoReserver(rules []ReserveRule) (*AutoReserver, error) {
+	autoReserver := &AutoReserver{
+		reservedWindows: make(map[string]time.Time),
+	}
+
+	for rulePos, rule := range rules {
+		compiledRule := compiledReserveRule{
+			ReserveRule: rule,
+			storeIds:    make(map[string]bool),
+			weekdays:    make(map[time.Weekday]bool),
+		}
+		if len(compiledRule.Name) == 0 {
+			compiledRule.Name = fmt.Sprintf("#%v", rulePos+1)
+		}
+		for _, storeId := range rule.StoreIds {
+			compiledRule.storeIds[storeId] = true
+		}
+		if len(rule.StoreNamePattern) > 0 {
+			storeNameRegexp, err := regexp.Compile(rule.StoreNamePattern)
+			if err != nil {
+				return nil, fmt.Errorf("error from regexp.Compile for rule %v: %w", compiledRule.Name, err)
+			}
+			compiledRule.storeNameRegexp = storeNameRegexp
+		}
+		for _, weekdayStr := range rule.Weekdays {
+			weekday, err := parseWeekday(weekdayStr)
+			if err != nil {
+				return nil, fmt.Errorf("error from parseWeekday for rule %v: %w", compiledRule.Name, err)
+			}
+			compiledRule.weekdays[weekday] = true
+		}
+		autoReserver.rules = append(autoReserver.rules, compiledRule)
+	}
+
+	return autoReserver, nil
+}
+
+// LoadReservedWindows makes autoReserver persist its reserved pickup windows in given file, loading them from it if it exists.
+func (autoReserver *AutoReserver) LoadReservedWindows(filePath string) error {
+	autoReserver.filePath = filePath
+
+	fileData, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error from os.ReadFile: %w", err)
+	}
+
+	var reservedWindows map[string]time.Time
+	err = json.Unmarshal(fileData, &reservedWindows)
+	if err != nil {
+		return fmt.Errorf("error from json.Unmarshal: %w", err)
+	}
+	for key, windowEnd := range reservedWindows {
+		autoReserver.reservedWindows[key] = windowEnd
+	}
+	return nil
+}
+
+// save writes the reserved windows which have not ended yet to the file, if any.
+func (autoReserver *AutoReserver) save(now time.Time) error {
+	for key, windowEnd := range autoReserver.reservedWindows {
+		if windowEnd.Before(now) {
+			delete(autoReserver.reservedWindows, key)
+		}
+	}
+	if len(autoReserver.filePath) == 0 {
+		return nil
+	}
+	fileData, err := json.MarshalIndent(autoReserver.reservedWindows, "", " ")
+	if err != nil {
+		return fmt.Errorf("error from json.MarshalIndent: %w", err)
+	}
+	err = writeFileAtomically(autoReserver.filePath, fileData)
+	if err != nil {
+		return fmt.Errorf("error from writeFileAtomically: %w", err)
+	}
+	return nil
+}
+
+// parseWeekday accepts full english weekday names or their 3 first letters, case insensitive.
+func parseWeekday(str string) (time.Weekday, error) {
+	lowerStr := strings.ToLower(str)
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		lowerWeekday := strings.ToLower(weekday.String())
+		if lowerStr == lowerWeekday || lowerStr == lowerWeekday[:3] {
+			return weekday, nil
+		}
+	}
+	return time.Sunday, fmt.Errorf("unknown weekday %v", str)
+}
+
+func (rule *compiledReserveRule) matches(store *Store) bool {
+	if len(rule.storeIds) > 0 && !rule.storeIds[store.Id] {
+		return false
+	}
+	if rule.storeNameRegexp != nil && !rule.storeNameRegexp.MatchString(store.Name) && !rule.storeNameRegexp.MatchString(store.DisplayName) {
+		return false
+	}
+	if rule.MaxPrice > 0 && store.Price.FloatAmount() > rule.MaxPrice {
+		return false
+	}
+	if store.Rating < rule.MinRating {
+		return false
+	}
+	if rule.PickupHours == nil && len(rule.weekdays) == 0 {
+		return true
+	}
+
+	pickupFrom := store.PickupDetails.FromGMT
+	pickupTo := store.PickupDetails.ToGMT
+	if pickupFrom.IsZero() || pickupTo.IsZero() {
+		return false
+	}
+	location, err := time.LoadLocation(store.TimeZone)
+	if err != nil || len(store.TimeZone) == 0 {
+		location = time.UTC
+	}
+	pickupFrom = pickupFrom.In(location)
+
+	if len(rule.weekdays) > 0 && !rule.weekdays[pickupFrom.Weekday()] {
+		return false
+	}
+	if rule.PickupHours != nil {
+		year, month, day := pickupFrom.Date()
+		hoursFrom := time.Date(year, month, day, rule.PickupHours.From.Hour, rule.PickupHours.From.Minute, 0, 0, location)
+		hoursTo := time.Date(year, month, day, rule.PickupHours.To.Hour, rule.PickupHours.To.Minute, 0, 0, location)
+		if !pickupFrom.Before(hoursTo) || !hoursFrom.Before(pickupTo) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchingRule returns the first rule matching given store, nil if none.
+func (autoReserver *AutoReserver) MatchingRule(store *Store) *ReserveRule {
+	for rulePos := range autoReserver.rules {
+		if autoReserver.rules[rulePos].matches(store) {
+			return &autoReserver.rules[rulePos].ReserveRule
+		}
+	}
+	return nil
+}
+
+func reservedWindowKey(store *Store) string {
+	return fmt.Sprintf("%v/%v", store.Id, store.PickupDetails.FromGMT.Unix())
+}
+
+// Reserve reserves the available stores matching a rule, skipping the ones already reserved for the same pickup window.
+// Stores which could not be reserved are retried at the next call, their errors are joined in the returned error.
+func (autoReserver *AutoReserver) Reserve(ctx context.Context, client *TooGooToGoClient, stores []Store) ([]AutoReservation, error) {
+	reservations := []AutoReservation{}
+	var errs []error
+
+	for storePos := range stores {
+		store := &stores[storePos]
+		if _, isReserved := autoReserver.reservedWindows[reservedWindowKey(store)]; store.AvailableBags == 0 || isReserved {
+			continue
+		}
+		rule := autoReserver.MatchingRule(store)
+		if rule == nil {
+			continue
+		}
+
+		nbBags := min(max(rule.MaxBags, 1), store.AvailableBags)
+
+		glog.Printf("rule %v matches store %v, reserving %v bag(s)\n", rule.Name, store.Name, nbBags)
+
+		reservedOrder, err := client.ReserveOrder(ctx, *store, nbBags)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error from client.ReserveOrder for store %v: %w", store.Id, err))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		windowEnd := store.PickupDetails.ToGMT
+		if windowEnd.IsZero() {
+			windowEnd = time.Now().Add(kUnknownReservedWindowRetention)
+		}
+		autoReserver.reservedWindows[reservedWindowKey(store)] = windowEnd
+		err = autoReserver.save(time.Now())
+		if err != nil {
+			// the reservation is still returned, the window is only forgotten at restart
+			glog.Printf("error from autoReserver.save: %v\n", err)
+		}
+
+		reservations = append(reservations, AutoReservation{
+			RuleName:      rule.Name,
+			Store:         *store,
+			ReservedOrder: reservedOrder,
+			Email:         client.emailAccount(),
+		})
+	}
+
+	return reservations, errors.Join(errs...)
+}
@@ -0,0 +1,230 @@
+package tga
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newRuleTestStore() Store {
+	return Store{
+		Name:        "Boulangerie Paul",
+		DisplayName: "Boulangerie Paul (Panier Surprise)",
+		Id:          "1",
+		Rating:      4.2,
+		Price: Price{
+			Amount:       399,
+			NbDecimals:   2,
+			CurrencyCode: "EUR",
+		},
+		AvailableBags: 3,
+		PickupDetails: PickupDetails{
+			// Sunday 21 May 2023, 19:30 - 20:00 in Paris
+			FromGMT: time.Date(2023, time.May, 21, 17, 30, 0, 0, time.UTC),
+			ToGMT:   time.Date(2023, time.May, 21, 18, 0, 0, 0, time.UTC),
+		},
+		TimeZone: "Europe/Paris",
+	}
+}
+
+func TestAutoReserverMatchingRule(t *testing.T) {
+	store := newRuleTestStore()
+
+	testCases := []struct {
+		rule          ReserveRule
+		expectedMatch bool
+	}{
+		{ReserveRule{}, true},
+		{ReserveRule{StoreIds: []string{"2", "1"}}, true},
+		{ReserveRule{StoreIds: []string{"2"}}, false},
+		{ReserveRule{StoreNamePattern: "(?i)boulangerie"}, true},
+		{ReserveRule{StoreNamePattern: "Surprise"}, true},
+		{ReserveRule{StoreNamePattern: "^Sushi"}, false},
+		{ReserveRule{MaxPrice: 3.99}, true},
+		{ReserveRule{MaxPrice: 3.5}, false},
+		{ReserveRule{MinRating: 4}, true},
+		{ReserveRule{MinRating: 4.5}, false},
+		{ReserveRule{Weekdays: []string{"saturday", "Sun"}}, true},
+		{ReserveRule{Weekdays: []string{"mon"}}, false},
+		{ReserveRule{PickupHours: &HoursInterval{From: TimeOfDay{19, 45}, To: TimeOfDay{21, 0}}}, true},
+		{ReserveRule{PickupHours: &HoursInterval{From: TimeOfDay{17, 0}, To: TimeOfDay{19, 30}}}, false},
+		{ReserveRule{PickupHours: &HoursInterval{From: TimeOfDay{20, 0}, To: TimeOfDay{21, 0}}}, false},
+	}
+
+	for testPos, testCase := range testCases {
+		autoReserver, err := NewAutoReserver([]ReserveRule{testCase.rule})
+		if err != nil {
+			t.Fatalf("error from NewAutoReserver for test case %v: %v", testPos, err)
+		}
+		rule := autoReserver.MatchingRule(&store)
+		if (rule != nil) != testCase.expectedMatch {
+			t.Fatalf("expected match %v for test case %v, got %v", testCase.expectedMatch, testPos, rule != nil)
+		}
+	}
+
+	store.PickupDetails = PickupDetails{}
+	autoReserver, _ := NewAutoReserver([]ReserveRule{{Weekdays: []string{"sunday"}}})
+	if autoReserver.MatchingRule(&store) != nil {
+		t.Fatalf("expected no match for a store without pickup window")
+	}
+}
+
+func TestAutoReserverInvalidRules(t *testing.T) {
+	_, err := NewAutoReserver([]ReserveRule{{StoreNamePattern: "("}})
+	if err == nil {
+		t.Fatalf("expected an error for an invalid pattern")
+	}
+	_, err = NewAutoReserver([]ReserveRule{{Weekdays: []string{"someday"}}})
+	if err == nil {
+		t.Fatalf("expected an error for an invalid weekday")
+	}
+}
+
+func TestAutoReserverReserve(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(
+		NewFakeItem("1", "Bakery", 3),
+		NewFakeItem("2", "Sushi", 1),
+	)
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	autoReserver, err := NewAutoReserver([]ReserveRule{
+		{Name: "bakery", StoreNamePattern: "Bakery", MaxBags: 2},
+	})
+	if err != nil {
+		t.Fatalf("error from NewAutoReserver: %v", err)
+	}
+
+	stores, err := client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+	reservations, err := autoReserver.Reserve(ctx, client, stores)
+	if err != nil {
+		t.Fatalf("error from Reserve: %v", err)
+	}
+	if len(reservations) != 1 {
+		t.Fatalf("expected 1 reservation, got %v", reservations)
+	}
+	if reservation := reservations[0]; reservation.RuleName != "bakery" || reservation.ReservedOrder.StoreId != "1" || reservation.ReservedOrder.Quantity != 2 {
+		t.Fatalf("unexpected reservation %v", reservation)
+	}
+
+	// same pickup window: not reserved again
+	stores, err = client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+	reservations, err = autoReserver.Reserve(ctx, client, stores)
+	if err != nil || len(reservations) != 0 {
+		t.Fatalf("expected no new reservation, got %v and error %v", reservations, err)
+	}
+	if nbCreateRequests := server.NbRequests(kApiCreateOrder + "/1"); nbCreateRequests != 1 {
+		t.Fatalf("expected 1 create order request, got %v", nbCreateRequests)
+	}
+}
+
+func TestAutoReserverReserveOnAccountSwitch(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(
+		NewFakeItem("1", "Bakery", 1),
+		NewFakeItem("2", "Sushi", 1),
+	)
+	// the second reservation is retried with the next account
+	server.EnqueueCaptcha(kApiCreateOrder + "/2")
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	autoReserver, err := NewAutoReserver([]ReserveRule{{Name: "any"}})
+	if err != nil {
+		t.Fatalf("error from NewAutoReserver: %v", err)
+	}
+	stores, err := client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+	reservations, err := autoReserver.Reserve(ctx, client, stores)
+	if !errors.Is(err, ErrAccountSwitched) {
+		t.Fatalf("expected account switched error, got %v", err)
+	}
+	if len(reservations) != 1 || reservations[0].ReservedOrder.StoreId != "1" || reservations[0].Email != "ant1@email.com" {
+		t.Fatalf("expected the reservation of store 1 by the first account, got %v", reservations)
+	}
+
+	// the order made by the next account is not known, the store is reserved again once available
+	server.SetItemsAvailable("2", 1)
+	stores, err = client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+	reservations, err = autoReserver.Reserve(ctx, client, stores)
+	if err != nil {
+		t.Fatalf("error from Reserve: %v", err)
+	}
+	if len(reservations) != 1 || reservations[0].ReservedOrder.StoreId != "2" || reservations[0].Email != "ant2@email.com" {
+		t.Fatalf("expected the reservation of store 2 by the second account, got %v", reservations)
+	}
+
+	// not paid nor cancelled with another account
+	autoPayer := NewAutoPayer(newTestPaymentConfig(), &SpendingTracker{})
+	reservation := reservations[0]
+	reservation.Email = "ant1@email.com"
+	_, err = autoPayer.Pay(ctx, client, reservation)
+	if !errors.Is(err, ErrOrderNotCancelled) || !errors.Is(err, ErrAccountSwitched) {
+		t.Fatalf("expected the payment to be refused for another account, got %v", err)
+	}
+	if len(server.AbortedOrderIds()) != 0 || server.NbRequests("order/v7/"+reservation.ReservedOrder.Id+"/pay") != 0 {
+		t.Fatalf("expected no payment nor cancellation")
+	}
+}
+
+func TestAutoReserverReservedWindowsAfterRestart(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 3))
+
+	client := newTestClient(server)
+	ctx := context.Background()
+	filePath := filepath.Join(t.TempDir(), kReservedWindowsFileName)
+
+	newLoadedAutoReserver := func() *AutoReserver {
+		autoReserver, err := NewAutoReserver([]ReserveRule{{Name: "any"}})
+		if err != nil {
+			t.Fatalf("error from NewAutoReserver: %v", err)
+		}
+		err = autoReserver.LoadReservedWindows(filePath)
+		if err != nil {
+			t.Fatalf("error from LoadReservedWindows: %v", err)
+		}
+		return autoReserver
+	}
+
+	stores, err := client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+	reservations, err := newLoadedAutoReserver().Reserve(ctx, client, stores)
+	if err != nil || len(reservations) != 1 {
+		t.Fatalf("expected 1 reservation, got %v and error %v", reservations, err)
+	}
+
+	// same pickup window after a restart: not reserved again
+	reservations, err = newLoadedAutoReserver().Reserve(ctx, client, stores)
+	if err != nil || len(reservations) != 0 {
+		t.Fatalf("expected no new reservation after restart, got %v and error %v", reservations, err)
+	}
+
+	// ended windows are forgotten
+	autoReserver := newLoadedAutoReserver()
+	err = autoReserver.save(time.Now().Add(2 * kUnknownReservedWindowRetention))
+	if err != nil {
+		t.Fatalf("error from save: %v", err)
+	}
+	if len(newLoadedAutoReserver().reservedWindows) != 0 {
+		t.Fatalf("expected ended windows to be forgotten")
+	}
+}
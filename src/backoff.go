@@ -0,0 +1,70 @@
+package tga
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	kDefaultMaxRetries        = 3
+	kDefaultInitialBackoff    = 30 * time.Second
+	kDefaultMaxBackoff        = 10 * time.Minute
+	kDefaultBackoffMultiplier = 2.0
+)
+
+func (retryConfig RetryConfig) maxRetries() int {
+	if retryConfig.MaxRetries <= 0 {
+		return kDefaultMaxRetries
+	}
+	return retryConfig.MaxRetries
+}
+
+// backoffDuration returns the exponential backoff to apply before retry number nbRetries (starting at 0),
+// with a random jitter between half and the full exponential duration.
+func (retryConfig RetryConfig) backoffDuration(nbRetries int) time.Duration {
+	initialBackoff := retryConfig.InitialBackoff.Duration
+	if initialBackoff <= 0 {
+		initialBackoff = kDefaultInitialBackoff
+	}
+	maxBackoff := retryConfig.MaxBackoff.Duration
+	if maxBackoff <= 0 {
+		maxBackoff = kDefaultMaxBackoff
+	}
+	multiplier := retryConfig.BackoffMultiplier
+	if multiplier < 1 {
+		multiplier = kDefaultBackoffMultiplier
+	}
+
+	backoff := float64(initialBackoff) * math.Pow(multiplier, float64(nbRetries))
+	if backoff > float64(maxBackoff) {
+		backoff = float64(maxBackoff)
+	}
+
+	halfBackoff := int64(backoff / 2)
+	if halfBackoff <= 0 {
+		return time.Duration(backoff)
+	}
+	return time.Duration(halfBackoff + rand.Int63n(halfBackoff+1))
+}
+
+// parseRetryAfter returns the duration to wait from the Retry-After header (in seconds or as http date),
+// or 0 if it is absent or invalid.
+func parseRetryAfter(header http.Header, nowTime time.Time) time.Duration {
+	retryAfter := header.Get("Retry-After")
+	if len(retryAfter) == 0 {
+		return 0
+	}
+	nbSeconds, err := strconv.Atoi(retryAfter)
+	if err == nil {
+		return max(time.Duration(nbSeconds)*time.Second, 0)
+	}
+	retryTime, err := http.ParseTime(retryAfter)
+	if err != nil {
+		glog.Printf("invalid Retry-After header value '%v'\n", retryAfter)
+		return 0
+	}
+	return max(retryTime.Sub(nowTime), 0)
+}
@@ -0,0 +1,63 @@
+package tga
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBackoffDuration(t *testing.T) {
+	retryConfig := RetryConfig{
+		InitialBackoff:    Duration{Duration: 10 * time.Second},
+		MaxBackoff:        Duration{Duration: time.Minute},
+		BackoffMultiplier: 2,
+	}
+
+	expectedMaxBackoffs := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}
+	for nbRetries, expectedMaxBackoff := range expectedMaxBackoffs {
+		for i := 0; i < 100; i++ {
+			backoff := retryConfig.backoffDuration(nbRetries)
+			if backoff < expectedMaxBackoff/2 || backoff > expectedMaxBackoff {
+				t.Fatalf("expected backoff in [%v, %v] for retry %v, got %v", expectedMaxBackoff/2, expectedMaxBackoff, nbRetries, backoff)
+			}
+		}
+	}
+}
+
+func TestBackoffDefaults(t *testing.T) {
+	retryConfig := RetryConfig{}
+	if retryConfig.maxRetries() != kDefaultMaxRetries {
+		t.Fatalf("expected default max retries %v, got %v", kDefaultMaxRetries, retryConfig.maxRetries())
+	}
+	backoff := retryConfig.backoffDuration(0)
+	if backoff < kDefaultInitialBackoff/2 || backoff > kDefaultInitialBackoff {
+		t.Fatalf("expected default backoff around %v, got %v", kDefaultInitialBackoff, backoff)
+	}
+	backoff = retryConfig.backoffDuration(100)
+	if backoff < kDefaultMaxBackoff/2 || backoff > kDefaultMaxBackoff {
+		t.Fatalf("expected backoff capped to %v, got %v", kDefaultMaxBackoff, backoff)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	nowTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		"-5":                            0,
+		"Fri, 01 Mar 2024 12:30:00 GMT": 30 * time.Minute,
+		"Fri, 01 Mar 2024 11:30:00 GMT": 0,
+		"soon":                          0,
+	}
+
+	for retryAfter, expectedDuration := range testCases {
+		header := http.Header{}
+		if len(retryAfter) > 0 {
+			header.Set("Retry-After", retryAfter)
+		}
+		if duration := parseRetryAfter(header, nowTime); duration != expectedDuration {
+			t.Fatalf("expected %v for Retry-After '%v', got %v", expectedDuration, retryAfter, duration)
+		}
+	}
+}
@@ -0,0 +1,470 @@
+package tga
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+var (
+	ErrUsage = errors.New("invalid usage")
+)
+
+// Exit codes of the commands, for scripting.
+const (
+	kExitSuccess       = 0
+	kExitFailure       = 1
+	kExitUsage         = 2
+	kExitUnauthorized  = 3 // log in needed or rejected
+	kExitBlocked       = 4 // rate limited, captcha, forbidden or account switched while backing off
+	kExitRefused       = 5 // order not cancellable, not enough bags, spending limit
+	kExitPaymentFailed = 6
+	kExitInterrupted   = 130
+)
+
+// ExitCode returns the process exit code corresponding to the error returned by a command.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return kExitSuccess
+	case errors.Is(err, ErrUsage):
+		return kExitUsage
+	case errors.Is(err, context.Canceled):
+		return kExitInterrupted
+	case errors.Is(err, ErrUnauthorized):
+		return kExitUnauthorized
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ErrCaptcha), errors.Is(err, ErrForbidden), errors.Is(err, ErrAccountSwitched):
+		return kExitBlocked
+	case errors.Is(err, ErrOrderNotCancellable), errors.Is(err, ErrNotEnoughBags), errors.Is(err, ErrSpendingLimit):
+		return kExitRefused
+	case errors.Is(err, ErrPaymentFailed), errors.Is(err, ErrPaymentTimeout):
+		return kExitPaymentFailed
+	}
+	return kExitFailure
+}
+
+// commandEnv is what the commands need to run.
+type commandEnv struct {
+	config       *Config
+	out          io.Writer // where the results are printed
+	outputFormat OutputFormat
+	newClient    func(ctx context.Context) *TooGooToGoClient
+}
+
+// withClient calls f with a new client, closed afterwards to save its authorization data.
+func (env *commandEnv) withClient(ctx context.Context, f func(client *TooGooToGoClient) error) error {
+	client := env.newClient(ctx)
+	err := f(client)
+	closeErr := client.Close()
+	if closeErr != nil {
+		glog.Printf("error from client.Close: %v\n", closeErr)
+	}
+	return err
+}
+
+type command struct {
+	name        string
+	arguments   string
+	description string
+	minNbArgs   int
+	maxNbArgs   int
+	run         func(ctx context.Context, env *commandEnv, args []string) error
+}
+
+var kCommands = []command{
+	{"run", "", "harvest stores until interrupted (default command)", 0, 0, runCommand},
+	{"list", "", "list the stores found by the configured searches", 0, 0, listCommand},
+	{"orders", "", "list the opened orders", 0, 0, ordersCommand},
+	{"reserve", "<itemId> [nbBags]", "reserve bags of given item (1 by default)", 1, 2, reserveCommand},
+	{"cancel", "<orderId>", "cancel given opened order if still cancellable", 1, 1, cancelCommand},
+	{"pay", "<orderId>", "pay given reserved order with the configured payment method", 1, 1, payCommand},
+	{"payment-methods", "", "list the saved payment methods", 0, 0, paymentMethodsCommand},
+	{"login", "<email>", "log in given configured account", 1, 1, loginCommand},
+	{"logout", "[email]", "forget the authorization data of given account, or of all accounts", 0, 1, logoutCommand},
+	{"whoami", "", "print the log in status of the configured accounts", 0, 0, whoamiCommand},
+	{"report", "[text|csv|json]", "update the order history of all accounts and print a report", 0, 1, reportCommand},
+	{"favorites", "add <file> | remove <file> | sync", "update the favorites of the first account, or copy them to the other accounts", 1, 2, favoritesCommand},
+}
+
+// PrintUsage writes the list of the commands to w.
+func PrintUsage(w io.Writer) {
+	fmt.Fprintf(w, "usage: too-good-ant [command] [arguments] [options]\n\ncommands:\n")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, cmd := range kCommands {
+		fmt.Fprintf(tw, "  %v %v\t%v\n", cmd.name, cmd.arguments, cmd.description)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\noptions:\n")
+}
+
+// parseArgs parses the options of flagSet found anywhere in args, so that they can also follow the command,
+// and returns the other arguments in order. Arguments after "--" are never parsed as options.
+func parseArgs(flagSet *flag.FlagSet, args []string) ([]string, error) {
+	positionalArgs := []string{}
+	for len(args) > 0 {
+		err := flagSet.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		remainingArgs := flagSet.Args()
+		if len(remainingArgs) == 0 {
+			break
+		}
+		if parsedArgs := args[:len(args)-len(remainingArgs)]; len(parsedArgs) > 0 && parsedArgs[len(parsedArgs)-1] == "--" {
+			positionalArgs = append(positionalArgs, remainingArgs...)
+			break
+		}
+		positionalArgs = append(positionalArgs, remainingArgs[0])
+		args = remainingArgs[1:]
+	}
+	return positionalArgs, nil
+}
+
+// findCommand returns the command named by the first argument and its arguments, "run" if there is none.
+func findCommand(args []string) (command, []string, error) {
+	if len(args) == 0 {
+		return kCommands[0], args, nil
+	}
+	cmdPos := slices.IndexFunc(kCommands, func(cmd command) bool { return cmd.name == args[0] })
+	if cmdPos == -1 {
+		return command{}, nil, fmt.Errorf("%w: unknown command %v", ErrUsage, args[0])
+	}
+	cmd := kCommands[cmdPos]
+	cmdArgs := args[1:]
+	if len(cmdArgs) < cmd.minNbArgs || len(cmdArgs) > cmd.maxNbArgs {
+		return cmd, nil, fmt.Errorf("%w: %v %v", ErrUsage, cmd.name, cmd.arguments)
+	}
+	return cmd, cmdArgs, nil
+}
+
+func runCommand(ctx context.Context, env *commandEnv, args []string) error {
+	return runAnt(ctx, env.config)
+}
+
+func listCommand(ctx context.Context, env *commandEnv, args []string) error {
+	return env.withClient(ctx, func(client *TooGooToGoClient) error {
+		stores, err := client.ListStores(ctx)
+		if err != nil {
+			return fmt.Errorf("error from client.ListStores: %w", err)
+		}
+		return WriteResults(env.out, env.outputFormat, stores, writeStoresTable)
+	})
+}
+
+func ordersCommand(ctx context.Context, env *commandEnv, args []string) error {
+	return env.withClient(ctx, func(client *TooGooToGoClient) error {
+		orders, err := client.ListOpenedOrders(ctx)
+		if err != nil {
+			return fmt.Errorf("error from client.ListOpenedOrders: %w", err)
+		}
+		return WriteResults(env.out, env.outputFormat, orders, writeOrdersTable)
+	})
+}
+
+func reserveCommand(ctx context.Context, env *commandEnv, args []string) error {
+	nbBags := 1
+	if len(args) > 1 {
+		var err error
+		nbBags, err = strconv.Atoi(args[1])
+		if err != nil || nbBags < 1 {
+			return fmt.Errorf("%w: invalid number of bags %v", ErrUsage, args[1])
+		}
+	}
+	return env.withClient(ctx, func(client *TooGooToGoClient) error {
+		store, err := client.GetItem(ctx, args[0])
+		if err != nil {
+			return fmt.Errorf("error from client.GetItem: %w", err)
+		}
+		reservedOrder, err := client.ReserveOrder(ctx, store, nbBags)
+		if err != nil {
+			return fmt.Errorf("error from client.ReserveOrder: %w", err)
+		}
+		return WriteResult(env.out, env.outputFormat, reservedOrder, "reserved "+reservedOrder.String())
+	})
+}
+
+func cancelCommand(ctx context.Context, env *commandEnv, args []string) error {
+	return env.withClient(ctx, func(client *TooGooToGoClient) error {
+		order, err := client.CancelOpenedOrder(ctx, args[0])
+		if err != nil {
+			return fmt.Errorf("error from client.CancelOpenedOrder: %w", err)
+		}
+		return WriteResult(env.out, env.outputFormat, order, "cancelled "+order.String())
+	})
+}
+
+func payCommand(ctx context.Context, env *commandEnv, args []string) error {
+	paymentConfig := &env.config.TooGoodToGoConfig.PaymentConfig
+	return env.withClient(ctx, func(client *TooGooToGoClient) error {
+		paymentMethod, err := SelectPaymentMethod(ctx, client, paymentConfig)
+		if err != nil {
+			return fmt.Errorf("error from SelectPaymentMethod: %w", err)
+		}
+		orderPayment, err := client.PayOrder(ctx, args[0], paymentMethod)
+		if err != nil {
+			return fmt.Errorf("error from client.PayOrder: %w", err)
+		}
+		paymentStatus, err := client.WaitForPayment(ctx, orderPayment.Id, paymentConfig.paymentTimeout())
+		if err != nil {
+			return fmt.Errorf("error from client.WaitForPayment: %w", err)
+		}
+		orderPayment.State = paymentStatus.State
+		return WriteResult(env.out, env.outputFormat, orderPayment,
+			fmt.Sprintf("paid order %v with %v, payment %v is %v", args[0], paymentMethod.String(), orderPayment.Id, orderPayment.State))
+	})
+}
+
+func paymentMethodsCommand(ctx context.Context, env *commandEnv, args []string) error {
+	paymentProvider := env.config.TooGoodToGoConfig.PaymentConfig.paymentProvider()
+	return env.withClient(ctx, func(client *TooGooToGoClient) error {
+		paymentMethods, err := client.PaymentMethods(ctx, paymentProvider)
+		if err != nil {
+			return fmt.Errorf("error from client.PaymentMethods: %w", err)
+		}
+		return WriteResults(env.out, env.outputFormat, paymentMethods, writePaymentMethodsTable)
+	})
+}
+
+func writeStoresTable(tw io.Writer, stores []Store) {
+	fmt.Fprintf(tw, "ITEM ID\tSTORE\tBAGS\tPRICE\tPICKUP\n")
+	for _, store := range stores {
+		name := store.DisplayName
+		if len(name) == 0 {
+			name = store.Name
+		}
+		pickup := ""
+		if !store.PickupDetails.FromGMT.IsZero() {
+			pickup = FormatPickupWindow(store.PickupDetails.FromGMT, store.PickupDetails.ToGMT, store.TimeZone)
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", store.Id, name, store.AvailableBags, store.Price, pickup)
+	}
+}
+
+func writeOrdersTable(tw io.Writer, orders []Order) {
+	fmt.Fprintf(tw, "ORDER ID\tSTORE\tSTATE\tBAGS\tPRICE\tPICKUP\tCANCEL UNTIL\n")
+	for _, order := range orders {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", order.Id, order.StoreName, order.State, order.Quantity, order.Price,
+			FormatPickupWindow(order.PickupDetails.FromGMT, order.PickupDetails.ToGMT, order.TimeZone), formatOptionalTime(order.CancelUntil))
+	}
+}
+
+func writePaymentMethodsTable(tw io.Writer, paymentMethods []PaymentMethod) {
+	fmt.Fprintf(tw, "ID\tTYPE\tNAME\tPREFERRED\n")
+	for _, paymentMethod := range paymentMethods {
+		preferred := ""
+		if paymentMethod.IsPreferred {
+			preferred = "yes"
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", paymentMethod.Id, paymentMethod.PaymentType, paymentMethod.DisplayValue, preferred)
+	}
+}
+
+// AccountStatus is the log in status of a configured account.
+type AccountStatus struct {
+	Email                  string    `json:"email"`
+	Status                 string    `json:"status"`
+	LastLogInRefreshedTime time.Time `json:"lastLogInRefreshedTime"` // zero if never logged in
+	LastTokenRefreshedTime time.Time `json:"lastTokenRefreshedTime"` // zero if never refreshed
+}
+
+func writeAccountStatusesTable(tw io.Writer, accountStatuses []AccountStatus) {
+	fmt.Fprintf(tw, "ACCOUNT\tSTATUS\tLOGGED IN\tTOKEN REFRESHED\n")
+	for _, accountStatus := range accountStatuses {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", accountStatus.Email, accountStatus.Status,
+			formatOptionalTime(accountStatus.LastLogInRefreshedTime), formatOptionalTime(accountStatus.LastTokenRefreshedTime))
+	}
+}
+
+// FavoritesResult is the result of a favorites command.
+type FavoritesResult struct {
+	Action  string   `json:"action"`            // add, remove or sync
+	Account string   `json:"account"`           // account whose favorites were updated, or copied to the other accounts
+	ItemIds []string `json:"itemIds,omitempty"` // added or removed items
+}
+
+type LogoutResult struct {
+	Email       string `json:"email"`
+	WasLoggedIn bool   `json:"wasLoggedIn"` // false if there was no authorization data to forget
+}
+
+func writeLogoutResultsTable(tw io.Writer, logoutResults []LogoutResult) {
+	for _, logoutResult := range logoutResults {
+		if logoutResult.WasLoggedIn {
+			fmt.Fprintf(tw, "logged out %v\n", logoutResult.Email)
+		} else {
+			fmt.Fprintf(tw, "%v was not logged in\n", logoutResult.Email)
+		}
+	}
+}
+
+// accountPos returns the position of given email in the configured accounts.
+func accountPos(config *TooGoodToGoConfig, email string) (int, error) {
+	pos := slices.IndexFunc(config.Accounts, func(account TooGoodToGoAccount) bool { return account.Email == email })
+	if pos == -1 {
+		return pos, fmt.Errorf("%w: account %v is not configured", ErrUsage, email)
+	}
+	return pos, nil
+}
+
+func loginCommand(ctx context.Context, env *commandEnv, args []string) error {
+	pos, err := accountPos(&env.config.TooGoodToGoConfig, args[0])
+	if err != nil {
+		return err
+	}
+	return env.withClient(ctx, func(client *TooGooToGoClient) error {
+		client.currentAccountPos = pos
+		client.UserAgent, err = getUserAgent(ctx, client.Config, pos)
+		if err != nil {
+			return fmt.Errorf("error from getUserAgent: %w", err)
+		}
+		err = client.ensureAuthDataValidity(ctx)
+		if err != nil {
+			return fmt.Errorf("error from client.ensureAuthDataValidity: %w", err)
+		}
+		accountStatus := AccountStatus{
+			Email:                  client.emailAccount(),
+			Status:                 "logged in",
+			LastLogInRefreshedTime: client.LastLogInRefreshedTime,
+			LastTokenRefreshedTime: client.LastTokenRefreshedTime,
+		}
+		return WriteResult(env.out, env.outputFormat, accountStatus, "logged in as "+accountStatus.Email)
+	})
+}
+
+func logoutCommand(ctx context.Context, env *commandEnv, args []string) error {
+	accounts := env.config.TooGoodToGoConfig.Accounts
+	if len(args) > 0 {
+		pos, err := accountPos(&env.config.TooGoodToGoConfig, args[0])
+		if err != nil {
+			return err
+		}
+		accounts = accounts[pos : pos+1]
+	}
+	var errs []error
+	logoutResults := []LogoutResult{}
+	for _, account := range accounts {
+		err := os.Remove(authorizationFileName(account.Email))
+		switch {
+		case os.IsNotExist(err):
+			logoutResults = append(logoutResults, LogoutResult{Email: account.Email, WasLoggedIn: false})
+		case err != nil:
+			errs = append(errs, fmt.Errorf("error from os.Remove: %w", err))
+		default:
+			logoutResults = append(logoutResults, LogoutResult{Email: account.Email, WasLoggedIn: true})
+		}
+	}
+	err := WriteResults(env.out, env.outputFormat, logoutResults, writeLogoutResultsTable)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+func whoamiCommand(ctx context.Context, env *commandEnv, args []string) error {
+	config := &env.config.TooGoodToGoConfig
+	nbLoggedIn := 0
+	accountStatuses := []AccountStatus{}
+	for _, account := range config.Accounts {
+		authData, err := readAuthorizationData(config, account.Email)
+		status := "logged in"
+		switch {
+		case os.IsNotExist(err), err == nil && !authData.IsLoggedIn():
+			status = "not logged in"
+		case err != nil:
+			status = fmt.Sprintf("unreadable authorization data (%v)", err)
+		case !authData.IsLogInStillValid():
+			status = "log in expired"
+		default:
+			nbLoggedIn++
+		}
+		accountStatuses = append(accountStatuses, AccountStatus{
+			Email:                  account.Email,
+			Status:                 status,
+			LastLogInRefreshedTime: authData.LastLogInRefreshedTime,
+			LastTokenRefreshedTime: authData.LastTokenRefreshedTime,
+		})
+	}
+	err := WriteResults(env.out, env.outputFormat, accountStatuses, writeAccountStatusesTable)
+	if err != nil {
+		return err
+	}
+	if nbLoggedIn == 0 {
+		return fmt.Errorf("%w: no account is logged in", ErrUnauthorized)
+	}
+	return nil
+}
+
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+func reportCommand(ctx context.Context, env *commandEnv, args []string) error {
+	reportFormat := TextReport
+	if len(args) > 0 {
+		var err error
+		reportFormat, err = NewReportFormat(args[0])
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrUsage, err)
+		}
+	}
+
+	orderHistory, err := LoadOrderHistory(filepath.Join(env.config.stateDir(), kOrderHistoryFileName))
+	if err != nil {
+		return fmt.Errorf("error from LoadOrderHistory: %w", err)
+	}
+
+	err = env.withClient(ctx, func(client *TooGooToGoClient) error {
+		return orderHistory.UpdateAllAccounts(ctx, client)
+	})
+	if err != nil {
+		// the report of the history known so far is still useful
+		glog.Printf("error from orderHistory.UpdateAllAccounts: %v\n", err)
+	}
+
+	return errors.Join(err, WriteReports(env.out, orderHistory.Reports(), reportFormat))
+}
+
+func favoritesCommand(ctx context.Context, env *commandEnv, args []string) error {
+	var isFavorite bool
+	switch {
+	case args[0] == "add" && len(args) == 2:
+		isFavorite = true
+	case args[0] == "remove" && len(args) == 2:
+		isFavorite = false
+	case args[0] == "sync" && len(args) == 1:
+		return env.withClient(ctx, func(client *TooGooToGoClient) error {
+			result := FavoritesResult{Action: args[0], Account: client.emailAccount()}
+			err := SyncFavorites(ctx, client)
+			if err != nil {
+				return err
+			}
+			return WriteResult(env.out, env.outputFormat, result, fmt.Sprintf("copied favorites of %v to the other accounts", result.Account))
+		})
+	default:
+		return fmt.Errorf("%w: favorites add <file> | remove <file> | sync", ErrUsage)
+	}
+
+	itemIds, err := ReadItemIdsFromFile(args[1])
+	if err != nil {
+		return fmt.Errorf("error from ReadItemIdsFromFile: %w", err)
+	}
+	return env.withClient(ctx, func(client *TooGooToGoClient) error {
+		err := SetFavorites(ctx, client, itemIds, isFavorite)
+		if err != nil {
+			return err
+		}
+		result := FavoritesResult{Action: args[0], Account: client.emailAccount(), ItemIds: itemIds}
+		return WriteResult(env.out, env.outputFormat, result, fmt.Sprintf("updated %v favorite(s) of %v", len(itemIds), result.Account))
+	})
+}
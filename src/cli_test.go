@@ -0,0 +1,262 @@
+package tga
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCommandEnv(client *TooGooToGoClient) (*commandEnv, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &commandEnv{
+		config:    &Config{TooGoodToGoConfig: *client.Config, StateDir: "testdata"},
+		out:       out,
+		newClient: func(ctx context.Context) *TooGooToGoClient { return client },
+	}, out
+}
+
+func TestFindCommand(t *testing.T) {
+	for _, testCase := range []struct {
+		args         []string
+		expectedName string
+		expectedArgs []string
+		expectedErr  error
+	}{
+		{[]string{}, "run", []string{}, nil},
+		{[]string{"list"}, "list", []string{}, nil},
+		{[]string{"reserve", "42"}, "reserve", []string{"42"}, nil},
+		{[]string{"reserve", "42", "2"}, "reserve", []string{"42", "2"}, nil},
+		{[]string{"reserve"}, "", nil, ErrUsage},
+		{[]string{"reserve", "42", "2", "3"}, "", nil, ErrUsage},
+		{[]string{"logout"}, "logout", []string{}, nil},
+		{[]string{"harvest"}, "", nil, ErrUsage},
+	} {
+		cmd, args, err := findCommand(testCase.args)
+		if !errors.Is(err, testCase.expectedErr) {
+			t.Fatalf("for %v, expected error %v, got %v", testCase.args, testCase.expectedErr, err)
+		}
+		if err != nil {
+			continue
+		}
+		if cmd.name != testCase.expectedName || !reflect.DeepEqual(args, testCase.expectedArgs) {
+			t.Fatalf("for %v, expected command %v %v, got %v %v", testCase.args, testCase.expectedName, testCase.expectedArgs, cmd.name, args)
+		}
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	for _, testCase := range []struct {
+		args                 []string
+		expectedArgs         []string
+		expectedOutputFormat OutputFormat
+		expectedVerbose      bool
+	}{
+		{[]string{}, []string{}, TableOutput, false},
+		{[]string{"list"}, []string{"list"}, TableOutput, false},
+		{[]string{"-output", "json", "list"}, []string{"list"}, JsonOutput, false},
+		{[]string{"list", "--output", "json"}, []string{"list"}, JsonOutput, false},
+		{[]string{"-v", "reserve", "42", "-output=ndjson", "2"}, []string{"reserve", "42", "2"}, NdjsonOutput, true},
+		{[]string{"reserve", "--", "-42"}, []string{"reserve", "-42"}, TableOutput, false},
+	} {
+		flagSet := flag.NewFlagSet("too-good-ant", flag.ContinueOnError)
+		verbose := flagSet.Bool("v", false, "")
+		var outputFormat OutputFormat
+		flagSet.Var(&outputFormat, "output", "")
+
+		args, err := parseArgs(flagSet, testCase.args)
+		if err != nil {
+			t.Fatalf("for %v, error from parseArgs: %v", testCase.args, err)
+		}
+		if !reflect.DeepEqual(args, testCase.expectedArgs) || outputFormat != testCase.expectedOutputFormat || *verbose != testCase.expectedVerbose {
+			t.Fatalf("for %v, expected %v with output %v and verbose %v, got %v with output %v and verbose %v", testCase.args,
+				testCase.expectedArgs, testCase.expectedOutputFormat, testCase.expectedVerbose, args, outputFormat, *verbose)
+		}
+	}
+
+	flagSet := flag.NewFlagSet("too-good-ant", flag.ContinueOnError)
+	flagSet.SetOutput(&bytes.Buffer{})
+	var outputFormat OutputFormat
+	flagSet.Var(&outputFormat, "output", "")
+	_, err := parseArgs(flagSet, []string{"list", "-output", "xml"})
+	if err == nil {
+		t.Fatalf("expected an error for an unknown output format")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	for _, testCase := range []struct {
+		err              error
+		expectedExitCode int
+	}{
+		{nil, kExitSuccess},
+		{fmt.Errorf("error from client.ListStores: %w", errors.New("network down")), kExitFailure},
+		{fmt.Errorf("%w: unknown command", ErrUsage), kExitUsage},
+		{fmt.Errorf("error from client.ListStores: %w", NewApiError(ErrUnauthorized, "item/v7/", QueryResponse{StatusCode: 401}, nil)), kExitUnauthorized},
+		{NewApiError(ErrCaptcha, "item/v7/", QueryResponse{StatusCode: 403}, nil), kExitBlocked},
+		{fmt.Errorf("%w: order 1 is not opened", ErrOrderNotCancellable), kExitRefused},
+		{fmt.Errorf("error from client.WaitForPayment: %w", ErrPaymentTimeout), kExitPaymentFailed},
+		{fmt.Errorf("error from sleepContext: %w", context.Canceled), kExitInterrupted},
+	} {
+		if exitCode := ExitCode(testCase.err); exitCode != testCase.expectedExitCode {
+			t.Fatalf("for error %v, expected exit code %v, got %v", testCase.err, testCase.expectedExitCode, exitCode)
+		}
+	}
+}
+
+func TestListAndReserveCommands(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2), NewFakeItem("2", "Sushi", 1))
+
+	env, out := newTestCommandEnv(newTestClient(server))
+	ctx := context.Background()
+
+	err := listCommand(ctx, env, []string{})
+	if err != nil {
+		t.Fatalf("error from listCommand: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "ITEM ID") || !strings.Contains(out.String(), "Bakery") || !strings.Contains(out.String(), "Sushi") {
+		t.Fatalf("unexpected stores table %q", out.String())
+	}
+
+	out.Reset()
+	err = reserveCommand(ctx, env, []string{"1", "2"})
+	if err != nil {
+		t.Fatalf("error from reserveCommand: %v", err)
+	}
+	if expectedOutput := "reserved Order # order-1 in store 1 with 2 bags\n"; out.String() != expectedOutput {
+		t.Fatalf("expected output %q, got %q", expectedOutput, out.String())
+	}
+
+	err = reserveCommand(ctx, env, []string{"2", "3"})
+	if ExitCode(err) != kExitRefused {
+		t.Fatalf("expected refused reservation, got %v", err)
+	}
+	err = reserveCommand(ctx, env, []string{"2", "zero"})
+	if ExitCode(err) != kExitUsage {
+		t.Fatalf("expected usage error for invalid number of bags, got %v", err)
+	}
+}
+
+func TestCancelAndPayCommands(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	now := time.Now()
+	server.AddOrder(NewFakeOrder("order-1", "Bakery", now.Add(2*time.Hour), now.Add(time.Hour)))
+	server.AddOrder(NewFakeOrder("order-2", "Sushi", now.Add(time.Hour), now.Add(-time.Minute)))
+
+	env, out := newTestCommandEnv(newTestClient(server))
+	ctx := context.Background()
+
+	err := cancelCommand(ctx, env, []string{"order-2"})
+	if ExitCode(err) != kExitRefused {
+		t.Fatalf("expected order-2 not to be cancellable, got %v", err)
+	}
+	err = cancelCommand(ctx, env, []string{"order-1"})
+	if err != nil {
+		t.Fatalf("error from cancelCommand: %v", err)
+	}
+	if !reflect.DeepEqual(server.AbortedOrderIds(), []string{"order-1"}) {
+		t.Fatalf("expected order-1 to be aborted, got %v", server.AbortedOrderIds())
+	}
+
+	out.Reset()
+	err = payCommand(ctx, env, []string{"order-3"})
+	if err != nil {
+		t.Fatalf("error from payCommand: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "paid order order-3") || !strings.HasSuffix(out.String(), "is CAPTURED\n") {
+		t.Fatalf("unexpected pay output %q", out.String())
+	}
+
+	server.SetPaymentStates("FAILED")
+	err = payCommand(ctx, env, []string{"order-4"})
+	if ExitCode(err) != kExitPaymentFailed {
+		t.Fatalf("expected payment failure, got %v", err)
+	}
+}
+
+func TestWhoamiCommandWithoutLogIn(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	env, out := newTestCommandEnv(newTestClient(server))
+	env.config.TooGoodToGoConfig.Accounts[0].Email = "unknown@email.com"
+	env.config.TooGoodToGoConfig.Accounts = env.config.TooGoodToGoConfig.Accounts[:1]
+
+	err := whoamiCommand(context.Background(), env, []string{})
+	if ExitCode(err) != kExitUnauthorized {
+		t.Fatalf("expected unauthorized error, got %v", err)
+	}
+	if !strings.Contains(out.String(), "unknown@email.com  not logged in") {
+		t.Fatalf("unexpected whoami output %q", out.String())
+	}
+
+	out.Reset()
+	env.outputFormat = JsonOutput
+	err = whoamiCommand(context.Background(), env, []string{})
+	var accountStatuses []AccountStatus
+	jsonErr := json.Unmarshal(out.Bytes(), &accountStatuses)
+	if ExitCode(err) != kExitUnauthorized || jsonErr != nil || len(accountStatuses) != 1 || accountStatuses[0].Status != "not logged in" {
+		t.Fatalf("unexpected whoami json output %q (%v, %v)", out.String(), err, jsonErr)
+	}
+
+	out.Reset()
+	err = logoutCommand(context.Background(), env, []string{})
+	if err != nil || out.String() != "[\n {\n  \"email\": \"unknown@email.com\",\n  \"wasLoggedIn\": false\n }\n]\n" {
+		t.Fatalf("unexpected logout json output %q (%v)", out.String(), err)
+	}
+
+	out.Reset()
+	env.outputFormat = TableOutput
+	err = logoutCommand(context.Background(), env, []string{"unknown@email.com"})
+	if err != nil || out.String() != "unknown@email.com was not logged in\n" {
+		t.Fatalf("unexpected logout output %q (%v)", out.String(), err)
+	}
+
+	err = loginCommand(context.Background(), env, []string{"other@email.com"})
+	if ExitCode(err) != kExitUsage {
+		t.Fatalf("expected usage error for unconfigured account, got %v", err)
+	}
+}
+
+func TestFavoritesCommandOutput(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 0), NewFakeItem("2", "Sushi", 3))
+
+	filePath := filepath.Join(t.TempDir(), "favorites.txt")
+	err := os.WriteFile(filePath, []byte("1 Bakery\n2 Sushi\n"), 0600)
+	if err != nil {
+		t.Fatalf("error from os.WriteFile: %v", err)
+	}
+
+	env, out := newTestCommandEnv(newTestClient(server))
+	env.outputFormat = NdjsonOutput
+	ctx := context.Background()
+
+	err = favoritesCommand(ctx, env, []string{"add", filePath})
+	if err != nil {
+		t.Fatalf("error from favoritesCommand: %v", err)
+	}
+	var result FavoritesResult
+	err = json.Unmarshal(out.Bytes(), &result)
+	if err != nil || result.Action != "add" || result.Account != "ant1@email.com" || !reflect.DeepEqual(result.ItemIds, []string{"1", "2"}) {
+		t.Fatalf("unexpected favorites output %q (%v)", out.String(), err)
+	}
+
+	out.Reset()
+	env.outputFormat = TableOutput
+	err = favoritesCommand(ctx, env, []string{"sync"})
+	if err != nil {
+		t.Fatalf("error from favoritesCommand: %v", err)
+	}
+	if expectedOutput := "copied favorites of ant1@email.com to the other accounts\n"; out.String() != expectedOutput {
+		t.Fatalf("expected output %q, got %q", expectedOutput, out.String())
+	}
+}
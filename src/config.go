@@ -10,9 +10,28 @@ import (
 type Config struct {
 	TooGoodToGoConfig TooGoodToGoConfig `json:"tooGoodToGoConfig"`
 	SendConfig        SendConfig        `json:"sendConfig"`
+	ApiConfig         ApiConfig         `json:"apiConfig"`
+	StateDir          string            `json:"stateDir"` // directory of the files persisted across restarts
 	Verbose           bool              `json:"verbose"`
 }
 
+// Local http api exposing the state of the running ant.
+type ApiConfig struct {
+	BindAddress string `json:"bindAddress"` // for instance "127.0.0.1:8080", api disabled if empty
+	Token       string `json:"token"`       // optional, required as "Authorization: Bearer <token>" to reserve or cancel orders
+}
+
+const (
+	kDefaultStateDir = "secrets"
+)
+
+func (config *Config) stateDir() string {
+	if len(config.StateDir) == 0 {
+		return kDefaultStateDir
+	}
+	return config.StateDir
+}
+
 // Custom duration to be able to unmarshall it from strings
 type Duration struct {
 	time.Duration
@@ -24,6 +43,7 @@ type TooGoodToGoAccount struct {
 }
 
 type TooGoodToGoConfig struct {
+	BaseUrl                             string               `json:"baseUrl"`
 	Accounts                            []TooGoodToGoAccount `json:"accounts"`
 	Language                            string               `json:"language"`
 	AverageRequestsPeriod               Duration             `json:"averageRequestsPeriod"`
@@ -33,7 +53,19 @@ type TooGoodToGoConfig struct {
 	LogInEmailValidationTimeoutDuration Duration             `json:"logInEmailValidationTimeoutDuration"`
 	LogInValidityDuration               Duration             `json:"logInValidityDuration"`
 	TokenValidityDuration               Duration             `json:"tokenValidityDuration"`
+	RetryConfig                         RetryConfig          `json:"retryConfig"`
 	SearchConfig                        SearchConfig         `json:"searchConfig"`
+	ReserveRules                        []ReserveRule        `json:"reserveRules"`
+	PaymentConfig                       PaymentConfig        `json:"paymentConfig"`
+}
+
+// Retry policy of queries rejected with 429 (too many requests) or 403 (forbidden) http status codes.
+// Zero values are replaced by defaults.
+type RetryConfig struct {
+	MaxRetries        int      `json:"maxRetries"`
+	InitialBackoff    Duration `json:"initialBackoff"`
+	MaxBackoff        Duration `json:"maxBackoff"`
+	BackoffMultiplier float64  `json:"backoffMultiplier"`
 }
 
 type Location struct {
@@ -41,14 +73,121 @@ type Location struct {
 	Longitude float64 `json:"longitude"`
 }
 
-type SearchConfig struct {
+// Search area around an origin. Stores found by a named search are tagged with its name in notifications.
+type Search struct {
+	Name          string   `json:"name"`
 	Origin        Location `json:"origin"`
 	RadiusInKm    int      `json:"radiusInKm"`
-	NbMaxResults  int      `json:"nbMaxResults"`
 	FavoritesOnly bool     `json:"favoritesOnly"`
 	WithStockOnly bool     `json:"withStockOnly"`
 }
 
+// SearchConfig holds a list of named searches. If it is empty, the single unnamed search
+// defined by Origin, RadiusInKm, FavoritesOnly and WithStockOnly is used.
+// Items of the Watchlist are polled individually, every WatchlistPollingPeriod.
+type SearchConfig struct {
+	Origin                 Location `json:"origin"`
+	RadiusInKm             int      `json:"radiusInKm"`
+	NbMaxResults           int      `json:"nbMaxResults"` // number of results per page
+	NbMaxPages             int      `json:"nbMaxPages"`
+	FavoritesOnly          bool     `json:"favoritesOnly"`
+	WithStockOnly          bool     `json:"withStockOnly"`
+	Searches               []Search `json:"searches"`
+	Watchlist              []string `json:"watchlist"`              // item ids
+	WatchlistPollingPeriod Duration `json:"watchlistPollingPeriod"` // 1m if empty
+}
+
+const (
+	kDefaultNbMaxPages = 5
+)
+
+func (searchConfig *SearchConfig) nbMaxPages() int {
+	if searchConfig.NbMaxPages <= 0 {
+		return kDefaultNbMaxPages
+	}
+	return searchConfig.NbMaxPages
+}
+
+func (searchConfig *SearchConfig) searches() []Search {
+	if len(searchConfig.Searches) > 0 {
+		return searchConfig.Searches
+	}
+	return []Search{{
+		Origin:        searchConfig.Origin,
+		RadiusInKm:    searchConfig.RadiusInKm,
+		FavoritesOnly: searchConfig.FavoritesOnly,
+		WithStockOnly: searchConfig.WithStockOnly,
+	}}
+}
+
+// origin returns the origin of the first search, which is the legacy origin if no named search is defined.
+func (searchConfig *SearchConfig) origin() Location {
+	return searchConfig.searches()[0].Origin
+}
+
+// ReserveRule describes the stores to reserve automatically. All defined criteria should match.
+// The first matching rule is applied.
+type ReserveRule struct {
+	Name             string         `json:"name"`
+	StoreIds         []string       `json:"storeIds"`         // item ids, any if empty
+	StoreNamePattern string         `json:"storeNamePattern"` // regular expression matched against store name and display name
+	MaxPrice         float64        `json:"maxPrice"`         // in currency units, no limit if 0
+	MinRating        float64        `json:"minRating"`
+	PickupHours      *HoursInterval `json:"pickupHours"` // pickup window should overlap these hours, in store time zone
+	Weekdays         []string       `json:"weekdays"`    // days of the pickup ("monday", "mon"...), any if empty
+	MaxBags          int            `json:"maxBags"`     // number of bags to reserve, 1 if 0
+}
+
+// Automatic payment of the orders reserved by the reserve rules, disabled by default.
+// Spending limits in currency units are ignored if 0.
+type PaymentConfig struct {
+	AutoPay              bool             `json:"autoPay"`
+	PaymentProvider      *PaymentProvider `json:"paymentProvider"` // ADYEN if not set
+	PaymentMethod        string           `json:"paymentMethod"`   // identifier or display value of the payment method, preferred one if empty
+	PaymentPollingPeriod Duration         `json:"paymentPollingPeriod"`
+	PaymentTimeout       Duration         `json:"paymentTimeout"`
+	MaxDailySpending     float64          `json:"maxDailySpending"`
+	MaxWeeklySpending    float64          `json:"maxWeeklySpending"`
+	MaxNbOrdersPerDay    int              `json:"maxNbOrdersPerDay"`
+
+	// reservations still unpaid after this period are cancelled, disabled if 0
+	UnpaidReservationGracePeriod Duration `json:"unpaidReservationGracePeriod"`
+}
+
+type HoursInterval struct {
+	From TimeOfDay `json:"from"`
+	To   TimeOfDay `json:"to"`
+}
+
+// Custom time of the day to be able to unmarshall it from "15:04" strings
+type TimeOfDay struct {
+	Hour   int
+	Minute int
+}
+
+func (timeOfDay TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", timeOfDay.Hour, timeOfDay.Minute)
+}
+
+func (timeOfDay TimeOfDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(timeOfDay.String())
+}
+
+func (timeOfDay *TimeOfDay) UnmarshalJSON(b []byte) error {
+	var str string
+	err := json.Unmarshal(b, &str)
+	if err != nil {
+		return fmt.Errorf("error from json.Unmarshal: %w", err)
+	}
+	parsedTime, err := time.Parse("15:04", str)
+	if err != nil {
+		return fmt.Errorf("error from time.Parse: %w", err)
+	}
+	timeOfDay.Hour = parsedTime.Hour()
+	timeOfDay.Minute = parsedTime.Minute()
+	return nil
+}
+
 type SendActionType int
 
 const (
@@ -96,9 +235,19 @@ func (s *SendActionType) UnmarshalJSON(b []byte) error {
 }
 
 type SendConfig struct {
-	EmailConfig    EmailConfig    `json:"emailConfig"`
-	WhatsAppConfig WhatsAppConfig `json:"whatsAppConfig"`
-	SendAction     SendActionType `json:"sendAction"`
+	EmailConfig     EmailConfig           `json:"emailConfig"`
+	WhatsAppConfig  WhatsAppConfig        `json:"whatsAppConfig"`
+	SendAction      SendActionType        `json:"sendAction"`
+	NotifyEvents    []StoreEventKind      `json:"notifyEvents"` // newlyAvailable and restocked if empty
+	PickupReminders PickupRemindersConfig `json:"pickupReminders"`
+}
+
+// Reminders of the opened orders, sent at given durations before the start and the end of the pickup window,
+// and before the cancellation deadline.
+type PickupRemindersConfig struct {
+	BeforeStart          []Duration `json:"beforeStart"`
+	BeforeEnd            []Duration `json:"beforeEnd"`
+	BeforeCancelDeadline []Duration `json:"beforeCancelDeadline"` // 15m if absent
 }
 
 type EmailConfig struct {
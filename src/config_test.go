@@ -16,6 +16,7 @@ func TestLoadConfig(t *testing.T) {
 		t.Fatalf("error from ReadConfigFromFile: %v", err)
 	}
 
+	paymentProvider := Adyen
 	expectedConfig := Config{
 		TooGoodToGoConfig: TooGoodToGoConfig{
 			Accounts: []TooGoodToGoAccount{
@@ -50,6 +51,16 @@ func TestLoadConfig(t *testing.T) {
 			TokenValidityDuration: Duration{
 				Duration: time.Duration(8) * time.Hour,
 			},
+			RetryConfig: RetryConfig{
+				MaxRetries: 3,
+				InitialBackoff: Duration{
+					Duration: time.Duration(30) * time.Second,
+				},
+				MaxBackoff: Duration{
+					Duration: time.Duration(10) * time.Minute,
+				},
+				BackoffMultiplier: 2,
+			},
 			SearchConfig: SearchConfig{
 				Origin: Location{
 					Latitude:  41.902782,
@@ -57,8 +68,60 @@ func TestLoadConfig(t *testing.T) {
 				},
 				RadiusInKm:    3,
 				NbMaxResults:  20,
+				NbMaxPages:    3,
 				FavoritesOnly: true,
 				WithStockOnly: true,
+				Searches: []Search{
+					{
+						Name: "home",
+						Origin: Location{
+							Latitude:  41.902782,
+							Longitude: 12.496366,
+						},
+						RadiusInKm:    3,
+						FavoritesOnly: true,
+						WithStockOnly: true,
+					},
+					{
+						Name: "office",
+						Origin: Location{
+							Latitude:  41.890251,
+							Longitude: 12.492373,
+						},
+						RadiusInKm:    1,
+						FavoritesOnly: false,
+						WithStockOnly: true,
+					},
+				},
+				Watchlist:              []string{"523087", "1254871"},
+				WatchlistPollingPeriod: Duration{Duration: time.Minute},
+			},
+			ReserveRules: []ReserveRule{
+				{
+					Name:             "bakery after work",
+					StoreIds:         []string{},
+					StoreNamePattern: "(?i)boulangerie|bakery",
+					MaxPrice:         4.5,
+					MinRating:        4,
+					PickupHours: &HoursInterval{
+						From: TimeOfDay{Hour: 18, Minute: 0},
+						To:   TimeOfDay{Hour: 20, Minute: 30},
+					},
+					Weekdays: []string{"mon", "tue", "wed", "thu", "fri"},
+					MaxBags:  1,
+				},
+			},
+			PaymentConfig: PaymentConfig{
+				AutoPay:              false,
+				PaymentProvider:      &paymentProvider,
+				PaymentMethod:        "",
+				PaymentPollingPeriod: Duration{Duration: 5 * time.Second},
+				PaymentTimeout:       Duration{Duration: 2 * time.Minute},
+				MaxDailySpending:     10,
+				MaxWeeklySpending:    30,
+				MaxNbOrdersPerDay:    2,
+
+				UnpaidReservationGracePeriod: Duration{Duration: 15 * time.Minute},
 			},
 		},
 		SendConfig: SendConfig{
@@ -72,9 +135,19 @@ func TestLoadConfig(t *testing.T) {
 				GroupNameTo: "My WhatsApp Group Name",
 				UserNameTo:  "My WhatsApp User Name",
 			},
-			SendAction: SendEmail,
+			SendAction:   SendEmail,
+			NotifyEvents: []StoreEventKind{NewlyAvailable, Restocked, SoldOut},
+			PickupReminders: PickupRemindersConfig{
+				BeforeStart:          []Duration{{Duration: time.Hour}, {Duration: 15 * time.Minute}},
+				BeforeEnd:            []Duration{{Duration: 15 * time.Minute}},
+				BeforeCancelDeadline: []Duration{{Duration: 15 * time.Minute}},
+			},
+		},
+		ApiConfig: ApiConfig{
+			BindAddress: "127.0.0.1:8080",
 		},
-		Verbose: false,
+		StateDir: "secrets",
+		Verbose:  false,
 	}
 
 	if !reflect.DeepEqual(expectedConfig, *config) {
@@ -0,0 +1,163 @@
+package tga
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+//go:embed dashboard.html
+var kDashboardHtml string
+
+var kDashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"formatTime":   formatOptionalTime,
+	"pickupWindow": formatOptionalPickupWindow,
+	"countdown":    pickupCountdown,
+}).Parse(kDashboardHtml))
+
+const (
+	kCsrfTokenSize = 32
+)
+
+// dashboardData is what the dashboard displays.
+type dashboardData struct {
+	Now              time.Time
+	CsrfToken        string // sent back by the forms, which another site cannot read
+	Message          string // result of the last action
+	Stores           []Store
+	StoresUpdateTime time.Time
+	Orders           []Order
+	OrdersUpdateTime time.Time
+	Accounts         []AccountHealth
+}
+
+func formatOptionalPickupWindow(pickupDetails PickupDetails, timeZone string) string {
+	if pickupDetails.FromGMT.IsZero() {
+		return ""
+	}
+	return FormatPickupWindow(pickupDetails.FromGMT, pickupDetails.ToGMT, timeZone)
+}
+
+// pickupCountdown returns the remaining duration before the start or the end of the pickup window of given order.
+func pickupCountdown(order Order, now time.Time) string {
+	switch {
+	case now.Before(order.PickupDetails.FromGMT):
+		return fmt.Sprintf("starts in %v", order.PickupDetails.FromGMT.Sub(now).Truncate(time.Second))
+	case now.Before(order.PickupDetails.ToGMT):
+		return fmt.Sprintf("ends in %v", order.PickupDetails.ToGMT.Sub(now).Truncate(time.Second))
+	}
+	return "pickup ended"
+}
+
+// csrfToken returns the random token of the forms of the dashboard, generated once per process.
+func (ant *Ant) csrfToken() string {
+	ant.csrfTokenInit.Do(func() {
+		token := make([]byte, kCsrfTokenSize)
+		_, err := rand.Read(token)
+		if err != nil {
+			// forms are refused rather than protected by a guessable token
+			glog.Printf("error from rand.Read: %v\n", err)
+			return
+		}
+		ant.dashboardCsrfToken = hex.EncodeToString(token)
+	})
+	return ant.dashboardCsrfToken
+}
+
+// checkCsrfToken returns an error if the submitted form does not come from the dashboard served by this process.
+func (ant *Ant) checkCsrfToken(req *http.Request) error {
+	err := ant.checkSameOrigin(req)
+	if err != nil {
+		return err
+	}
+	csrfToken := ant.csrfToken()
+	if len(csrfToken) == 0 || subtle.ConstantTimeCompare([]byte(req.PostFormValue("csrfToken")), []byte(csrfToken)) != 1 {
+		return fmt.Errorf("missing or invalid csrf token")
+	}
+	return nil
+}
+
+// withDashboardAuthorization wraps the handler of a dashboard request, which requires the configured api token if any,
+// as the password of a basic authorization (with any user name) prompted by the browser.
+// Otherwise, any host reaching the api could read the csrf token and reserve.
+func (ant *Ant) withDashboardAuthorization(handler http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		if !ant.hasApiToken(req) {
+			res.Header().Set("WWW-Authenticate", `Basic realm="too good ant", charset="UTF-8"`)
+			http.Error(res, "missing or invalid api token", http.StatusUnauthorized)
+			return
+		}
+		handler(res, req)
+	}
+}
+
+func (ant *Ant) handleDashboard(res http.ResponseWriter, req *http.Request) {
+	ant.stateMutex.RLock()
+	data := dashboardData{
+		Now:              time.Now(),
+		CsrfToken:        ant.csrfToken(),
+		Message:          req.URL.Query().Get("message"),
+		Stores:           ant.stores,
+		StoresUpdateTime: ant.storesUpdateTime,
+		Orders:           ant.openedOrders,
+		OrdersUpdateTime: ant.ordersUpdateTime,
+		Accounts:         ant.accountsHealth,
+	}
+	ant.stateMutex.RUnlock()
+
+	var page bytes.Buffer
+	err := kDashboardTemplate.Execute(&page, data)
+	if err != nil {
+		glog.Printf("error from kDashboardTemplate.Execute: %v\n", err)
+		res.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err = res.Write(page.Bytes())
+	if err != nil {
+		glog.Printf("error from res.Write: %v\n", err)
+	}
+}
+
+// handleDashboardReserve reserves the item of the submitted form, and redirects to the dashboard displaying the result.
+func (ant *Ant) handleDashboardReserve(res http.ResponseWriter, req *http.Request) {
+	err := ant.checkCsrfToken(req)
+	if err != nil {
+		glog.Printf("refused dashboard reservation: %v\n", err)
+		http.Error(res, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	itemId := req.FormValue("itemId")
+	nbBags := 1
+	if nbBagsStr := req.FormValue("nbBags"); len(nbBagsStr) > 0 {
+		var err error
+		nbBags, err = strconv.Atoi(nbBagsStr)
+		if err != nil || nbBags < 1 {
+			nbBags = 0
+		}
+	}
+
+	var message string
+	if len(itemId) == 0 || nbBags < 1 {
+		message = "reservation failed: expected an item id and a positive number of bags"
+	} else {
+		reservedOrder, err := ant.reserve(req.Context(), itemId, nbBags)
+		if err != nil {
+			glog.Printf("error from ant.reserve: %v\n", err)
+			message = fmt.Sprintf("reservation failed: %v", err)
+		} else {
+			message = fmt.Sprintf("reserved %v", reservedOrder.String())
+		}
+	}
+
+	http.Redirect(res, req, "/?message="+url.QueryEscape(message), http.StatusSeeOther)
+}
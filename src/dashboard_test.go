@@ -0,0 +1,186 @@
+package tga
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPickupCountdown(t *testing.T) {
+	now := time.Date(2023, time.May, 21, 19, 0, 0, 0, time.UTC)
+	order := Order{PickupDetails: PickupDetails{FromGMT: now.Add(90 * time.Minute), ToGMT: now.Add(2 * time.Hour)}}
+
+	for _, testCase := range []struct {
+		now               time.Time
+		expectedCountdown string
+	}{
+		{now, "starts in 1h30m0s"},
+		{now.Add(100 * time.Minute), "ends in 20m0s"},
+		{now.Add(2 * time.Hour), "pickup ended"},
+	} {
+		if countdown := pickupCountdown(order, testCase.now); countdown != testCase.expectedCountdown {
+			t.Fatalf("at %v, expected countdown %q, got %q", testCase.now, testCase.expectedCountdown, countdown)
+		}
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2), NewFakeItem("2", "<Sushi>", 1))
+	now := time.Now()
+	server.AddOrder(NewFakeOrder("order-1", "Pizza", now.Add(2*time.Hour), now.Add(time.Hour)))
+
+	ant := &Ant{
+		client:       newTestClient(server),
+		sender:       &cancelAfterWriter{nbMaxMessages: 10, cancel: func() {}},
+		storeTracker: NewStoreTracker(nil),
+	}
+	ant.harvestOnce(context.Background())
+	ant.setAccountsHealth(ant.client.AccountsHealth())
+
+	apiServer := httptest.NewServer(ant.apiHandler())
+	defer apiServer.Close()
+
+	res, err := apiServer.Client().Get(apiServer.URL + "/")
+	if err != nil {
+		t.Fatalf("error from client.Get: %v", err)
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatalf("error from io.ReadAll: %v", err)
+	}
+	page := string(body)
+	for _, expectedContent := range []string{
+		"Bakery",
+		"&lt;Sushi&gt;",
+		`<input type="hidden" name="itemId" value="1">`,
+		"Pizza",
+		"starts in 1h59m",
+		"ant1@email.com (in use)",
+		"ant2@email.com",
+		"not logged in",
+	} {
+		if !strings.Contains(page, expectedContent) {
+			t.Fatalf("expected %q in dashboard page:\n%v", expectedContent, page)
+		}
+	}
+	if strings.Contains(page, "<Sushi>") || strings.Contains(page, "http://") || strings.Contains(page, "https://") {
+		t.Fatalf("expected escaped store names and no external asset in dashboard page:\n%v", page)
+	}
+	csrfToken := ant.csrfToken()
+	if len(csrfToken) != 2*kCsrfTokenSize || !strings.Contains(page, `<input type="hidden" name="csrfToken" value="`+csrfToken+`">`) {
+		t.Fatalf("expected csrf token %q in dashboard forms:\n%v", csrfToken, page)
+	}
+
+	// redirections are not followed, to check the message
+	client := apiServer.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }
+	for _, testCase := range []struct {
+		itemId          string
+		expectedMessage string
+	}{
+		{"1", "reserved Order # order-1 in store 1 with 1 bags"},
+		{"3", "reservation failed: "},
+	} {
+		res, err = client.PostForm(apiServer.URL+"/dashboard/reserve", url.Values{"itemId": {testCase.itemId}, "csrfToken": {csrfToken}})
+		if err != nil {
+			t.Fatalf("error from client.PostForm: %v", err)
+		}
+		res.Body.Close()
+		location, err := url.Parse(res.Header.Get("Location"))
+		if err != nil || res.StatusCode != http.StatusSeeOther || location.Path != "/" {
+			t.Fatalf("expected redirection to the dashboard, got %v %v", res.StatusCode, res.Header.Get("Location"))
+		}
+		if message := location.Query().Get("message"); !strings.HasPrefix(message, testCase.expectedMessage) {
+			t.Fatalf("expected message %q, got %q", testCase.expectedMessage, message)
+		}
+	}
+	// forms posted by another site are refused
+	for _, testCase := range []struct {
+		form   url.Values
+		origin string
+	}{
+		{url.Values{"itemId": {"2"}}, ""},
+		{url.Values{"itemId": {"2"}, "csrfToken": {"guessed"}}, ""},
+		{url.Values{"itemId": {"2"}, "csrfToken": {csrfToken}}, "https://evil.example"},
+	} {
+		req, err := http.NewRequest("POST", apiServer.URL+"/dashboard/reserve", strings.NewReader(testCase.form.Encode()))
+		if err != nil {
+			t.Fatalf("error from http.NewRequest: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if len(testCase.origin) > 0 {
+			req.Header.Set("Origin", testCase.origin)
+		}
+		res, err = client.Do(req)
+		if err != nil {
+			t.Fatalf("error from client.Do: %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusForbidden {
+			t.Fatalf("expected forbidden reservation for form %v from origin %q, got %v", testCase.form, testCase.origin, res.StatusCode)
+		}
+	}
+	if nbRequests := server.NbRequests(kApiCreateOrder + "/2"); nbRequests != 0 {
+		t.Fatalf("expected no reservation of item 2, got %v", nbRequests)
+	}
+}
+
+func TestDashboardRequiresApiToken(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+
+	ant := &Ant{
+		client:       newTestClient(server),
+		sender:       &cancelAfterWriter{nbMaxMessages: 10, cancel: func() {}},
+		storeTracker: NewStoreTracker(nil),
+		apiConfig:    &ApiConfig{Token: "secret"},
+	}
+	apiServer := httptest.NewServer(ant.apiHandler())
+	defer apiServer.Close()
+	client := apiServer.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error { return http.ErrUseLastResponse }
+
+	for _, testCase := range []struct {
+		method             string
+		path               string
+		password           string
+		expectedStatusCode int
+	}{
+		{"GET", "/", "", http.StatusUnauthorized},
+		{"GET", "/", "guessed", http.StatusUnauthorized},
+		{"GET", "/", "secret", http.StatusOK},
+		{"POST", "/dashboard/reserve", "", http.StatusUnauthorized},
+		{"POST", "/dashboard/reserve", "secret", http.StatusSeeOther},
+	} {
+		form := url.Values{"itemId": {"1"}, "csrfToken": {ant.csrfToken()}}
+		req, err := http.NewRequest(testCase.method, apiServer.URL+testCase.path, strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatalf("error from http.NewRequest: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if len(testCase.password) > 0 {
+			req.SetBasicAuth("ant", testCase.password)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("error from client.Do: %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != testCase.expectedStatusCode {
+			t.Fatalf("expected status %v for %v %v with password %q, got %v", testCase.expectedStatusCode, testCase.method, testCase.path, testCase.password, res.StatusCode)
+		}
+		if res.StatusCode == http.StatusUnauthorized && len(res.Header.Get("WWW-Authenticate")) == 0 {
+			t.Fatalf("expected the browser to be asked for the token")
+		}
+	}
+	if nbRequests := server.NbRequests(kApiCreateOrder + "/1"); nbRequests != 1 {
+		t.Fatalf("expected 1 reservation, got %v", nbRequests)
+	}
+}
@@ -3,30 +3,55 @@ package tga
 import (
 	"bytes"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"reflect"
+	"path/filepath"
+	"sync"
+	"time"
 )
 
 var (
-	ctx  = context.Background()
 	glog = log.Default()
 )
 
+const (
+	kNotificationStateFileName = "notificationState.json"
+)
+
 func Start() {
 	forceVerbose := flag.Bool("v", false, "Trace requests information for debugging")
 	forceQuiet := flag.Bool("q", false, "Quiet: force verbose deactivation")
 	configFilePath := flag.String("conf", "secrets/config.json", "Configuration file path")
+	var outputFormat OutputFormat
+	flag.Var(&outputFormat, "output", "Output format of the command results: table, json or ndjson")
 
-	flag.Parse()
+	flag.Usage = func() {
+		PrintUsage(flag.CommandLine.Output())
+		flag.PrintDefaults()
+	}
+	args, err := parseArgs(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		os.Exit(kExitUsage)
+	}
+
+	cmd, args, err := findCommand(args)
+	if err != nil {
+		glog.Printf("%v\n", err)
+		flag.Usage()
+		os.Exit(ExitCode(err))
+	}
 
 	config, err := ReadConfigFromFile(*configFilePath)
 	if os.IsNotExist(err) {
-		glog.Fatalf("you need to create file %v that will be loaded and used as your personal configuration", *configFilePath)
+		glog.Printf("you need to create file %v that will be loaded and used as your personal configuration\n", *configFilePath)
+		os.Exit(kExitFailure)
 	} else if err != nil {
-		glog.Fatalf("error from ReadConfigFromFile: %v", err)
+		glog.Printf("error from ReadConfigFromFile: %v\n", err)
+		os.Exit(kExitFailure)
 	}
 
 	if *forceVerbose {
@@ -35,61 +60,370 @@ func Start() {
 		config.Verbose = false
 	}
 
-	sender, err := NewSender(config.SendConfig)
+	// Root context, cancelled at first SIGINT or SIGTERM for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	GracefulShutdownHook(cancel)
+
+	env := &commandEnv{
+		config:       config,
+		out:          os.Stdout,
+		outputFormat: outputFormat,
+		newClient: func(ctx context.Context) *TooGooToGoClient {
+			return NewTooGooToGoClient(ctx, &config.TooGoodToGoConfig, config.Verbose)
+		},
+	}
+	err = cmd.run(ctx, env, args)
+	cancel()
+	if err != nil {
+		glog.Printf("error from command %v: %v\n", cmd.name, err)
+	}
+	os.Exit(ExitCode(err))
+}
+
+// runAnt harvests until ctx is done.
+func runAnt(ctx context.Context, config *Config) error {
+	sender, err := NewSender(ctx, config.SendConfig)
 	if err != nil {
-		glog.Fatalf("error from NewSender: %v", err)
+		return fmt.Errorf("error from NewSender: %w", err)
 	}
 	defer sender.Close()
 
 	glog.Printf("starting too good to go ant for %v accounts\n", len(config.TooGoodToGoConfig.Accounts))
 
-	tooGoodToGoClient := NewTooGooToGoClient(&config.TooGoodToGoConfig, config.Verbose)
+	tooGoodToGoClient := NewTooGooToGoClient(ctx, &config.TooGoodToGoConfig, config.Verbose)
 	defer tooGoodToGoClient.Close()
 
-	// Capture SIGTERM for graceful shutdown
-	stopSignalReceived := false
-	GracefulShutdownHook(&stopSignalReceived)
+	storeTracker, err := LoadStoreTracker(filepath.Join(config.stateDir(), kNotificationStateFileName), config.SendConfig.NotifyEvents)
+	if err != nil {
+		glog.Printf("error from LoadStoreTracker, starting from an empty state: %v\n", err)
+	}
+
+	autoReserver, err := NewAutoReserver(config.TooGoodToGoConfig.ReserveRules)
+	if err != nil {
+		return fmt.Errorf("error from NewAutoReserver: %w", err)
+	}
+	err = autoReserver.LoadReservedWindows(filepath.Join(config.stateDir(), kReservedWindowsFileName))
+	if err != nil {
+		glog.Printf("error from autoReserver.LoadReservedWindows, stores may be reserved again for the same pickup window: %v\n", err)
+	}
+
+	reservationTracker, err := LoadReservationTracker(filepath.Join(config.stateDir(), kReservationsFileName))
+	if err != nil {
+		glog.Printf("error from LoadReservationTracker, previous reservations are forgotten: %v\n", err)
+	}
+
+	pickupReminder, err := LoadPickupReminder(&config.SendConfig.PickupReminders, filepath.Join(config.stateDir(), kSentRemindersFileName))
+	if err != nil {
+		glog.Printf("error from LoadPickupReminder, previous reminders may be sent again: %v\n", err)
+	}
+
+	var antSender io.Writer = sender
+	if config.SendConfig.SendAction != NoSend {
+		antSender = &countingSender{sender: sender, name: config.SendConfig.SendAction.String(), metrics: tooGoodToGoClient.metrics}
+	}
+
+	ant := &Ant{
+		client:             tooGoodToGoClient,
+		sender:             antSender,
+		storeTracker:       storeTracker,
+		autoReserver:       autoReserver,
+		reservationTracker: reservationTracker,
+		commands:           sender.Commands(),
+		pickupReminder:     pickupReminder,
+		watchlist:          NewWatchlist(&config.TooGoodToGoConfig.SearchConfig),
+	}
+
+	paymentConfig := &config.TooGoodToGoConfig.PaymentConfig
+	if paymentConfig.AutoPay {
+		spendingTracker, err := LoadSpendingTracker(filepath.Join(config.stateDir(), kSpendingFileName))
+		if err != nil {
+			return fmt.Errorf("error from LoadSpendingTracker, cannot enforce spending limits: %w", err)
+		}
+		ant.autoPayer = NewAutoPayer(paymentConfig, spendingTracker)
+	}
+
+	if len(config.ApiConfig.BindAddress) > 0 {
+		ant.apiConfig = &config.ApiConfig
+		err = ant.StartApi(ctx, config.ApiConfig.BindAddress)
+		if err != nil {
+			return fmt.Errorf("error from ant.StartApi: %w", err)
+		}
+	}
+
+	watchDone := make(chan struct{})
+	go func() {
+		ant.watch(ctx)
+		close(watchDone)
+	}()
+
+	ant.harvest(ctx)
+	<-watchDone
+
+	glog.Printf("exiting too good ant\n")
+	return nil
+}
+
+// Ant gathers the components used by the harvest loop.
+type Ant struct {
+	client       *TooGooToGoClient
+	sender       io.Writer
+	storeTracker *StoreTracker
+	autoReserver *AutoReserver // optional
+	autoPayer    *AutoPayer    // optional, pays the orders reserved by autoReserver
+
+	reservationTracker *ReservationTracker // optional, unpaid reservations to cancel
+	commands           <-chan string       // messages received from the user
+
+	pickupReminder *PickupReminder // optional
+	watchlist      *Watchlist      // optional, items polled individually
+
+	apiConfig *ApiConfig // optional, nil if the api is not served
+
+	dashboardCsrfToken string
+	csrfTokenInit      sync.Once
+
+	clientLockInit sync.Once // creates the shared lock of the client, see lockClient
+
+	// last known state, read by the api requests
+	stateMutex       sync.RWMutex
+	stores           []Store   // last listed stores
+	storesUpdateTime time.Time // zero if never listed
+	openedOrders     []Order   // last known opened orders
+	ordersUpdateTime time.Time // zero if never listed
+	accountsHealth   []AccountHealth
+}
+
+// harvest polls stores and opened orders until ctx is done, reserving the stores matching the auto reserve rules
+// and writing a message to sender listing the reservations and the store events reported by the tracker.
+func (ant *Ant) harvest(ctx context.Context) {
+	for ctx.Err() == nil {
+		if ant.lockClient(ctx) != nil {
+			return
+		}
+		// measured once the client is held, so that waiting for the api requests and the watchlist before the iteration is not counted
+		startTime := time.Now()
+		ant.harvestOnce(ctx)
+		if ctx.Err() == nil {
+			endTime := time.Now()
+			ant.client.metrics.AddHarvest(endTime.Sub(startTime), endTime)
+		}
+		ant.setAccountsHealth(ant.client.AccountsHealth())
+		ant.unlockClient()
+	}
+}
+
+// lockClient waits for the exclusive use of the client, unless ctx is done first.
+// The client is shared by the harvest loop, the watchlist and the api requests, which hold it while using it.
+// The client releases it during its pauses (between paced queries, backoffs), so that the others can query meanwhile.
+// It is a channel rather than a mutex, so that api requests can stop waiting for it when their context is done.
+func (ant *Ant) lockClient(ctx context.Context) error {
+	ant.clientLockInit.Do(func() {
+		ant.client.sharedLock = make(chan struct{}, 1)
+	})
+	select {
+	case ant.client.sharedLock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
 
-	var lastStoresSent []Store
+func (ant *Ant) unlockClient() {
+	<-ant.client.sharedLock
+}
 
-	for !stopSignalReceived {
-		stores, err := tooGoodToGoClient.ListStores()
+func (ant *Ant) harvestOnce(ctx context.Context) {
+	stores, err := ant.client.ListStores(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		glog.Printf("error from ListStores: %v\n", err)
+		recoverFromError(ctx, ant.client, err)
+		return
+	}
+	ant.setStores(stores)
+
+	ant.reserveMatchingStores(ctx, stores)
+
+	err = notifyStoreEvents(ant.sender, ant.storeTracker, ant.storeTracker.Diff(stores))
+	if err != nil {
+		// state is kept as is, so that the events are notified again at next loop
+		glog.Printf("error from notifyStoreEvents: %v\n", err)
+	} else {
+		// state is only recorded and persisted when consistent with what has been notified
+		ant.storeTracker.Commit(stores)
+		err = ant.storeTracker.Save()
 		if err != nil {
-			glog.Fatalf("error from ListStores: %v", err)
+			glog.Printf("error from storeTracker.Save: %v\n", err)
 		}
+	}
 
-		if len(stores) > 0 && !reflect.DeepEqual(lastStoresSent, stores) {
-			storeMessage, err := computeStoresMessage(stores)
-			if err != nil {
-				glog.Printf("error from computeStoresMessage: %v", err)
+	if ant.client.canListOpenedOrders() {
+		openedOrders, err := ant.client.ListOpenedOrders(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			glog.Printf("error from ListOpenedOrders: %v\n", err)
+			recoverFromError(ctx, ant.client, err)
+		} else {
+			ant.setOpenedOrders(openedOrders)
+			if ant.reservationTracker != nil {
+				err = ant.reservationTracker.RemovePaid(openedOrders)
+				if err != nil {
+					glog.Printf("error from reservationTracker.RemovePaid: %v\n", err)
+				}
 			}
-			_, err = sender.Write(storeMessage)
-			if err != nil {
-				glog.Printf("error from sender.Write: %v", err)
+		}
+	}
+
+	ant.sendPickupReminders()
+
+	ant.cancelUnpaidReservations(ctx)
+}
+
+func (ant *Ant) setStores(stores []Store) {
+	ant.stateMutex.Lock()
+	defer ant.stateMutex.Unlock()
+	ant.stores = stores
+	ant.storesUpdateTime = time.Now()
+}
+
+func (ant *Ant) setAccountsHealth(accountsHealth []AccountHealth) {
+	ant.stateMutex.Lock()
+	defer ant.stateMutex.Unlock()
+	ant.accountsHealth = accountsHealth
+}
+
+func (ant *Ant) setOpenedOrders(openedOrders []Order) {
+	ant.stateMutex.Lock()
+	defer ant.stateMutex.Unlock()
+	ant.openedOrders = openedOrders
+	ant.ordersUpdateTime = time.Now()
+}
+
+// reserveMatchingStores reserves the stores matching the auto reserve rules, pays them if automatic payment is enabled,
+// and notifies the reservations.
+func (ant *Ant) reserveMatchingStores(ctx context.Context, stores []Store) {
+	if ant.autoReserver == nil {
+		return
+	}
+	reservations, err := ant.autoReserver.Reserve(ctx, ant.client, stores)
+	for _, reservation := range reservations {
+		ant.trackReservation(reservation)
+
+		message := reservation.String()
+		if ant.autoPayer != nil {
+			orderPayment, payErr := ant.autoPayer.Pay(ctx, ant.client, reservation)
+			switch {
+			case errors.Is(payErr, ErrPaymentUncertain):
+				glog.Printf("error from autoPayer.Pay: %v\n", payErr)
+				message += fmt.Sprintf("\nautomatic payment outcome unknown, order kept, check it in the app: %v", payErr)
+			case errors.Is(payErr, ErrOrderNotCancelled):
+				glog.Printf("error from autoPayer.Pay: %v\n", payErr)
+				message += fmt.Sprintf("\nautomatic payment failed, order not cancelled: %v", payErr)
+			case payErr != nil:
+				glog.Printf("error from autoPayer.Pay: %v\n", payErr)
+				message += fmt.Sprintf("\nautomatic payment failed, order cancelled: %v", payErr)
+			default:
+				message += fmt.Sprintf("\npaid, payment %v is %v", orderPayment.Id, orderPayment.State)
 			}
-			lastStoresSent = stores
+			// orders which may still be unpaid stay tracked, to be cancelled after the grace period if so
+			if !errors.Is(payErr, ErrOrderNotCancelled) && !errors.Is(payErr, ErrPaymentUncertain) {
+				ant.untrackReservation(reservation)
+			}
+		} else if ant.reservationTracker != nil {
+			message += "\nreply 'cancel' to cancel it"
+		}
+		_, writeErr := ant.sender.Write([]byte(message))
+		if writeErr != nil {
+			glog.Printf("error from sender.Write: %v\n", writeErr)
 		}
+	}
+	if err != nil && ctx.Err() == nil {
+		glog.Printf("error from autoReserver.Reserve: %v\n", err)
+		recoverFromError(ctx, ant.client, err)
+	}
+}
+
+func (ant *Ant) trackReservation(reservation AutoReservation) {
+	if ant.reservationTracker == nil {
+		return
+	}
+	err := ant.reservationTracker.Add(TrackedReservation{
+		OrderId:      reservation.ReservedOrder.Id,
+		StoreName:    reservation.Store.Name,
+		ReservedTime: time.Now(),
+		Email:        reservation.Email,
+	})
+	if err != nil {
+		glog.Printf("error from reservationTracker.Add: %v\n", err)
+	}
+}
 
-		_, err = tooGoodToGoClient.ListOpenedOrders()
+func (ant *Ant) untrackReservation(reservation AutoReservation) {
+	if ant.reservationTracker == nil {
+		return
+	}
+	_, err := ant.reservationTracker.Remove(reservation.ReservedOrder.Id)
+	if err != nil {
+		glog.Printf("error from reservationTracker.Remove: %v\n", err)
+	}
+}
+
+// recoverFromError prepares the client for the next loop after a failed query:
+// blocked accounts are rotated, rejected authorizations are dropped, other errors are followed by a pause.
+func recoverFromError(ctx context.Context, tooGoodToGoClient *TooGooToGoClient, err error) {
+	switch {
+	case errors.Is(err, ErrAccountSwitched):
+		glog.Printf("account switched while backing off, retrying at next loop\n")
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ErrCaptcha), errors.Is(err, ErrForbidden):
+		glog.Printf("too good to go account %v is blocked, switching account\n", tooGoodToGoClient.emailAccount())
+		err = tooGoodToGoClient.switchToNextEmailAccount(ctx)
 		if err != nil {
-			glog.Fatalf("error from ListOpenedOrders: %v", err)
+			glog.Printf("error from switchToNextEmailAccount: %v\n", err)
 		}
+	case errors.Is(err, ErrUnauthorized):
+		glog.Printf("authorization rejected, log in again at next query\n")
+		tooGoodToGoClient.removeLatestAuthorizationFileName()
+		tooGoodToGoClient.resetAuthData()
+	default:
+		// server errors, malformed responses, network errors: keep going after a pause
+		pauseDuration := tooGoodToGoClient.Config.AverageRequestsPeriod.Duration
+		glog.Printf("pausing %v before next query\n", pauseDuration)
+		tooGoodToGoClient.pause(ctx, pauseDuration)
 	}
+}
 
-	glog.Printf("exiting too good ant\n")
+// notifyStoreEvents writes a message listing given events to sender, if any.
+func notifyStoreEvents(sender io.Writer, tracker *StoreTracker, events []StoreEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	eventsMessage, err := computeEventsMessage(events)
+	if err != nil {
+		return fmt.Errorf("error from computeEventsMessage: %w", err)
+	}
+	_, err = sender.Write(eventsMessage)
+	if err != nil {
+		return fmt.Errorf("error from sender.Write: %w", err)
+	}
+	tracker.SetNotified(events, time.Now())
+	return nil
 }
 
-func computeStoresMessage(stores []Store) ([]byte, error) {
-	storeMessage := bytes.NewBuffer([]byte{})
-	for _, store := range stores {
-		_, err := storeMessage.WriteString(store.String())
+func computeEventsMessage(events []StoreEvent) ([]byte, error) {
+	eventsMessage := bytes.NewBuffer([]byte{})
+	for _, event := range events {
+		_, err := eventsMessage.WriteString(event.String())
 		if err != nil {
-			return nil, fmt.Errorf("error from storeMessage.WriteString: %w", err)
+			return nil, fmt.Errorf("error from eventsMessage.WriteString: %w", err)
 		}
-		_, err = storeMessage.WriteString("\n\n")
+		_, err = eventsMessage.WriteString("\n\n")
 		if err != nil {
-			return nil, fmt.Errorf("error from storeMessage.WriteString: %w", err)
+			return nil, fmt.Errorf("error from eventsMessage.WriteString: %w", err)
 		}
 	}
-	return storeMessage.Bytes(), nil
+	return eventsMessage.Bytes(), nil
 }
@@ -0,0 +1,87 @@
+package tga
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Kinds of errors returned by the Too Good To Go client, to be checked with errors.Is.
+var (
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrRateLimited       = errors.New("rate limited")
+	ErrCaptcha           = errors.New("captcha challenge")
+	ErrForbidden         = errors.New("forbidden")
+	ErrServer            = errors.New("server error")
+	ErrMalformedResponse = errors.New("malformed response")
+	ErrUnexpectedStatus  = errors.New("unexpected http status")
+	ErrAccountSwitched   = errors.New("account switched")
+)
+
+const (
+	kMaxBodySnippetLength = 256
+)
+
+// ApiError describes an unexpected response of the Too Good To Go API.
+// It can be retrieved with errors.As, and matches its Kind with errors.Is.
+type ApiError struct {
+	Kind        error
+	StatusCode  int
+	Endpoint    string
+	BodySnippet string
+	Err         error
+}
+
+func (e *ApiError) Error() string {
+	msg := fmt.Sprintf("%v from %v (http status %v)", e.Kind, e.Endpoint, e.StatusCode)
+	if e.Err != nil {
+		msg += fmt.Sprintf(": %v", e.Err)
+	}
+	if len(e.BodySnippet) > 0 {
+		msg += fmt.Sprintf(", body: %v", e.BodySnippet)
+	}
+	return msg
+}
+
+func (e *ApiError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Err}
+}
+
+func bodySnippet(body []byte) string {
+	if len(body) > kMaxBodySnippetLength {
+		return string(body[:kMaxBodySnippetLength]) + "..."
+	}
+	return string(body)
+}
+
+func NewApiError(kind error, endpoint string, response QueryResponse, err error) *ApiError {
+	return &ApiError{
+		Kind:        kind,
+		StatusCode:  response.StatusCode,
+		Endpoint:    endpoint,
+		BodySnippet: bodySnippet(response.Body),
+		Err:         err,
+	}
+}
+
+func NewMalformedResponseError(endpoint string, response QueryResponse, err error) *ApiError {
+	return NewApiError(ErrMalformedResponse, endpoint, response, err)
+}
+
+// ErrorKindFromStatusCode returns the kind of error corresponding to given non OK http status code.
+func ErrorKindFromStatusCode(statusCode int) error {
+	switch {
+	case statusCode == http.StatusUnauthorized:
+		return ErrUnauthorized
+	case statusCode == http.StatusForbidden:
+		return ErrForbidden
+	case statusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case statusCode >= http.StatusInternalServerError:
+		return ErrServer
+	}
+	return ErrUnexpectedStatus
+}
@@ -0,0 +1,55 @@
+package tga
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestErrorKindFromStatusCode(t *testing.T) {
+	expectedKinds := map[int]error{
+		http.StatusUnauthorized:        ErrUnauthorized,
+		http.StatusForbidden:           ErrForbidden,
+		http.StatusTooManyRequests:     ErrRateLimited,
+		http.StatusInternalServerError: ErrServer,
+		http.StatusBadGateway:          ErrServer,
+		http.StatusNotFound:            ErrUnexpectedStatus,
+	}
+	for statusCode, expectedKind := range expectedKinds {
+		if kind := ErrorKindFromStatusCode(statusCode); kind != expectedKind {
+			t.Fatalf("expected kind %v for status %v, got %v", expectedKind, statusCode, kind)
+		}
+	}
+}
+
+func TestApiErrorWrapping(t *testing.T) {
+	parseErr := errors.New("unexpected end of JSON input")
+	response := QueryResponse{
+		StatusCode: http.StatusOK,
+		Body:       []byte(strings.Repeat("a", 2*kMaxBodySnippetLength)),
+	}
+	err := fmt.Errorf("error from ListStores: %w", NewMalformedResponseError(kApiItemEndpoint, response, parseErr))
+
+	if !errors.Is(err, ErrMalformedResponse) {
+		t.Fatalf("expected %v to be a malformed response error", err)
+	}
+	if !errors.Is(err, parseErr) {
+		t.Fatalf("expected %v to wrap %v", err, parseErr)
+	}
+	if errors.Is(err, ErrServer) {
+		t.Fatalf("expected %v not to be a server error", err)
+	}
+
+	var apiErr *ApiError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected %v to be an ApiError", err)
+	}
+	if apiErr.StatusCode != http.StatusOK || apiErr.Endpoint != kApiItemEndpoint {
+		t.Fatalf("unexpected status code %v or endpoint %v", apiErr.StatusCode, apiErr.Endpoint)
+	}
+	if len(apiErr.BodySnippet) != kMaxBodySnippetLength+len("...") {
+		t.Fatalf("expected truncated body snippet, got %v characters", len(apiErr.BodySnippet))
+	}
+}
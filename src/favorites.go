@@ -0,0 +1,124 @@
+package tga
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	kFavoritesSearchRadiusInKm = 30
+)
+
+// ReadItemIdsFromFile reads item ids from given file, one per line. Empty lines and lines starting with '#' are ignored,
+// as well as anything after the first space of a line, which can be used to name the store.
+func ReadItemIdsFromFile(filePath string) ([]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return []string{}, fmt.Errorf("error from os.Open: %w", err)
+	}
+	defer file.Close()
+
+	itemIds := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		itemIds = append(itemIds, fields[0])
+	}
+	err = scanner.Err()
+	if err != nil {
+		return itemIds, fmt.Errorf("error from scanner.Err: %w", err)
+	}
+	return itemIds, nil
+}
+
+// SetFavorites adds given items to the favorites of current account, or removes them if isFavorite is false.
+// Items which could not be updated are skipped, their errors are joined in the returned error.
+// It stops at the first account switch, so that the remaining items are not updated for another account.
+func SetFavorites(ctx context.Context, client *TooGooToGoClient, itemIds []string, isFavorite bool) error {
+	var errs []error
+	for _, itemId := range itemIds {
+		err := client.SetFavorite(ctx, itemId, isFavorite)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error from client.SetFavorite for item %v: %w", itemId, err))
+			if ctx.Err() != nil || errors.Is(err, ErrAccountSwitched) {
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func favoriteItemIds(ctx context.Context, client *TooGooToGoClient) (map[string]bool, error) {
+	favorites, err := client.ListFavorites(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error from client.ListFavorites: %w", err)
+	}
+	itemIds := make(map[string]bool, len(favorites))
+	for _, favorite := range favorites {
+		itemIds[favorite.Id] = true
+	}
+	return itemIds, nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SyncFavorites makes the favorites of all the other accounts identical to the ones of current account,
+// so that the favorites only searches return the same stores whatever the account in use.
+// The sync is aborted if the account switches during a list or set call, as the accounts would not be visited in order anymore.
+func SyncFavorites(ctx context.Context, client *TooGooToGoClient) error {
+	referenceAccount := client.emailAccount()
+	referenceItemIds, err := favoriteItemIds(ctx, client)
+	if err != nil {
+		return err
+	}
+	glog.Printf("syncing %v favorite(s) of account %v\n", len(referenceItemIds), referenceAccount)
+
+	for accountPos := 1; accountPos < len(client.Config.Accounts); accountPos++ {
+		err = client.switchToNextEmailAccount(ctx)
+		if err != nil {
+			return fmt.Errorf("error from client.switchToNextEmailAccount: %w", err)
+		}
+		account := client.emailAccount()
+
+		itemIds, err := favoriteItemIds(ctx, client)
+		if err != nil {
+			return err
+		}
+		missingItemIds := make(map[string]bool)
+		for itemId := range referenceItemIds {
+			if !itemIds[itemId] {
+				missingItemIds[itemId] = true
+			}
+		}
+		extraItemIds := make(map[string]bool)
+		for itemId := range itemIds {
+			if !referenceItemIds[itemId] {
+				extraItemIds[itemId] = true
+			}
+		}
+
+		err = SetFavorites(ctx, client, sortedKeys(missingItemIds), true)
+		if ctx.Err() == nil && !errors.Is(err, ErrAccountSwitched) {
+			err = errors.Join(err, SetFavorites(ctx, client, sortedKeys(extraItemIds), false))
+		}
+		if err != nil {
+			return fmt.Errorf("error from SetFavorites for account %v: %w", account, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package tga
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadItemIdsFromFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "favorites.txt")
+	err := os.WriteFile(filePath, []byte("# my favorite stores\n523087 Ennao\n\n  1254871\n#42\n"), 0600)
+	if err != nil {
+		t.Fatalf("error from os.WriteFile: %v", err)
+	}
+
+	itemIds, err := ReadItemIdsFromFile(filePath)
+	if err != nil {
+		t.Fatalf("error from ReadItemIdsFromFile: %v", err)
+	}
+	if expectedItemIds := []string{"523087", "1254871"}; !reflect.DeepEqual(itemIds, expectedItemIds) {
+		t.Fatalf("expected item ids %v, got %v", expectedItemIds, itemIds)
+	}
+
+	_, err = ReadItemIdsFromFile(filepath.Join(t.TempDir(), "missing.txt"))
+	if err == nil {
+		t.Fatalf("expected error for missing file")
+	}
+}
+
+func TestClientSetAndListFavorites(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 0), NewFakeItem("2", "Sushi", 3), NewFakeItem("3", "Pizza", 1))
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	err := SetFavorites(ctx, client, []string{"1", "2", "3"}, true)
+	if err != nil {
+		t.Fatalf("error from SetFavorites: %v", err)
+	}
+	err = client.SetFavorite(ctx, "3", false)
+	if err != nil {
+		t.Fatalf("error from SetFavorite: %v", err)
+	}
+
+	favorites, err := client.ListFavorites(ctx)
+	if err != nil {
+		t.Fatalf("error from ListFavorites: %v", err)
+	}
+	if expectedIds := []string{"1", "2"}; !reflect.DeepEqual(storeIds(favorites), expectedIds) {
+		t.Fatalf("expected favorites %v, got %v", expectedIds, storeIds(favorites))
+	}
+
+	err = SetFavorites(ctx, client, []string{"4", "3"}, true)
+	if err == nil {
+		t.Fatalf("expected error for unknown item")
+	}
+	if expectedIds := []string{"1", "2", "3"}; !reflect.DeepEqual(server.Favorites("ant1@email.com"), expectedIds) {
+		t.Fatalf("expected favorites %v after partial failure, got %v", expectedIds, server.Favorites("ant1@email.com"))
+	}
+}
+
+func TestClientListFavoritesOfSeveralSearches(t *testing.T) {
+	homeOrigin := Location{Latitude: 48.85, Longitude: 2.35}
+
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 0), NewFakeItem("2", "Sushi", 3))
+	server.SetItemsAt(homeOrigin, NewFakeItem("2", "Sushi", 3))
+
+	client := newTestClient(server)
+	// only named searches, the legacy origin is not set
+	client.Config.SearchConfig.Origin = Location{}
+	client.Config.SearchConfig.Searches = []Search{
+		{Name: "home", Origin: homeOrigin, RadiusInKm: 1},
+		{Name: "office", Origin: Location{Latitude: 48.89, Longitude: 2.24}, RadiusInKm: 2},
+	}
+	ctx := context.Background()
+
+	err := SetFavorites(ctx, client, []string{"1", "2"}, true)
+	if err != nil {
+		t.Fatalf("error from SetFavorites: %v", err)
+	}
+
+	favorites, err := client.ListFavorites(ctx)
+	if err != nil {
+		t.Fatalf("error from ListFavorites: %v", err)
+	}
+	if expectedIds := []string{"2"}; !reflect.DeepEqual(storeIds(favorites), expectedIds) {
+		t.Fatalf("expected favorites around %v %v, got %v", homeOrigin, expectedIds, storeIds(favorites))
+	}
+}
+
+func TestSyncFavorites(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 0), NewFakeItem("2", "Sushi", 3), NewFakeItem("3", "Pizza", 1))
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	err := SetFavorites(ctx, client, []string{"1", "2"}, true)
+	if err != nil {
+		t.Fatalf("error from SetFavorites: %v", err)
+	}
+	err = client.switchToNextEmailAccount(ctx)
+	if err != nil {
+		t.Fatalf("error from switchToNextEmailAccount: %v", err)
+	}
+	err = SetFavorites(ctx, client, []string{"2", "3"}, true)
+	if err != nil {
+		t.Fatalf("error from SetFavorites: %v", err)
+	}
+	err = client.switchToNextEmailAccount(ctx)
+	if err != nil {
+		t.Fatalf("error from switchToNextEmailAccount: %v", err)
+	}
+
+	err = SyncFavorites(ctx, client)
+	if err != nil {
+		t.Fatalf("error from SyncFavorites: %v", err)
+	}
+	for _, email := range []string{"ant1@email.com", "ant2@email.com"} {
+		if expectedIds := []string{"1", "2"}; !reflect.DeepEqual(server.Favorites(email), expectedIds) {
+			t.Fatalf("expected favorites %v for %v, got %v", expectedIds, email, server.Favorites(email))
+		}
+	}
+}
+
+func TestSyncFavoritesAbortedOnAccountSwitch(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 0), NewFakeItem("2", "Sushi", 3), NewFakeItem("3", "Pizza", 1))
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	err := SetFavorites(ctx, client, []string{"1", "2"}, true)
+	if err != nil {
+		t.Fatalf("error from SetFavorites: %v", err)
+	}
+	err = client.switchToNextEmailAccount(ctx)
+	if err != nil {
+		t.Fatalf("error from switchToNextEmailAccount: %v", err)
+	}
+	err = SetFavorites(ctx, client, []string{"3"}, true)
+	if err != nil {
+		t.Fatalf("error from SetFavorites: %v", err)
+	}
+	err = client.switchToNextEmailAccount(ctx)
+	if err != nil {
+		t.Fatalf("error from switchToNextEmailAccount: %v", err)
+	}
+
+	// setting the first missing favorite of ant2 switches back to ant1
+	server.EnqueueCaptcha(kApiItemEndpoint + "1/setFavorite")
+
+	err = SyncFavorites(ctx, client)
+	if !errors.Is(err, ErrAccountSwitched) {
+		t.Fatalf("expected account switched error, got %v", err)
+	}
+	for _, path := range []string{kApiItemEndpoint + "2/setFavorite", kApiItemEndpoint + "3/setFavorite"} {
+		if nbRequests := server.NbRequests(path); nbRequests != 1 {
+			t.Fatalf("expected no more request to %v after the account switch, got %v", path, nbRequests-1)
+		}
+	}
+	if expectedIds := []string{"3"}; !reflect.DeepEqual(server.Favorites("ant2@email.com"), expectedIds) {
+		t.Fatalf("expected favorites %v for ant2, got %v", expectedIds, server.Favorites("ant2@email.com"))
+	}
+}
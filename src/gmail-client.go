@@ -35,7 +35,7 @@ func SetupConfig(emailConfig EmailConfig) (*oauth2.Config, error) {
 	return config, nil
 }
 
-func NewGmailClient(emailConfig EmailConfig) (*gmail.Service, error) {
+func NewGmailClient(ctx context.Context, emailConfig EmailConfig) (*gmail.Service, error) {
 	config, err := SetupConfig(emailConfig)
 	if err != nil {
 		return nil, fmt.Errorf("error from SetupConfig: %w", err)
@@ -54,7 +54,12 @@ func NewGmailClient(emailConfig EmailConfig) (*gmail.Service, error) {
 	}
 
 	// Grabs the authorization code from the web page through the channel provided
-	code := <-codeChan
+	var code string
+	select {
+	case code = <-codeChan:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("gmail authentication interrupted: %w", ctx.Err())
+	}
 
 	// Exchange the auth code for an access token
 	tok, err := config.Exchange(ctx, code)
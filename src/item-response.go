@@ -0,0 +1,132 @@
+package tga
+
+import (
+	"time"
+)
+
+// Structures mirroring the item/v7 payload of the Too Good To Go API.
+// Optional objects are pointers so that their absence can be detected.
+
+type ListItemsResponse struct {
+	Items []ItemEntryResponse `json:"items"`
+}
+
+type ItemEntryResponse struct {
+	Item           *ItemResponse           `json:"item"`
+	Store          *StoreResponse          `json:"store"`
+	DisplayName    string                  `json:"display_name"`
+	PickupLocation *PickupLocationResponse `json:"pickup_location"`
+	PickupInterval *IntervalResponse       `json:"pickup_interval"`
+	PurchaseEnd    *time.Time              `json:"purchase_end"`
+	SoldOutAt      *time.Time              `json:"sold_out_at"`
+	ItemsAvailable int                     `json:"items_available"`
+	Distance       float64                 `json:"distance"`
+	Favorite       bool                    `json:"favorite"`
+	InSalesWindow  bool                    `json:"in_sales_window"`
+	NewItem        bool                    `json:"new_item"`
+	ItemType       string                  `json:"item_type"`
+}
+
+type ItemResponse struct {
+	ItemId                 string           `json:"item_id"`
+	ItemPrice              *PriceResponse   `json:"item_price"`
+	PriceExcludingTaxes    *PriceResponse   `json:"price_excluding_taxes"`
+	ValueIncludingTaxes    *PriceResponse   `json:"value_including_taxes"`
+	ValueExcludingTaxes    *PriceResponse   `json:"value_excluding_taxes"`
+	CoverPicture           *PictureResponse `json:"cover_picture"`
+	LogoPicture            *PictureResponse `json:"logo_picture"`
+	Name                   string           `json:"name"`
+	Description            string           `json:"description"`
+	PackagingOption        string           `json:"packaging_option"`
+	CanUserSupplyPackaging bool             `json:"can_user_supply_packaging"`
+	CollectionInfo         string           `json:"collection_info"`
+	DietCategories         []string         `json:"diet_categories"`
+	ItemCategory           string           `json:"item_category"`
+	Buffet                 bool             `json:"buffet"`
+	Badges                 []BadgeResponse  `json:"badges"`
+	PositiveRatingReasons  []string         `json:"positive_rating_reasons"`
+	AverageOverallRating   *RatingResponse  `json:"average_overall_rating"`
+	FavoriteCount          int              `json:"favorite_count"`
+}
+
+type StoreResponse struct {
+	StoreId       string                  `json:"store_id"`
+	StoreName     string                  `json:"store_name"`
+	Branch        string                  `json:"branch"`
+	Description   string                  `json:"description"`
+	Website       string                  `json:"website"`
+	StoreLocation *PickupLocationResponse `json:"store_location"`
+	LogoPicture   *PictureResponse        `json:"logo_picture"`
+	CoverPicture  *PictureResponse        `json:"cover_picture"`
+	StoreTimeZone string                  `json:"store_time_zone"`
+	Hidden        bool                    `json:"hidden"`
+	FavoriteCount int                     `json:"favorite_count"`
+	WeCare        bool                    `json:"we_care"`
+	Distance      float64                 `json:"distance"`
+}
+
+type PriceResponse struct {
+	Code       string `json:"code"`
+	MinorUnits int    `json:"minor_units"`
+	Decimals   int    `json:"decimals"`
+}
+
+func (p PriceResponse) Price() Price {
+	return Price{
+		Amount:       p.MinorUnits,
+		NbDecimals:   p.Decimals,
+		CurrencyCode: p.Code,
+	}
+}
+
+type PictureResponse struct {
+	PictureId              string `json:"picture_id"`
+	CurrentUrl             string `json:"current_url"`
+	IsAutomaticallyCreated bool   `json:"is_automatically_created"`
+}
+
+type CountryResponse struct {
+	IsoCode string `json:"iso_code"`
+	Name    string `json:"name"`
+}
+
+type AddressResponse struct {
+	Country     CountryResponse `json:"country"`
+	AddressLine string          `json:"address_line"`
+	City        string          `json:"city"`
+	PostalCode  string          `json:"postal_code"`
+}
+
+type PickupLocationResponse struct {
+	Address  AddressResponse `json:"address"`
+	Location Location        `json:"location"`
+}
+
+type IntervalResponse struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+type RatingResponse struct {
+	AverageOverallRating float64 `json:"average_overall_rating"`
+	RatingCount          int     `json:"rating_count"`
+	MonthCount           int     `json:"month_count"`
+}
+
+type BadgeResponse struct {
+	BadgeType   string `json:"badge_type"`
+	RatingGroup string `json:"rating_group"`
+	Percentage  int    `json:"percentage"`
+	UserCount   int    `json:"user_count"`
+	MonthCount  int    `json:"month_count"`
+}
+
+func (b BadgeResponse) Badge() Badge {
+	return Badge{
+		BadgeType:   b.BadgeType,
+		RatingGroup: b.RatingGroup,
+		Percentage:  b.Percentage,
+		UserCount:   b.UserCount,
+		MonthCount:  b.MonthCount,
+	}
+}
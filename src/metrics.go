@@ -0,0 +1,244 @@
+package tga
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	kMetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// path segments of the too good to go endpoints which are not identifiers
+var kEndpointWords = []string{"active", "inactive", "create", "abort", "pay", "setFavorite", "authByEmail", "authByRequestPollingId", "token", "refresh"}
+
+type requestKey struct {
+	endpoint   string
+	statusCode string
+}
+
+type notificationKey struct {
+	sender string
+	result string
+}
+
+// Metrics counts what happens in the ant, exported in the Prometheus text format on the /metrics api endpoint.
+// A nil *Metrics records nothing.
+type Metrics struct {
+	mutex sync.Mutex
+
+	requests          map[requestKey]int
+	nbCaptchas        int
+	nbAccountSwitches int
+	nbLogIns          int
+	nbTokenRefreshes  int
+	notifications     map[notificationKey]int
+
+	nbHarvests          int
+	harvestDurationSum  time.Duration
+	lastHarvestDuration time.Duration
+	lastHarvestTime     time.Time // zero if no harvest iteration ended yet
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		requests:      make(map[requestKey]int),
+		notifications: make(map[notificationKey]int),
+	}
+}
+
+// endpointLabel returns given query path with its identifiers replaced by {id}, to bound the number of label values.
+func endpointLabel(path string) string {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	// first segments are the api name and version
+	for segmentPos := 2; segmentPos < len(segments); segmentPos++ {
+		if !slices.Contains(kEndpointWords, segments[segmentPos]) {
+			segments[segmentPos] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// AddRequest counts a query of path, with statusCode 0 if no response was received.
+func (metrics *Metrics) AddRequest(path string, statusCode int) {
+	if metrics == nil {
+		return
+	}
+	statusCodeLabel := "error"
+	if statusCode != 0 {
+		statusCodeLabel = strconv.Itoa(statusCode)
+	}
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.requests[requestKey{endpointLabel(path), statusCodeLabel}]++
+}
+
+func (metrics *Metrics) AddCaptcha() {
+	if metrics == nil {
+		return
+	}
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.nbCaptchas++
+}
+
+func (metrics *Metrics) AddAccountSwitch() {
+	if metrics == nil {
+		return
+	}
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.nbAccountSwitches++
+}
+
+func (metrics *Metrics) AddLogIn() {
+	if metrics == nil {
+		return
+	}
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.nbLogIns++
+}
+
+func (metrics *Metrics) AddTokenRefresh() {
+	if metrics == nil {
+		return
+	}
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.nbTokenRefreshes++
+}
+
+// AddNotification counts a message written to given sender.
+func (metrics *Metrics) AddNotification(sender string, sent bool) {
+	if metrics == nil {
+		return
+	}
+	result := "sent"
+	if !sent {
+		result = "failed"
+	}
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.notifications[notificationKey{sender, result}]++
+}
+
+// AddHarvest records the duration of an iteration of the harvest loop, ended at endTime.
+func (metrics *Metrics) AddHarvest(duration time.Duration, endTime time.Time) {
+	if metrics == nil {
+		return
+	}
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.nbHarvests++
+	metrics.harvestDurationSum += duration
+	metrics.lastHarvestDuration = duration
+	metrics.lastHarvestTime = endTime
+}
+
+// escapeLabelValue escapes given label value as expected by the Prometheus text format.
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func writeMetricHeader(w io.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, metricType)
+}
+
+func sortedMetricKeys[K comparable](m map[K]int, cmp func(lhs, rhs K) int) []K {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, cmp)
+	return keys
+}
+
+// WriteText writes the metrics in the Prometheus text format to w, with the bags available in given stores listed at storesUpdateTime.
+func (metrics *Metrics) WriteText(w io.Writer, stores []Store, storesUpdateTime time.Time) error {
+	var text bytes.Buffer
+
+	metrics.mutex.Lock()
+	writeMetricHeader(&text, "tga_requests_total", "counter", "Number of too good to go queries by endpoint and http status code.")
+	for _, key := range sortedMetricKeys(metrics.requests, func(lhs, rhs requestKey) int {
+		return strings.Compare(lhs.endpoint+" "+lhs.statusCode, rhs.endpoint+" "+rhs.statusCode)
+	}) {
+		fmt.Fprintf(&text, "tga_requests_total{endpoint=\"%v\",status_code=\"%v\"} %v\n", escapeLabelValue(key.endpoint), key.statusCode, metrics.requests[key])
+	}
+	writeMetricHeader(&text, "tga_captchas_total", "counter", "Number of captcha challenges received.")
+	fmt.Fprintf(&text, "tga_captchas_total %v\n", metrics.nbCaptchas)
+	writeMetricHeader(&text, "tga_account_switches_total", "counter", "Number of switches to the next too good to go account.")
+	fmt.Fprintf(&text, "tga_account_switches_total %v\n", metrics.nbAccountSwitches)
+	writeMetricHeader(&text, "tga_logins_total", "counter", "Number of successful log ins.")
+	fmt.Fprintf(&text, "tga_logins_total %v\n", metrics.nbLogIns)
+	writeMetricHeader(&text, "tga_token_refreshes_total", "counter", "Number of successful token refreshes.")
+	fmt.Fprintf(&text, "tga_token_refreshes_total %v\n", metrics.nbTokenRefreshes)
+	writeMetricHeader(&text, "tga_notifications_total", "counter", "Number of notifications by sender and result (sent or failed).")
+	for _, key := range sortedMetricKeys(metrics.notifications, func(lhs, rhs notificationKey) int {
+		return strings.Compare(lhs.sender+" "+lhs.result, rhs.sender+" "+rhs.result)
+	}) {
+		fmt.Fprintf(&text, "tga_notifications_total{sender=\"%v\",result=\"%v\"} %v\n", escapeLabelValue(key.sender), key.result, metrics.notifications[key])
+	}
+	writeMetricHeader(&text, "tga_harvest_duration_seconds", "summary", "Duration of the iterations of the harvest loop.")
+	fmt.Fprintf(&text, "tga_harvest_duration_seconds_sum %v\n", metrics.harvestDurationSum.Seconds())
+	fmt.Fprintf(&text, "tga_harvest_duration_seconds_count %v\n", metrics.nbHarvests)
+	writeMetricHeader(&text, "tga_last_harvest_duration_seconds", "gauge", "Duration of the last iteration of the harvest loop.")
+	fmt.Fprintf(&text, "tga_last_harvest_duration_seconds %v\n", metrics.lastHarvestDuration.Seconds())
+	writeMetricHeader(&text, "tga_last_harvest_timestamp_seconds", "gauge", "Unix time of the end of the last iteration of the harvest loop, 0 if none.")
+	lastHarvestTimestamp := int64(0)
+	if !metrics.lastHarvestTime.IsZero() {
+		lastHarvestTimestamp = metrics.lastHarvestTime.Unix()
+	}
+	fmt.Fprintf(&text, "tga_last_harvest_timestamp_seconds %v\n", lastHarvestTimestamp)
+	metrics.mutex.Unlock()
+
+	writeMetricHeader(&text, "tga_available_bags", "gauge", "Number of bags available by store at the last listing, see tga_stores_update_timestamp_seconds.")
+	for _, store := range stores {
+		fmt.Fprintf(&text, "tga_available_bags{store_id=\"%v\",store_name=\"%v\"} %v\n", escapeLabelValue(store.Id), escapeLabelValue(store.Name), store.AvailableBags)
+	}
+	writeMetricHeader(&text, "tga_stores_update_timestamp_seconds", "gauge", "Unix time of the last listing of the stores, 0 if none.")
+	storesUpdateTimestamp := int64(0)
+	if !storesUpdateTime.IsZero() {
+		storesUpdateTimestamp = storesUpdateTime.Unix()
+	}
+	fmt.Fprintf(&text, "tga_stores_update_timestamp_seconds %v\n", storesUpdateTimestamp)
+
+	_, err := w.Write(text.Bytes())
+	if err != nil {
+		return fmt.Errorf("error from w.Write: %w", err)
+	}
+	return nil
+}
+
+// countingSender counts the messages written to sender in metrics.
+type countingSender struct {
+	sender  io.Writer
+	name    string
+	metrics *Metrics
+}
+
+func (s *countingSender) Write(message []byte) (int, error) {
+	n, err := s.sender.Write(message)
+	s.metrics.AddNotification(s.name, err == nil)
+	return n, err
+}
+
+func (ant *Ant) handleMetrics(res http.ResponseWriter, req *http.Request) {
+	ant.stateMutex.RLock()
+	stores := ant.stores
+	storesUpdateTime := ant.storesUpdateTime
+	ant.stateMutex.RUnlock()
+
+	res.Header().Set("Content-Type", kMetricsContentType)
+	err := ant.client.metrics.WriteText(res, stores, storesUpdateTime)
+	if err != nil {
+		glog.Printf("error from metrics.WriteText: %v\n", err)
+	}
+}
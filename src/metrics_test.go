@@ -0,0 +1,118 @@
+package tga
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEndpointLabel(t *testing.T) {
+	for _, testCase := range []struct {
+		path          string
+		expectedLabel string
+	}{
+		{kApiItemEndpoint, "item/v7"},
+		{kApiItemEndpoint + "42", "item/v7/{id}"},
+		{"item/v7/42/setFavorite", "item/v7/{id}/setFavorite"},
+		{kApiCreateOrder + "/42", "order/v7/create/{id}"},
+		{"order/v7/order-1/abort", "order/v7/{id}/abort"},
+		{kApiListOpenedOrders, "order/v7/active"},
+		{kApiPayment + "/payment-1", "payment/v3/{id}"},
+		{kRefreshTokenEndpoint, "auth/v3/token/refresh"},
+	} {
+		if label := endpointLabel(testCase.path); label != testCase.expectedLabel {
+			t.Fatalf("for %v, expected label %v, got %v", testCase.path, testCase.expectedLabel, label)
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(message []byte) (int, error) {
+	return 0, errors.New("send failed")
+}
+
+func TestMetricsText(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.AddRequest(kApiItemEndpoint+"1", 200)
+	metrics.AddRequest(kApiItemEndpoint+"2", 200)
+	metrics.AddRequest(kApiItemEndpoint, 0)
+
+	sender := &countingSender{sender: failingWriter{}, name: "email", metrics: metrics}
+	sender.Write([]byte("hello"))
+	sender = &countingSender{sender: io.Discard, name: "email", metrics: metrics}
+	sender.Write([]byte("hello"))
+	sender.Write([]byte("hello again"))
+
+	metrics.AddHarvest(1500*time.Millisecond, time.Unix(1700000000, 0))
+
+	var text bytes.Buffer
+	err := metrics.WriteText(&text, []Store{{Id: "1", Name: "Bakery \"Chez Paul\"\\", AvailableBags: 3}}, time.Unix(1700000100, 0))
+	if err != nil {
+		t.Fatalf("error from metrics.WriteText: %v", err)
+	}
+	for _, expectedLine := range []string{
+		"# TYPE tga_requests_total counter",
+		`tga_requests_total{endpoint="item/v7",status_code="error"} 1`,
+		`tga_requests_total{endpoint="item/v7/{id}",status_code="200"} 2`,
+		"tga_captchas_total 0",
+		`tga_notifications_total{sender="email",result="failed"} 1`,
+		`tga_notifications_total{sender="email",result="sent"} 2`,
+		"tga_harvest_duration_seconds_sum 1.5",
+		"tga_harvest_duration_seconds_count 1",
+		"tga_last_harvest_timestamp_seconds 1700000000",
+		`tga_available_bags{store_id="1",store_name="Bakery \"Chez Paul\"\\"} 3`,
+		"tga_stores_update_timestamp_seconds 1700000100",
+	} {
+		if !strings.Contains(text.String(), expectedLine+"\n") {
+			t.Fatalf("expected line %q in metrics:\n%v", expectedLine, text.String())
+		}
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+	server.EnqueueCaptcha(kApiItemEndpoint)
+
+	ant := &Ant{
+		client:       newTestClient(server),
+		sender:       &cancelAfterWriter{nbMaxMessages: 10, cancel: func() {}},
+		storeTracker: NewStoreTracker(nil),
+	}
+	ant.harvestOnce(context.Background())
+
+	apiServer := httptest.NewServer(ant.apiHandler())
+	defer apiServer.Close()
+
+	res, err := apiServer.Client().Get(apiServer.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("error from client.Get: %v", err)
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		t.Fatalf("error from io.ReadAll: %v", err)
+	}
+	if contentType := res.Header.Get("Content-Type"); contentType != kMetricsContentType {
+		t.Fatalf("expected content type %v, got %v", kMetricsContentType, contentType)
+	}
+	text := string(body)
+	for _, expectedLine := range []string{
+		`tga_requests_total{endpoint="item/v7",status_code="403"} 1`,
+		`tga_requests_total{endpoint="item/v7",status_code="200"} 1`,
+		"tga_captchas_total 1",
+		"tga_account_switches_total 1",
+		"tga_logins_total 2",
+		`tga_available_bags{store_id="1",store_name="Bakery"} 2`,
+	} {
+		if !strings.Contains(text, expectedLine+"\n") {
+			t.Fatalf("expected line %q in metrics:\n%v", expectedLine, text)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package tga
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	kOrderHistoryFileName = "orderHistory.json"
+	kOrderHistoryPageSize = 20
+
+	kCancelledOrderState = "CANCELLED"
+
+	kNbFavoriteStoresInReport = 5
+)
+
+// OrderHistory keeps the past orders of each account in a file, to only fetch the new ones from the server.
+type OrderHistory struct {
+	filePath         string
+	ordersPerAccount map[string][]Order // most recent first
+}
+
+// LoadOrderHistory creates a history persisted in given file, loading its orders from it if it exists.
+func LoadOrderHistory(filePath string) (*OrderHistory, error) {
+	orderHistory := &OrderHistory{
+		filePath:         filePath,
+		ordersPerAccount: make(map[string][]Order),
+	}
+
+	fileData, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return orderHistory, nil
+	}
+	if err != nil {
+		return orderHistory, fmt.Errorf("error from os.ReadFile: %w", err)
+	}
+
+	err = json.Unmarshal(fileData, &orderHistory.ordersPerAccount)
+	if err != nil {
+		return orderHistory, fmt.Errorf("error from json.Unmarshal: %w", err)
+	}
+	return orderHistory, nil
+}
+
+// Save writes the history to its file.
+func (orderHistory *OrderHistory) Save() error {
+	if len(orderHistory.filePath) == 0 {
+		return nil
+	}
+	fileData, err := json.MarshalIndent(orderHistory.ordersPerAccount, "", " ")
+	if err != nil {
+		return fmt.Errorf("error from json.MarshalIndent: %w", err)
+	}
+	err = writeFileAtomically(orderHistory.filePath, fileData)
+	if err != nil {
+		return fmt.Errorf("error from writeFileAtomically: %w", err)
+	}
+	return nil
+}
+
+// Accounts returns the accounts having a history, sorted.
+func (orderHistory *OrderHistory) Accounts() []string {
+	accounts := []string{}
+	for account := range orderHistory.ordersPerAccount {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+func (orderHistory *OrderHistory) isKnown(account, orderId string) bool {
+	for _, order := range orderHistory.ordersPerAccount[account] {
+		if order.Id == orderId {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds the unknown orders among given ones to the history of given account.
+func (orderHistory *OrderHistory) Add(account string, orders []Order) {
+	accountOrders := orderHistory.ordersPerAccount[account]
+	for _, order := range orders {
+		if !orderHistory.isKnown(account, order.Id) {
+			accountOrders = append(accountOrders, order)
+		}
+	}
+	sort.SliceStable(accountOrders, func(lhs, rhs int) bool {
+		return accountOrders[lhs].PurchaseTime.After(accountOrders[rhs].PurchaseTime)
+	})
+	orderHistory.ordersPerAccount[account] = accountOrders
+}
+
+// Update fetches the new past orders of the current account of the client, and saves the history.
+func (orderHistory *OrderHistory) Update(ctx context.Context, client *TooGooToGoClient) error {
+	account := client.emailAccount()
+	orders, err := client.ListOrderHistory(ctx, func(orderId string) bool {
+		return orderHistory.isKnown(account, orderId)
+	})
+	if err != nil {
+		return fmt.Errorf("error from client.ListOrderHistory: %w", err)
+	}
+	orderHistory.setValues(ctx, client, orders)
+	orderHistory.Add(account, orders)
+	return orderHistory.Save()
+}
+
+// itemValues returns the known values of the items of the orders of all accounts.
+func (orderHistory *OrderHistory) itemValues() map[string]Price {
+	itemValues := make(map[string]Price)
+	for _, orders := range orderHistory.ordersPerAccount {
+		for _, order := range orders {
+			if len(order.ItemId) > 0 && order.Value.Amount > 0 {
+				itemValues[order.ItemId] = order.Value
+			}
+		}
+	}
+	return itemValues
+}
+
+// setValues sets the value of given orders, which the order payloads do not hold, from the current value of their item.
+// Values already known for an item are reused, other items are queried once. Orders whose item cannot be queried
+// (removed since, for instance) keep an unknown value.
+func (orderHistory *OrderHistory) setValues(ctx context.Context, client *TooGooToGoClient, orders []Order) {
+	itemValues := orderHistory.itemValues()
+	for orderPos := range orders {
+		order := &orders[orderPos]
+		if len(order.ItemId) == 0 || order.State == kCancelledOrderState {
+			continue
+		}
+		value, isKnown := itemValues[order.ItemId]
+		if !isKnown {
+			store, err := client.GetItem(ctx, order.ItemId)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				glog.Printf("error from client.GetItem, value of item %v is unknown: %v\n", order.ItemId, err)
+			}
+			value = store.Value
+			itemValues[order.ItemId] = value
+		}
+		order.Value = value
+	}
+}
+
+// UpdateAllAccounts updates the history of each configured account, starting from the current one.
+func (orderHistory *OrderHistory) UpdateAllAccounts(ctx context.Context, client *TooGooToGoClient) error {
+	nbAccounts := len(client.Config.Accounts)
+	for accountPos := 0; accountPos < nbAccounts; accountPos++ {
+		if accountPos > 0 {
+			err := client.switchToNextEmailAccount(ctx)
+			if err != nil {
+				return fmt.Errorf("error from client.switchToNextEmailAccount: %w", err)
+			}
+		}
+		err := orderHistory.Update(ctx, client)
+		if err != nil {
+			return fmt.Errorf("error from orderHistory.Update for account %v: %w", client.emailAccount(), err)
+		}
+	}
+	return nil
+}
+
+// StoreReport summarizes the orders of an account in a store.
+type StoreReport struct {
+	StoreId      string  `json:"storeId"`
+	StoreName    string  `json:"storeName"`
+	NbOrders     int     `json:"nbOrders"`
+	NbBags       int     `json:"nbBags"`
+	Spent        float64 `json:"spent"`
+	CurrencyCode string  `json:"currencyCode"`
+}
+
+// CurrencyReport sums the amounts of the orders of an account paid in a currency.
+type CurrencyReport struct {
+	CurrencyCode string  `json:"currencyCode"`
+	Spent        float64 `json:"spent"`
+	Value        float64 `json:"value"` // value of the bags whose value is known
+	Saved        float64 `json:"saved"` // value minus price of the bags whose value is known
+}
+
+// OrderReport summarizes the past orders of an account. Cancelled orders are only counted in NbCancelledOrders.
+type OrderReport struct {
+	Account           string           `json:"account"`
+	NbOrders          int              `json:"nbOrders"`
+	NbCancelledOrders int              `json:"nbCancelledOrders"`
+	NbBags            int              `json:"nbBags"`
+	Currencies        []CurrencyReport `json:"currencies"`     // sorted by currency code
+	FavoriteStores    []StoreReport    `json:"favoriteStores"` // most rescued bags first
+}
+
+// Report computes the report of given account.
+func (orderHistory *OrderHistory) Report(account string) OrderReport {
+	report := OrderReport{
+		Account:        account,
+		Currencies:     []CurrencyReport{},
+		FavoriteStores: []StoreReport{},
+	}
+	currencyReports := make(map[string]*CurrencyReport)
+	storeReports := make(map[string]*StoreReport)
+
+	for _, order := range orderHistory.ordersPerAccount[account] {
+		if order.State == kCancelledOrderState {
+			report.NbCancelledOrders++
+			continue
+		}
+		spent := order.Price.FloatAmount() * float64(order.Quantity)
+
+		report.NbOrders++
+		report.NbBags += order.Quantity
+
+		currencyReport, hasCurrencyReport := currencyReports[order.Price.CurrencyCode]
+		if !hasCurrencyReport {
+			currencyReport = &CurrencyReport{CurrencyCode: order.Price.CurrencyCode}
+			currencyReports[order.Price.CurrencyCode] = currencyReport
+		}
+		currencyReport.Spent += spent
+		if order.Value.Amount > 0 && order.Value.CurrencyCode == order.Price.CurrencyCode {
+			value := order.Value.FloatAmount() * float64(order.Quantity)
+			currencyReport.Value += value
+			currencyReport.Saved += value - spent
+		}
+
+		storeReport, hasStoreReport := storeReports[order.StoreId]
+		if !hasStoreReport {
+			storeReport = &StoreReport{StoreId: order.StoreId, StoreName: order.StoreName, CurrencyCode: order.Price.CurrencyCode}
+			storeReports[order.StoreId] = storeReport
+		}
+		storeReport.NbOrders++
+		storeReport.NbBags += order.Quantity
+		storeReport.Spent += spent
+	}
+
+	for _, currencyReport := range currencyReports {
+		report.Currencies = append(report.Currencies, *currencyReport)
+	}
+	sort.Slice(report.Currencies, func(lhs, rhs int) bool {
+		return report.Currencies[lhs].CurrencyCode < report.Currencies[rhs].CurrencyCode
+	})
+
+	for _, storeReport := range storeReports {
+		report.FavoriteStores = append(report.FavoriteStores, *storeReport)
+	}
+	sort.Slice(report.FavoriteStores, func(lhs, rhs int) bool {
+		lhsStore, rhsStore := &report.FavoriteStores[lhs], &report.FavoriteStores[rhs]
+		if lhsStore.NbBags != rhsStore.NbBags {
+			return lhsStore.NbBags > rhsStore.NbBags
+		}
+		return lhsStore.StoreId < rhsStore.StoreId
+	})
+	if len(report.FavoriteStores) > kNbFavoriteStoresInReport {
+		report.FavoriteStores = report.FavoriteStores[:kNbFavoriteStoresInReport]
+	}
+	return report
+}
+
+// Reports computes the report of each account of the history.
+func (orderHistory *OrderHistory) Reports() []OrderReport {
+	reports := []OrderReport{}
+	for _, account := range orderHistory.Accounts() {
+		reports = append(reports, orderHistory.Report(account))
+	}
+	return reports
+}
+
+func (r *OrderReport) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "account %v: %v bag(s) rescued in %v order(s)", r.Account, r.NbBags, r.NbOrders)
+	if r.NbCancelledOrders > 0 {
+		fmt.Fprintf(&sb, " (%v cancelled)", r.NbCancelledOrders)
+	}
+	for _, currencyReport := range r.Currencies {
+		fmt.Fprintf(&sb, "\nspent %.2f %v", currencyReport.Spent, currencyReport.CurrencyCode)
+		if currencyReport.Value > 0 {
+			fmt.Fprintf(&sb, " for a value of %.2f %v, saved %.2f %v", currencyReport.Value, currencyReport.CurrencyCode, currencyReport.Saved, currencyReport.CurrencyCode)
+		}
+	}
+	if len(r.FavoriteStores) > 0 {
+		sb.WriteString("\nfavorite stores:")
+		for _, storeReport := range r.FavoriteStores {
+			fmt.Fprintf(&sb, "\n- %v: %v bag(s) in %v order(s)", storeReport.StoreName, storeReport.NbBags, storeReport.NbOrders)
+		}
+	}
+	return sb.String()
+}
+
+type ReportFormat int8
+
+const (
+	TextReport ReportFormat = iota
+	CsvReport
+	JsonReport
+)
+
+func (f ReportFormat) String() string {
+	switch f {
+	case TextReport:
+		return "text"
+	case CsvReport:
+		return "csv"
+	case JsonReport:
+		return "json"
+	}
+	return "unknown"
+}
+
+func NewReportFormat(str string) (ReportFormat, error) {
+	for reportFormat := TextReport; reportFormat <= JsonReport; reportFormat++ {
+		if reportFormat.String() == str {
+			return reportFormat, nil
+		}
+	}
+	return TextReport, fmt.Errorf("unknown report format %v, should be text, csv or json", str)
+}
+
+// WriteReports writes given reports in given format.
+func WriteReports(w io.Writer, reports []OrderReport, reportFormat ReportFormat) error {
+	switch reportFormat {
+	case CsvReport:
+		return writeCsvReports(w, reports)
+	case JsonReport:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", " ")
+		err := encoder.Encode(reports)
+		if err != nil {
+			return fmt.Errorf("error from encoder.Encode: %w", err)
+		}
+	default:
+		for reportPos := range reports {
+			_, err := fmt.Fprintf(w, "%v\n\n", reports[reportPos].String())
+			if err != nil {
+				return fmt.Errorf("error from fmt.Fprintf: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+func writeCsvReports(w io.Writer, reports []OrderReport) error {
+	csvWriter := csv.NewWriter(w)
+	records := [][]string{{"account", "nbOrders", "nbCancelledOrders", "nbBags", "spent", "value", "saved", "currencyCode", "favoriteStores"}}
+	for _, report := range reports {
+		favoriteStores := make([]string, len(report.FavoriteStores))
+		for storePos, storeReport := range report.FavoriteStores {
+			favoriteStores[storePos] = fmt.Sprintf("%v (%v)", storeReport.StoreName, storeReport.NbBags)
+		}
+		// amounts in several currencies are listed in the same order in their columns
+		spent, value, saved, currencyCodes := []string{}, []string{}, []string{}, []string{}
+		for _, currencyReport := range report.Currencies {
+			spent = append(spent, strconv.FormatFloat(currencyReport.Spent, 'f', 2, 64))
+			value = append(value, strconv.FormatFloat(currencyReport.Value, 'f', 2, 64))
+			saved = append(saved, strconv.FormatFloat(currencyReport.Saved, 'f', 2, 64))
+			currencyCodes = append(currencyCodes, currencyReport.CurrencyCode)
+		}
+		records = append(records, []string{
+			report.Account,
+			strconv.Itoa(report.NbOrders),
+			strconv.Itoa(report.NbCancelledOrders),
+			strconv.Itoa(report.NbBags),
+			strings.Join(spent, "; "),
+			strings.Join(value, "; "),
+			strings.Join(saved, "; "),
+			strings.Join(currencyCodes, "; "),
+			strings.Join(favoriteStores, "; "),
+		})
+	}
+	err := csvWriter.WriteAll(records)
+	if err != nil {
+		return fmt.Errorf("error from csvWriter.WriteAll: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package tga
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newFakePastOrder(orderId, storeName, state string, quantity int, purchaseTime time.Time) map[string]interface{} {
+	order := NewFakeOrder(orderId, storeName, purchaseTime.Add(time.Hour), purchaseTime)
+	order["state"] = state
+	order["quantity"] = quantity
+	order["time_of_purchase"] = purchaseTime.Format(time.RFC3339)
+	order["item_id"] = "item-" + storeName
+	return order
+}
+
+// newFakeValuedItem builds an item whose bags are worth given value, which is not part of the order payloads.
+func newFakeValuedItem(itemId, storeName string, valueMinorUnits int) map[string]interface{} {
+	item := NewFakeItem(itemId, storeName, 0)
+	item["item"].(map[string]interface{})["value_including_taxes"] = map[string]interface{}{
+		"code":        "EUR",
+		"minor_units": valueMinorUnits,
+		"decimals":    2,
+	}
+	return item
+}
+
+func TestOrderHistoryUpdateAllAccounts(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	purchaseTime := time.Date(2023, time.May, 21, 19, 0, 0, 0, time.UTC)
+	for orderPos := 0; orderPos < kOrderHistoryPageSize+5; orderPos++ {
+		server.AddPastOrder("ant1@email.com", newFakePastOrder(fmt.Sprintf("order-%v", orderPos), "Bakery", "REDEEMED", 1, purchaseTime.AddDate(0, 0, orderPos)))
+	}
+	server.AddPastOrder("ant2@email.com", newFakePastOrder("order-ant2", "Sushi", "REDEEMED", 2, purchaseTime))
+	server.SetItems(newFakeValuedItem("item-Bakery", "Bakery", 1200), newFakeValuedItem("item-Sushi", "Sushi", 1500))
+
+	filePath := filepath.Join(t.TempDir(), kOrderHistoryFileName)
+	orderHistory, err := LoadOrderHistory(filePath)
+	if err != nil {
+		t.Fatalf("error from LoadOrderHistory: %v", err)
+	}
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	err = orderHistory.UpdateAllAccounts(ctx, client)
+	if err != nil {
+		t.Fatalf("error from UpdateAllAccounts: %v", err)
+	}
+	if nbRequests := server.NbRequests("order/v7/inactive"); nbRequests != 3 {
+		t.Fatalf("expected 3 order history requests, got %v", nbRequests)
+	}
+
+	orderHistory, err = LoadOrderHistory(filePath)
+	if err != nil {
+		t.Fatalf("error from LoadOrderHistory: %v", err)
+	}
+	if expectedAccounts := []string{"ant1@email.com", "ant2@email.com"}; !reflect.DeepEqual(orderHistory.Accounts(), expectedAccounts) {
+		t.Fatalf("expected accounts %v, got %v", expectedAccounts, orderHistory.Accounts())
+	}
+	if report := orderHistory.Report("ant1@email.com"); report.NbOrders != kOrderHistoryPageSize+5 {
+		t.Fatalf("expected %v orders, got %v", kOrderHistoryPageSize+5, report.NbOrders)
+	}
+	// values come from the items, queried once each
+	if report := orderHistory.Report("ant2@email.com"); len(report.Currencies) != 1 || report.Currencies[0].Value != 30 {
+		t.Fatalf("expected a value of 30 EUR, got %v", report.Currencies)
+	}
+	if nbRequests := server.NbRequests("item/v7/item-Bakery") + server.NbRequests("item/v7/item-Sushi"); nbRequests != 2 {
+		t.Fatalf("expected 2 item requests, got %v", nbRequests)
+	}
+
+	// only the first page is queried when it contains already known orders
+	server.AddPastOrder("ant2@email.com", newFakePastOrder("order-ant2-new", "Sushi", "REDEEMED", 1, purchaseTime.AddDate(0, 0, 1)))
+	err = orderHistory.Update(ctx, client)
+	if err != nil {
+		t.Fatalf("error from Update: %v", err)
+	}
+	if nbRequests := server.NbRequests("order/v7/inactive"); nbRequests != 4 {
+		t.Fatalf("expected 4 order history requests, got %v", nbRequests)
+	}
+	if report := orderHistory.Report("ant2@email.com"); report.NbOrders != 2 || report.NbBags != 3 {
+		t.Fatalf("expected 3 bags in 2 orders, got %v", report)
+	}
+	if nbRequests := server.NbRequests("item/v7/item-Sushi"); nbRequests != 1 {
+		t.Fatalf("expected the known value of the item to be reused, got %v item requests", nbRequests)
+	}
+}
+
+func TestOrderHistoryUpdateFromRealOrders(t *testing.T) {
+	responseBody, err := os.ReadFile(kExampleOrderPath)
+	if err != nil {
+		t.Fatalf("error reading file %v", kExampleOrderPath)
+	}
+	server := NewFakeTooGoodToGoServer(t)
+	server.Enqueue(kApiListInactiveOrders, FakeResponse{StatusCode: http.StatusOK, Body: string(responseBody)})
+	server.SetItems(newFakeValuedItem("45874", "My Store name", 1200))
+
+	orderHistory := &OrderHistory{ordersPerAccount: make(map[string][]Order)}
+	err = orderHistory.Update(context.Background(), newTestClient(server))
+	if err != nil {
+		t.Fatalf("error from Update: %v", err)
+	}
+	report := orderHistory.Report("ant1@email.com")
+	if report.NbOrders != 1 || len(report.Currencies) != 1 {
+		t.Fatalf("expected 1 order in 1 currency, got %v", report)
+	}
+	if currencyReport := report.Currencies[0]; currencyReport.Value != 12 || fmt.Sprintf("%.2f", currencyReport.Saved) != "8.01" {
+		t.Fatalf("expected a value of 12 EUR and 8.01 EUR saved, got %v", currencyReport)
+	}
+}
+
+func TestOrderHistoryReport(t *testing.T) {
+	price := Price{Amount: 399, NbDecimals: 2, CurrencyCode: "EUR"}
+	value := Price{Amount: 1200, NbDecimals: 2, CurrencyCode: "EUR"}
+	orderHistory := &OrderHistory{ordersPerAccount: make(map[string][]Order)}
+	orderHistory.Add("ant@email.com", []Order{
+		{Id: "1", StoreId: "s1", StoreName: "Bakery", State: "REDEEMED", Quantity: 2, Price: price, Value: value},
+		{Id: "2", StoreId: "s2", StoreName: "Sushi", State: "REDEEMED", Quantity: 1, Price: price},
+		{Id: "3", StoreId: "s1", StoreName: "Bakery", State: "REDEEMED", Quantity: 1, Price: price, Value: value},
+		{Id: "4", StoreId: "s3", StoreName: "Pizza", State: kCancelledOrderState, Quantity: 3, Price: price, Value: value},
+	})
+
+	reports := orderHistory.Reports()
+	expectedReport := OrderReport{
+		Account:           "ant@email.com",
+		NbOrders:          3,
+		NbCancelledOrders: 1,
+		NbBags:            4,
+		Currencies: []CurrencyReport{
+			{CurrencyCode: "EUR", Spent: 4 * 3.99, Value: 3 * 12, Saved: 3 * (12 - 3.99)},
+		},
+		FavoriteStores: []StoreReport{
+			{StoreId: "s1", StoreName: "Bakery", NbOrders: 2, NbBags: 3, Spent: 3 * 3.99, CurrencyCode: "EUR"},
+			{StoreId: "s2", StoreName: "Sushi", NbOrders: 1, NbBags: 1, Spent: 3.99, CurrencyCode: "EUR"},
+		},
+	}
+	if len(reports) != 1 || len(reports[0].Currencies) != 1 || fmt.Sprintf("%.2f", reports[0].Currencies[0].Saved) != fmt.Sprintf("%.2f", expectedReport.Currencies[0].Saved) {
+		t.Fatalf("expected report %v, got %v", expectedReport, reports)
+	}
+	reports[0].Currencies[0].Spent, reports[0].Currencies[0].Saved, reports[0].FavoriteStores[0].Spent = expectedReport.Currencies[0].Spent, expectedReport.Currencies[0].Saved, expectedReport.FavoriteStores[0].Spent
+	if !reflect.DeepEqual(reports[0], expectedReport) {
+		t.Fatalf("expected report %v, got %v", expectedReport, reports[0])
+	}
+
+	var buffer bytes.Buffer
+	err := WriteReports(&buffer, reports, TextReport)
+	if err != nil {
+		t.Fatalf("error from WriteReports: %v", err)
+	}
+	expectedText := "account ant@email.com: 4 bag(s) rescued in 3 order(s) (1 cancelled)\nspent 15.96 EUR for a value of 36.00 EUR, saved 24.03 EUR\nfavorite stores:\n- Bakery: 3 bag(s) in 2 order(s)\n- Sushi: 1 bag(s) in 1 order(s)\n\n"
+	if buffer.String() != expectedText {
+		t.Fatalf("expected text report %q, got %q", expectedText, buffer.String())
+	}
+
+	buffer.Reset()
+	err = WriteReports(&buffer, reports, CsvReport)
+	if err != nil {
+		t.Fatalf("error from WriteReports: %v", err)
+	}
+	expectedCsv := "account,nbOrders,nbCancelledOrders,nbBags,spent,value,saved,currencyCode,favoriteStores\nant@email.com,3,1,4,15.96,36.00,24.03,EUR,Bakery (3); Sushi (1)\n"
+	if buffer.String() != expectedCsv {
+		t.Fatalf("expected csv report %q, got %q", expectedCsv, buffer.String())
+	}
+
+	buffer.Reset()
+	err = WriteReports(&buffer, reports, JsonReport)
+	if err != nil {
+		t.Fatalf("error from WriteReports: %v", err)
+	}
+	var parsedReports []OrderReport
+	err = json.Unmarshal(buffer.Bytes(), &parsedReports)
+	if err != nil || len(parsedReports) != 1 || !strings.Contains(buffer.String(), `"nbBags": 4`) {
+		t.Fatalf("unexpected json report %v (%v)", buffer.String(), err)
+	}
+}
+
+func TestOrderHistoryReportSeveralCurrencies(t *testing.T) {
+	orderHistory := &OrderHistory{ordersPerAccount: make(map[string][]Order)}
+	orderHistory.Add("ant@email.com", []Order{
+		{Id: "1", StoreId: "s1", StoreName: "Bakery", State: "REDEEMED", Quantity: 2, Price: Price{Amount: 399, NbDecimals: 2, CurrencyCode: "EUR"}},
+		{Id: "2", StoreId: "s2", StoreName: "Sushi", State: "REDEEMED", Quantity: 1, Price: Price{Amount: 500, NbDecimals: 2, CurrencyCode: "CHF"}, Value: Price{Amount: 1500, NbDecimals: 2, CurrencyCode: "CHF"}},
+	})
+
+	var buffer bytes.Buffer
+	err := WriteReports(&buffer, orderHistory.Reports(), TextReport)
+	if err != nil {
+		t.Fatalf("error from WriteReports: %v", err)
+	}
+	expectedText := "account ant@email.com: 3 bag(s) rescued in 2 order(s)\nspent 5.00 CHF for a value of 15.00 CHF, saved 10.00 CHF\nspent 7.98 EUR\nfavorite stores:\n- Bakery: 2 bag(s) in 1 order(s)\n- Sushi: 1 bag(s) in 1 order(s)\n\n"
+	if buffer.String() != expectedText {
+		t.Fatalf("expected text report %q, got %q", expectedText, buffer.String())
+	}
+
+	buffer.Reset()
+	err = WriteReports(&buffer, orderHistory.Reports(), CsvReport)
+	if err != nil {
+		t.Fatalf("error from WriteReports: %v", err)
+	}
+	expectedCsv := "account,nbOrders,nbCancelledOrders,nbBags,spent,value,saved,currencyCode,favoriteStores\nant@email.com,2,0,3,5.00; 7.98,15.00; 0.00,10.00; 0.00,CHF; EUR,Bakery (2); Sushi (1)\n"
+	if buffer.String() != expectedCsv {
+		t.Fatalf("expected csv report %q, got %q", expectedCsv, buffer.String())
+	}
+}
+
+func TestNewReportFormat(t *testing.T) {
+	for _, reportFormat := range []ReportFormat{TextReport, CsvReport, JsonReport} {
+		parsedReportFormat, err := NewReportFormat(reportFormat.String())
+		if err != nil || parsedReportFormat != reportFormat {
+			t.Fatalf("expected %v, got %v (%v)", reportFormat, parsedReportFormat, err)
+		}
+	}
+	_, err := NewReportFormat("xml")
+	if err == nil {
+		t.Fatalf("expected error for unknown report format")
+	}
+}
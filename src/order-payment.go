@@ -2,14 +2,20 @@ package tga
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 )
 
+var (
+	ErrPaymentFailed  = errors.New("payment failed")
+	ErrPaymentTimeout = errors.New("payment timeout")
+)
+
 type OrderPayment struct {
-	Id              string
-	OrderId         string
-	PaymentProvider PaymentProvider
-	State           string
+	Id              string          `json:"id"`
+	OrderId         string          `json:"orderId"`
+	PaymentProvider PaymentProvider `json:"paymentProvider"`
+	State           PaymentState    `json:"state"`
 }
 
 func NewOrderPaymentFromPayOrderResponse(responseBody []byte) (OrderPayment, error) {
@@ -29,7 +35,67 @@ func NewOrderPaymentFromPayOrderResponse(responseBody []byte) (OrderPayment, err
 		return orderPayment, fmt.Errorf("error from NewPaymentProvider: %w", err)
 	}
 
-	orderPayment.State = parsedOrderPayment["state"]
+	orderPayment.State, err = NewPaymentState(parsedOrderPayment["state"])
+	if err != nil {
+		glog.Printf("error from NewPaymentState, considered as pending: %v\n", err)
+	}
 
 	return orderPayment, nil
 }
+
+// PaymentStatus is the state of a payment, as returned by payment/v3.
+type PaymentStatus struct {
+	Id              string
+	OrderId         string
+	PaymentProvider PaymentProvider
+	State           PaymentState
+	Amount          Price
+	FailureReason   string
+}
+
+func (p *PaymentStatus) String() string {
+	str := fmt.Sprintf("Payment # %v of order # %v with %v: %v", p.Id, p.OrderId, p.PaymentProvider, p.State)
+	if p.Amount.Amount > 0 {
+		str += fmt.Sprintf(", %v", p.Amount)
+	}
+	if len(p.FailureReason) > 0 {
+		str += fmt.Sprintf(" (%v)", p.FailureReason)
+	}
+	return str
+}
+
+type paymentStatusResponse struct {
+	PaymentId       string           `json:"payment_id"`
+	OrderId         string           `json:"order_id"`
+	PaymentProvider *PaymentProvider `json:"payment_provider"`
+	State           *PaymentState    `json:"state"`
+	Amount          *PriceResponse   `json:"amount"`
+	FailureReason   string           `json:"failure_reason"`
+}
+
+func NewPaymentStatusFromPaymentResponse(responseBody []byte) (PaymentStatus, error) {
+	var paymentStatus PaymentStatus
+
+	var parsedPaymentStatus paymentStatusResponse
+	err := json.Unmarshal(responseBody, &parsedPaymentStatus)
+	if err != nil {
+		glog.Printf("full response: %v\n", string(responseBody))
+		return paymentStatus, fmt.Errorf("error from json.Unmarshal: %w", err)
+	}
+	if parsedPaymentStatus.State == nil {
+		return paymentStatus, fmt.Errorf("missing field 'state'")
+	}
+
+	paymentStatus.Id = parsedPaymentStatus.PaymentId
+	paymentStatus.OrderId = parsedPaymentStatus.OrderId
+	if parsedPaymentStatus.PaymentProvider != nil {
+		paymentStatus.PaymentProvider = *parsedPaymentStatus.PaymentProvider
+	}
+	paymentStatus.State = *parsedPaymentStatus.State
+	if parsedPaymentStatus.Amount != nil {
+		paymentStatus.Amount = parsedPaymentStatus.Amount.Price()
+	}
+	paymentStatus.FailureReason = parsedPaymentStatus.FailureReason
+
+	return paymentStatus, nil
+}
@@ -6,7 +6,8 @@ import (
 )
 
 const (
-	kExampleOrderPaymentFilepath = "testdata/example_order_payment.json"
+	kExampleOrderPaymentFilepath  = "testdata/example_order_payment.json"
+	kExamplePaymentStatusFilepath = "testdata/example_payment_status.json"
 )
 
 func TestOrderPaymentFromResponse(t *testing.T) {
@@ -23,7 +24,7 @@ func TestOrderPaymentFromResponse(t *testing.T) {
 		Id:              "123456789",
 		OrderId:         "orderid12354",
 		PaymentProvider: Adyen,
-		State:           "AUTHORIZATION_INITIATED",
+		State:           AuthorizationInitiated,
 	}
 
 	if expectedOrderPayment != orderPayment {
@@ -31,3 +32,58 @@ func TestOrderPaymentFromResponse(t *testing.T) {
 	}
 
 }
+
+func TestPaymentStatusFromResponse(t *testing.T) {
+	responseBody, err := os.ReadFile(kExamplePaymentStatusFilepath)
+	if err != nil {
+		t.Fatalf("error reading file %v", kExamplePaymentStatusFilepath)
+	}
+	paymentStatus, err := NewPaymentStatusFromPaymentResponse(responseBody)
+	if err != nil {
+		t.Fatalf("error in NewPaymentStatusFromPaymentResponse: %v", err)
+	}
+
+	expectedPaymentStatus := PaymentStatus{
+		Id:              "123456789",
+		OrderId:         "orderid12354",
+		PaymentProvider: Adyen,
+		State:           Captured,
+		Amount: Price{
+			Amount:       399,
+			NbDecimals:   2,
+			CurrencyCode: "EUR",
+		},
+	}
+
+	if expectedPaymentStatus != paymentStatus {
+		t.Fatalf("expected %v == %v\n", expectedPaymentStatus, paymentStatus)
+	}
+	if !paymentStatus.State.IsSuccessful() || !paymentStatus.State.IsTerminal() {
+		t.Fatalf("expected successful and terminal state for %v", paymentStatus.State)
+	}
+
+	paymentStatus, err = NewPaymentStatusFromPaymentResponse([]byte(`{"payment_id": "123456789", "state": "PENDING_3DS"}`))
+	if err != nil || paymentStatus.State != PaymentStateUnknown || paymentStatus.State.IsTerminal() {
+		t.Fatalf("expected an unknown payment state to be pending, got %v, %v", paymentStatus.State, err)
+	}
+	for _, responseBody := range []string{
+		`{"payment_id": "123456789", "state": 3}`,
+		`{"payment_id": "123456789", "state": ""}`,
+	} {
+		paymentStatus, err = NewPaymentStatusFromPaymentResponse([]byte(responseBody))
+		if err == nil && paymentStatus.State != PaymentStateUnknown {
+			t.Fatalf("expected an error or an unknown state for %v, got %v", responseBody, paymentStatus.State)
+		}
+	}
+	var paymentState PaymentState
+	for _, stateJson := range []string{``, `"`, `CAPTURED`} {
+		err = paymentState.UnmarshalJSON([]byte(stateJson))
+		if err == nil {
+			t.Fatalf("expected an error for payment state %q", stateJson)
+		}
+	}
+	_, err = NewPaymentStatusFromPaymentResponse([]byte(`{"payment_id": "123456789"}`))
+	if err == nil {
+		t.Fatalf("expected an error for a missing payment state")
+	}
+}
@@ -2,73 +2,135 @@ package tga
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 )
 
+var (
+	ErrOrderNotCancellable = errors.New("order not cancellable")
+	ErrNotEnoughBags       = errors.New("not enough available bags")
+)
+
 type PickupDetails struct {
-	Address string
-	FromGMT time.Time
-	ToGMT   time.Time
+	Address string    `json:"address"`
+	FromGMT time.Time `json:"fromGMT"`
+	ToGMT   time.Time `json:"toGMT"`
 }
 
 func (p *PickupDetails) String() string {
 	return fmt.Sprintf("%v between [%v, %v]", p.Address, p.FromGMT, p.ToGMT)
 }
 
+// TimeInterval is an interval of time, with zero bounds when unknown.
+type TimeInterval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
 type Order struct {
-	StoreName     string
-	StoreId       string
-	State         string
-	Id            string
-	PickupDetails PickupDetails
-	Rating        float64
-	Price         Price
-	Quantity      int
+	StoreName     string        `json:"storeName"`
+	StoreId       string        `json:"storeId"`
+	State         string        `json:"state"`
+	Id            string        `json:"id"`
+	PickupDetails PickupDetails `json:"pickupDetails"`
+	Rating        float64       `json:"rating"`
+	Price         Price         `json:"price"`
+	Quantity      int           `json:"quantity"`
+	TimeZone      string        `json:"timeZone"`
+
+	CancelUntil    time.Time    `json:"cancelUntil"`    // zero if unknown
+	RedeemInterval TimeInterval `json:"redeemInterval"` // zero if unknown
+
+	ItemId       string    `json:"itemId"`
+	Value        Price     `json:"value"`        // current value of a bag of the item including taxes, zero if unknown
+	PurchaseTime time.Time `json:"purchaseTime"` // zero if unknown
+}
+
+// OrderResponse mirrors an entry of the order/v7 payloads. Optional objects are pointers so that their absence can be detected.
+type OrderResponse struct {
+	OrderId        string                  `json:"order_id"`
+	State          string                  `json:"state"`
+	StoreId        string                  `json:"store_id"`
+	StoreName      string                  `json:"store_name"`
+	StoreTimeZone  string                  `json:"store_time_zone"`
+	ItemId         string                  `json:"item_id"`
+	ItemPrice      *PriceResponse          `json:"item_price"`
+	Quantity       int                     `json:"quantity"`
+	PickupLocation *PickupLocationResponse `json:"pickup_location"`
+	PickupInterval *IntervalResponse       `json:"pickup_interval"`
+	RedeemInterval *IntervalResponse       `json:"redeem_interval"`
+	CancelUntil    *time.Time              `json:"cancel_until"`
+	TimeOfPurchase *time.Time              `json:"time_of_purchase"`
+}
+
+type ListOrdersResponse struct {
+	Orders []OrderResponse `json:"orders"`
 }
 
 func (o *Order) String() string {
 	return fmt.Sprintf("Order # %v, Store # %v, with %v bags to pick at %v", o.Id, o.StoreId, o.Quantity, o.PickupDetails)
 }
 
+// IsCancellable returns false if the cancellation deadline of the order has passed at given time.
+func (o *Order) IsCancellable(now time.Time) bool {
+	return o.CancelUntil.IsZero() || now.Before(o.CancelUntil)
+}
+
+// NewOrder converts given order entry, which should at least have an id. Other missing fields are left empty.
+func NewOrder(orderResponse OrderResponse) (Order, error) {
+	if len(orderResponse.OrderId) == 0 {
+		return Order{}, fmt.Errorf("expected field 'order_id' in order")
+	}
+	order := Order{
+		StoreName: orderResponse.StoreName,
+		StoreId:   orderResponse.StoreId,
+		State:     orderResponse.State,
+		Id:        orderResponse.OrderId,
+		Quantity:  orderResponse.Quantity,
+		TimeZone:  orderResponse.StoreTimeZone,
+		ItemId:    orderResponse.ItemId,
+	}
+	if orderResponse.ItemPrice != nil {
+		order.Price = orderResponse.ItemPrice.Price()
+	}
+	if orderResponse.PickupLocation != nil {
+		order.PickupDetails.Address = orderResponse.PickupLocation.Address.AddressLine
+	}
+	if orderResponse.PickupInterval != nil {
+		order.PickupDetails.FromGMT = orderResponse.PickupInterval.Start
+		order.PickupDetails.ToGMT = orderResponse.PickupInterval.End
+	}
+	if orderResponse.RedeemInterval != nil {
+		order.RedeemInterval.Start = orderResponse.RedeemInterval.Start
+		order.RedeemInterval.End = orderResponse.RedeemInterval.End
+	}
+	if orderResponse.CancelUntil != nil {
+		order.CancelUntil = *orderResponse.CancelUntil
+	}
+	if orderResponse.TimeOfPurchase != nil {
+		order.PurchaseTime = *orderResponse.TimeOfPurchase
+	}
+	return order, nil
+}
+
 func NewOrdersFromListOrdersResponse(responseBody []byte) ([]Order, error) {
 	if len(responseBody) == 0 {
 		return []Order{}, nil
 	}
 
-	var parsedOrders map[string]interface{}
-	err := json.Unmarshal(responseBody, &parsedOrders)
+	var listOrdersResponse ListOrdersResponse
+	err := json.Unmarshal(responseBody, &listOrdersResponse)
 	if err != nil {
-		glog.Printf("full response: %v\n", string(responseBody))
 		return []Order{}, fmt.Errorf("error from json.Unmarshal: %w", err)
 	}
 
-	items := parsedOrders["orders"].([]interface{})
-
-	orders := make([]Order, len(items))
-
-	for itemPos, item := range items {
-		parsedItem := item.(map[string]interface{})
-		orders[itemPos].StoreName = parsedItem["store_name"].(string)
-		orders[itemPos].StoreId = parsedItem["store_id"].(string)
-		orders[itemPos].State = parsedItem["state"].(string)
-		orders[itemPos].Id = parsedItem["order_id"].(string)
-		orders[itemPos].Price = NewPrice(parsedItem["item_price"].(map[string]interface{}))
-		orders[itemPos].Quantity = int(parsedItem["quantity"].(float64))
-
-		orders[itemPos].PickupDetails.Address = parsedItem["pickup_location"].(map[string]interface{})["address"].(map[string]interface{})["address_line"].(string)
-
-		pickupInterval := parsedItem["pickup_interval"].(map[string]interface{})
-
-		orders[itemPos].PickupDetails.FromGMT, err = time.Parse(time.RFC3339, pickupInterval["start"].(string))
+	orders := make([]Order, len(listOrdersResponse.Orders))
+	for orderPos, orderResponse := range listOrdersResponse.Orders {
+		orders[orderPos], err = NewOrder(orderResponse)
 		if err != nil {
-			return []Order{}, fmt.Errorf("error in time.Parse: %w", err)
-		}
-		orders[itemPos].PickupDetails.ToGMT, err = time.Parse(time.RFC3339, pickupInterval["end"].(string))
-		if err != nil {
-			return []Order{}, fmt.Errorf("error in time.Parse: %w", err)
+			return []Order{}, fmt.Errorf("error from NewOrder for order %v: %w", orderPos, err)
 		}
 	}
-
 	return orders, nil
 }
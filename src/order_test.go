@@ -62,7 +62,15 @@ func TestOrderStandardResponse(t *testing.T) {
 			NbDecimals:   2,
 			CurrencyCode: "EUR",
 		},
-		Quantity: 1,
+		Quantity:    1,
+		TimeZone:    "Europe/Paris",
+		CancelUntil: time.Date(2023, time.May, 23, 11, 0, 0, 0, time.UTC),
+		RedeemInterval: TimeInterval{
+			Start: time.Date(2023, time.May, 23, 12, 40, 0, 0, time.UTC),
+			End:   time.Date(2023, time.May, 23, 15, 10, 0, 0, time.UTC),
+		},
+		ItemId:       "45874",
+		PurchaseTime: time.Date(2023, time.May, 23, 10, 41, 18, 0, time.UTC),
 	}
 
 	if order1.StoreName != expectedOrder1.StoreName {
@@ -89,6 +97,78 @@ func TestOrderStandardResponse(t *testing.T) {
 	if order1.Quantity != expectedOrder1.Quantity {
 		t.Fatalf("expected quantity %v, got %v", expectedOrder1.Quantity, order1.Quantity)
 	}
+	if order1.TimeZone != expectedOrder1.TimeZone {
+		t.Fatalf("expected time zone %v, got %v", expectedOrder1.TimeZone, order1.TimeZone)
+	}
+	if !order1.CancelUntil.Equal(expectedOrder1.CancelUntil) {
+		t.Fatalf("expected cancel until %v, got %v", expectedOrder1.CancelUntil, order1.CancelUntil)
+	}
+	if !order1.RedeemInterval.Start.Equal(expectedOrder1.RedeemInterval.Start) || !order1.RedeemInterval.End.Equal(expectedOrder1.RedeemInterval.End) {
+		t.Fatalf("expected redeem interval %v, got %v", expectedOrder1.RedeemInterval, order1.RedeemInterval)
+	}
+	if order1.ItemId != expectedOrder1.ItemId {
+		t.Fatalf("expected item id %v, got %v", expectedOrder1.ItemId, order1.ItemId)
+	}
+	if !order1.PurchaseTime.Equal(expectedOrder1.PurchaseTime) {
+		t.Fatalf("expected purchase time %v, got %v", expectedOrder1.PurchaseTime, order1.PurchaseTime)
+	}
+	if !order1.IsCancellable(expectedOrder1.CancelUntil.Add(-time.Minute)) || order1.IsCancellable(expectedOrder1.CancelUntil) {
+		t.Fatalf("expected order to be cancellable only before %v", expectedOrder1.CancelUntil)
+	}
+}
+
+func TestOrderMissingOptionalDates(t *testing.T) {
+	orderWithDates := func(dates string) string {
+		return `{"orders": [{"store_name": "Bakery", "store_id": "1", "state": "ACTIVE", "order_id": "order-1", "quantity": 1,
+			"item_price": {"code": "EUR", "minor_units": 399, "decimals": 2},
+			"pickup_location": {"address": {"address_line": "1 rue de la Paix"}},
+			"pickup_interval": {"start": "2023-05-23T12:40:00Z", "end": "2023-05-23T15:10:00Z"}` + dates + `}]}`
+	}
+
+	for _, dates := range []string{
+		``,
+		`, "redeem_interval": null, "cancel_until": null, "time_of_purchase": null`,
+		`, "redeem_interval": {}`,
+	} {
+		orders, err := NewOrdersFromListOrdersResponse([]byte(orderWithDates(dates)))
+		if err != nil || len(orders) != 1 {
+			t.Fatalf("expected 1 order for dates %q, got %v and error %v", dates, orders, err)
+		}
+		if !orders[0].RedeemInterval.Start.IsZero() || !orders[0].RedeemInterval.End.IsZero() || !orders[0].CancelUntil.IsZero() || !orders[0].PurchaseTime.IsZero() {
+			t.Fatalf("expected zero dates for dates %q, got %v", dates, orders[0])
+		}
+	}
+
+	orders, err := NewOrdersFromListOrdersResponse([]byte(orderWithDates(`, "redeem_interval": {"start": "2023-05-23T12:40:00Z"}`)))
+	if err != nil || len(orders) != 1 {
+		t.Fatalf("expected 1 order, got %v and error %v", orders, err)
+	}
+	if orders[0].RedeemInterval.Start.IsZero() || !orders[0].RedeemInterval.End.IsZero() {
+		t.Fatalf("expected redeem interval without end, got %v", orders[0].RedeemInterval)
+	}
+
+	_, err = NewOrdersFromListOrdersResponse([]byte(orderWithDates(`, "redeem_interval": {"start": 42}`)))
+	if err == nil {
+		t.Fatalf("expected an error for a malformed redeem interval")
+	}
+}
+
+func TestOrderMissingFields(t *testing.T) {
+	orders, err := NewOrdersFromListOrdersResponse([]byte(`{"orders": [{"order_id": "order-1", "state": "CANCELLED"}]}`))
+	if err != nil || len(orders) != 1 || orders[0].Id != "order-1" || orders[0].State != "CANCELLED" {
+		t.Fatalf("expected 1 cancelled order, got %v and error %v", orders, err)
+	}
+
+	for _, responseBody := range []string{
+		`{"orders": [{"state": "CANCELLED"}]}`,
+		`{"orders": [{"order_id": 42}]}`,
+		`{"orders": {}}`,
+	} {
+		_, err = NewOrdersFromListOrdersResponse([]byte(responseBody))
+		if err == nil {
+			t.Fatalf("expected an error for orders response %q", responseBody)
+		}
+	}
 }
 
 func TestEqual(t *testing.T) {
@@ -116,10 +196,10 @@ func TestEqual(t *testing.T) {
 		Price:  price,
 	}
 
-	if store1 != store2 {
+	if !reflect.DeepEqual(store1, store2) {
 		t.Fatalf("stores should be compared by id (expected %v == %v)\n", store1, store2)
 	}
-	if store1 == store3 {
+	if reflect.DeepEqual(store1, store3) {
 		t.Fatalf("stores should be compared by id (expected %v != %v)\n", store1, store3)
 	}
 
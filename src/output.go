@@ -0,0 +1,97 @@
+package tga
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// OutputFormat is the format of the results printed by the one-shot commands.
+type OutputFormat int8
+
+const (
+	TableOutput  OutputFormat = iota
+	JsonOutput                // a single json document
+	NdjsonOutput              // one json object per line
+)
+
+func (f OutputFormat) String() string {
+	switch f {
+	case TableOutput:
+		return "table"
+	case JsonOutput:
+		return "json"
+	case NdjsonOutput:
+		return "ndjson"
+	}
+	return "unknown"
+}
+
+func NewOutputFormat(str string) (OutputFormat, error) {
+	for outputFormat := TableOutput; outputFormat <= NdjsonOutput; outputFormat++ {
+		if outputFormat.String() == str {
+			return outputFormat, nil
+		}
+	}
+	return TableOutput, fmt.Errorf("unknown output format %v, should be table, json or ndjson", str)
+}
+
+// Set implements flag.Value.
+func (f *OutputFormat) Set(str string) error {
+	outputFormat, err := NewOutputFormat(str)
+	if err != nil {
+		return err
+	}
+	*f = outputFormat
+	return nil
+}
+
+// WriteResults writes given results in given format. writeTable is called with a tab separated writer in table format,
+// flushed afterwards.
+func WriteResults[T any](w io.Writer, outputFormat OutputFormat, results []T, writeTable func(tw io.Writer, results []T)) error {
+	switch outputFormat {
+	case JsonOutput:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", " ")
+		err := encoder.Encode(results)
+		if err != nil {
+			return fmt.Errorf("error from encoder.Encode: %w", err)
+		}
+	case NdjsonOutput:
+		encoder := json.NewEncoder(w)
+		for _, result := range results {
+			err := encoder.Encode(result)
+			if err != nil {
+				return fmt.Errorf("error from encoder.Encode: %w", err)
+			}
+		}
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		writeTable(tw, results)
+		err := tw.Flush()
+		if err != nil {
+			return fmt.Errorf("error from tw.Flush: %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteResult writes a single result in given format, with message in table format.
+func WriteResult(w io.Writer, outputFormat OutputFormat, result any, message string) error {
+	var err error
+	switch outputFormat {
+	case JsonOutput:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", " ")
+		err = encoder.Encode(result)
+	case NdjsonOutput:
+		err = json.NewEncoder(w).Encode(result)
+	default:
+		_, err = fmt.Fprintf(w, "%v\n", message)
+	}
+	if err != nil {
+		return fmt.Errorf("error while writing result: %w", err)
+	}
+	return nil
+}
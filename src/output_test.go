@@ -0,0 +1,104 @@
+package tga
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewOutputFormat(t *testing.T) {
+	for _, outputFormat := range []OutputFormat{TableOutput, JsonOutput, NdjsonOutput} {
+		parsedOutputFormat, err := NewOutputFormat(outputFormat.String())
+		if err != nil || parsedOutputFormat != outputFormat {
+			t.Fatalf("expected %v, got %v (%v)", outputFormat, parsedOutputFormat, err)
+		}
+	}
+	var outputFormat OutputFormat
+	err := outputFormat.Set("yaml")
+	if err == nil {
+		t.Fatalf("expected error for unknown output format")
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	orders := []Order{
+		{Id: "1", StoreName: "Bakery", Quantity: 2, Price: Price{Amount: 399, NbDecimals: 2, CurrencyCode: "EUR"}},
+		{Id: "2", StoreName: "Sushi", Quantity: 1, CancelUntil: time.Date(2023, time.May, 21, 19, 0, 0, 0, time.UTC)},
+	}
+	writeTable := func(tw io.Writer, orders []Order) {}
+
+	var buffer bytes.Buffer
+	err := WriteResults(&buffer, NdjsonOutput, orders, writeTable)
+	if err != nil {
+		t.Fatalf("error from WriteResults: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected one line per order, got %q", buffer.String())
+	}
+	var parsedOrder map[string]interface{}
+	err = json.Unmarshal([]byte(lines[0]), &parsedOrder)
+	if err != nil {
+		t.Fatalf("error from json.Unmarshal: %v", err)
+	}
+	for _, field := range []string{"id", "storeName", "quantity", "price", "pickupDetails", "cancelUntil"} {
+		if _, hasField := parsedOrder[field]; !hasField {
+			t.Fatalf("expected field %v in %v", field, lines[0])
+		}
+	}
+	if price := parsedOrder["price"].(map[string]interface{}); price["amount"] != 399.0 || price["currencyCode"] != "EUR" {
+		t.Fatalf("unexpected price %v", price)
+	}
+
+	buffer.Reset()
+	err = WriteResults(&buffer, JsonOutput, orders, writeTable)
+	if err != nil {
+		t.Fatalf("error from WriteResults: %v", err)
+	}
+	var parsedOrders []Order
+	err = json.Unmarshal(buffer.Bytes(), &parsedOrders)
+	if err != nil || len(parsedOrders) != 2 || !parsedOrders[1].CancelUntil.Equal(orders[1].CancelUntil) {
+		t.Fatalf("unexpected json output %v (%v)", buffer.String(), err)
+	}
+
+	buffer.Reset()
+	err = WriteResults(&buffer, JsonOutput, []PaymentMethod{{Id: "1", AdyenApiPayload: "secret", PaymentType: CreditCard}}, writePaymentMethodsTable)
+	if err != nil {
+		t.Fatalf("error from WriteResults: %v", err)
+	}
+	if strings.Contains(buffer.String(), "secret") || !strings.Contains(buffer.String(), `"paymentType": "CREDITCARD"`) {
+		t.Fatalf("unexpected payment methods json %v", buffer.String())
+	}
+
+	buffer.Reset()
+	err = WriteResults(&buffer, NdjsonOutput, []Store{{Id: "1", Badges: []Badge{{BadgeType: "SERVICE_RATING_SCORE", RatingGroup: "LOVED", UserCount: 7}}}}, writeStoresTable)
+	if err != nil {
+		t.Fatalf("error from WriteResults: %v", err)
+	}
+	if !strings.Contains(buffer.String(), `"badgeType":"SERVICE_RATING_SCORE","ratingGroup":"LOVED"`) || !strings.Contains(buffer.String(), `"userCount":7`) {
+		t.Fatalf("unexpected stores json %v", buffer.String())
+	}
+}
+
+func TestLoadOrderHistoryWithGoFieldNames(t *testing.T) {
+	// files written before the json field names were defined
+	filePath := filepath.Join(t.TempDir(), kOrderHistoryFileName)
+	err := os.WriteFile(filePath, []byte(`{"ant@email.com": [{"Id": "1", "StoreName": "Bakery", "State": "REDEEMED", "Quantity": 2,
+		"Price": {"Amount": 399, "NbDecimals": 2, "CurrencyCode": "EUR"}}]}`), 0600)
+	if err != nil {
+		t.Fatalf("error from os.WriteFile: %v", err)
+	}
+
+	orderHistory, err := LoadOrderHistory(filePath)
+	if err != nil {
+		t.Fatalf("error from LoadOrderHistory: %v", err)
+	}
+	if report := orderHistory.Report("ant@email.com"); report.NbBags != 2 || len(report.Currencies) != 1 || report.Currencies[0].Spent != 2*3.99 || report.Currencies[0].CurrencyCode != "EUR" {
+		t.Fatalf("unexpected report %v", report)
+	}
+}
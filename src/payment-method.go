@@ -6,14 +6,14 @@ import (
 )
 
 type PaymentMethod struct {
-	Id                string
-	InternalType      string
-	AdyenApiPayload   string
-	DisplayValue      string
-	SavePaymentMethod string // TODO: check what it is
-	PaymentProvider   PaymentProvider
-	PaymentType       PaymentType
-	IsPreferred       bool
+	Id                string          `json:"id"`
+	InternalType      string          `json:"internalType"`
+	AdyenApiPayload   string          `json:"-"`
+	DisplayValue      string          `json:"displayValue"`
+	SavePaymentMethod string          `json:"savePaymentMethod"` // TODO: check what it is
+	PaymentProvider   PaymentProvider `json:"paymentProvider"`
+	PaymentType       PaymentType     `json:"paymentType"`
+	IsPreferred       bool            `json:"isPreferred"`
 }
 
 func (p PaymentMethod) String() string {
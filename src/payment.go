@@ -72,6 +72,91 @@ func (p PaymentProvider) GetAuthorizationPayloadType() string {
 	return "unknown"
 }
 
+type PaymentState int
+
+const (
+	AuthorizationInitiated PaymentState = iota
+	Authorized
+	Captured
+	PaymentFailed
+	PaymentCancelled
+	Refunded
+	PaymentStateUnknown // state not known by this version, considered as still pending
+)
+
+func (p PaymentState) String() string {
+	switch p {
+	case AuthorizationInitiated:
+		return "AUTHORIZATION_INITIATED"
+	case Authorized:
+		return "AUTHORIZED"
+	case Captured:
+		return "CAPTURED"
+	case PaymentFailed:
+		return "FAILED"
+	case PaymentCancelled:
+		return "CANCELLED"
+	case Refunded:
+		return "REFUNDED"
+	case PaymentStateUnknown:
+		return "UNKNOWN"
+	}
+	return "unknown"
+}
+
+// NewPaymentState returns the payment state named str, and PaymentStateUnknown with an error if it is unknown.
+func NewPaymentState(str string) (PaymentState, error) {
+	if str == "AUTHORIZATION_INITIATED" {
+		return AuthorizationInitiated, nil
+	}
+	if str == "AUTHORIZED" {
+		return Authorized, nil
+	}
+	if str == "CAPTURED" {
+		return Captured, nil
+	}
+	if str == "FAILED" {
+		return PaymentFailed, nil
+	}
+	if str == "CANCELLED" {
+		return PaymentCancelled, nil
+	}
+	if str == "REFUNDED" {
+		return Refunded, nil
+	}
+	return PaymentStateUnknown, fmt.Errorf("unknown payment state %v", str)
+}
+
+func (p PaymentState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON accepts unknown states, as new ones may be introduced by too good to go at any time.
+func (p *PaymentState) UnmarshalJSON(b []byte) error {
+	var str string
+	err := json.Unmarshal(b, &str)
+	if err != nil {
+		return fmt.Errorf("error from json.Unmarshal: %w", err)
+	}
+	paymentState, err := NewPaymentState(str)
+	if err != nil {
+		glog.Printf("error from NewPaymentState, considered as pending: %v\n", err)
+	}
+	*p = paymentState
+	return nil
+}
+
+// IsSuccessful returns true if the payment has been accepted.
+func (p PaymentState) IsSuccessful() bool {
+	return p == Authorized || p == Captured
+}
+
+// IsTerminal returns true if the payment will not evolve anymore without user action.
+// An unknown state is not terminal, as it may still be accepted.
+func (p PaymentState) IsTerminal() bool {
+	return p != AuthorizationInitiated && p != PaymentStateUnknown
+}
+
 type PaymentType int
 
 const (
@@ -0,0 +1,195 @@
+package tga
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	kDefaultCancelDeadlineWarning = 15 * time.Minute
+
+	kSentRemindersFileName = "sentReminders.json"
+)
+
+// beforeCancelDeadline returns the offsets of the warnings before the cancellation deadline, 15 minutes if not configured.
+func (pickupRemindersConfig *PickupRemindersConfig) beforeCancelDeadline() []Duration {
+	if pickupRemindersConfig.BeforeCancelDeadline == nil {
+		return []Duration{{Duration: kDefaultCancelDeadlineWarning}}
+	}
+	return pickupRemindersConfig.BeforeCancelDeadline
+}
+
+// PickupReminder computes the reminders of the opened orders, each of them being sent only once per order and offset.
+// The sent reminders are kept in a file, so that a restart does not send them again.
+type PickupReminder struct {
+	config        *PickupRemindersConfig
+	filePath      string
+	sentReminders map[string]time.Time // reminder key -> end of the pickup window of the order
+}
+
+func NewPickupReminder(config *PickupRemindersConfig) *PickupReminder {
+	return &PickupReminder{
+		config:        config,
+		sentReminders: make(map[string]time.Time),
+	}
+}
+
+// LoadPickupReminder creates a pickup reminder persisting its sent reminders in given file, loading them from it if it exists.
+func LoadPickupReminder(config *PickupRemindersConfig, filePath string) (*PickupReminder, error) {
+	pickupReminder := NewPickupReminder(config)
+	pickupReminder.filePath = filePath
+
+	fileData, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return pickupReminder, nil
+	}
+	if err != nil {
+		return pickupReminder, fmt.Errorf("error from os.ReadFile: %w", err)
+	}
+
+	err = json.Unmarshal(fileData, &pickupReminder.sentReminders)
+	if err != nil {
+		return pickupReminder, fmt.Errorf("error from json.Unmarshal: %w", err)
+	}
+	if pickupReminder.sentReminders == nil {
+		pickupReminder.sentReminders = make(map[string]time.Time)
+	}
+	return pickupReminder, nil
+}
+
+func (pickupReminder *PickupReminder) save() error {
+	if len(pickupReminder.filePath) == 0 {
+		return nil
+	}
+	fileData, err := json.MarshalIndent(pickupReminder.sentReminders, "", " ")
+	if err != nil {
+		return fmt.Errorf("error from json.MarshalIndent: %w", err)
+	}
+	err = writeFileAtomically(pickupReminder.filePath, fileData)
+	if err != nil {
+		return fmt.Errorf("error from writeFileAtomically: %w", err)
+	}
+	return nil
+}
+
+// DueReminder is a reminder to send, to be marked as sent with MarkSent once it has been written successfully.
+type DueReminder struct {
+	Message   string
+	keys      []string
+	pickupEnd time.Time
+}
+
+// MarkSent records that given reminder has been sent, so that it is not due anymore.
+func (pickupReminder *PickupReminder) MarkSent(reminder DueReminder) error {
+	for _, key := range reminder.keys {
+		pickupReminder.sentReminders[key] = reminder.pickupEnd
+	}
+	return pickupReminder.save()
+}
+
+func reminderKey(order *Order, eventName string, offset time.Duration) string {
+	return fmt.Sprintf("%v/%v/%v", order.Id, eventName, offset)
+}
+
+// FormatPickupReminder renders a reminder of given order, with the pickup window in the time zone of the store.
+func FormatPickupReminder(order *Order, eventName string, remainingDuration time.Duration) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "reminder: pickup of %v bag(s) at %v %v in %v", order.Quantity, order.StoreName, eventName, remainingDuration.Round(time.Minute))
+	fmt.Fprintf(&sb, "\npickup %v", FormatPickupWindow(order.PickupDetails.FromGMT, order.PickupDetails.ToGMT, order.TimeZone))
+	if len(order.PickupDetails.Address) > 0 {
+		fmt.Fprintf(&sb, "\nat %v", order.PickupDetails.Address)
+	}
+	return sb.String()
+}
+
+// FormatCancelDeadlineReminder renders a warning that given order will not be cancellable anymore after given duration.
+func FormatCancelDeadlineReminder(order *Order, remainingDuration time.Duration) string {
+	location, err := time.LoadLocation(order.TimeZone)
+	if err != nil || len(order.TimeZone) == 0 {
+		location = time.UTC
+	}
+	return fmt.Sprintf("reminder: order # %v of %v bag(s) at %v can only be cancelled until %v (in %v), reply 'cancel %v' to cancel it",
+		order.Id, order.Quantity, order.StoreName, order.CancelUntil.In(location).Format("15:04"), remainingDuration.Round(time.Minute), order.Id)
+}
+
+// DueReminders returns the reminders to send now for given orders, which stay due until they are marked as sent.
+// A reminder is due from its offset before the start (or the end) of the pickup window, or the cancellation deadline, until this time.
+func (pickupReminder *PickupReminder) DueReminders(orders []Order, now time.Time) []DueReminder {
+	nbSentReminders := len(pickupReminder.sentReminders)
+	for key, pickupEnd := range pickupReminder.sentReminders {
+		if now.After(pickupEnd) {
+			delete(pickupReminder.sentReminders, key)
+		}
+	}
+	if len(pickupReminder.sentReminders) != nbSentReminders {
+		err := pickupReminder.save()
+		if err != nil {
+			glog.Printf("error from pickupReminder.save: %v\n", err)
+		}
+	}
+
+	dueReminders := []DueReminder{}
+	for orderPos := range orders {
+		order := &orders[orderPos]
+		if order.PickupDetails.FromGMT.IsZero() {
+			continue
+		}
+		for _, reminder := range []struct {
+			eventName string
+			eventTime time.Time
+			offsets   []Duration
+			format    func(order *Order, remainingDuration time.Duration) string
+		}{
+			{"starts", order.PickupDetails.FromGMT, pickupReminder.config.BeforeStart, func(order *Order, remainingDuration time.Duration) string {
+				return FormatPickupReminder(order, "starts", remainingDuration)
+			}},
+			{"ends", order.PickupDetails.ToGMT, pickupReminder.config.BeforeEnd, func(order *Order, remainingDuration time.Duration) string {
+				return FormatPickupReminder(order, "ends", remainingDuration)
+			}},
+			{"cancelDeadline", order.CancelUntil, pickupReminder.config.beforeCancelDeadline(), FormatCancelDeadlineReminder},
+		} {
+			// when several offsets become due at once, a single reminder is sent for all of them
+			newlyDueKeys := []string{}
+			for _, offset := range reminder.offsets {
+				key := reminderKey(order, reminder.eventName, offset.Duration)
+				if _, sent := pickupReminder.sentReminders[key]; sent {
+					continue
+				}
+				if !now.Before(reminder.eventTime.Add(-offset.Duration)) && now.Before(reminder.eventTime) {
+					newlyDueKeys = append(newlyDueKeys, key)
+				}
+			}
+			if len(newlyDueKeys) == 0 {
+				continue
+			}
+			dueReminders = append(dueReminders, DueReminder{
+				Message:   reminder.format(order, reminder.eventTime.Sub(now)),
+				keys:      newlyDueKeys,
+				pickupEnd: order.PickupDetails.ToGMT,
+			})
+		}
+	}
+	return dueReminders
+}
+
+// sendPickupReminders writes the due reminders of the last known opened orders to the sender.
+// A reminder which could not be written stays due, to be sent again at next loop.
+func (ant *Ant) sendPickupReminders() {
+	if ant.pickupReminder == nil {
+		return
+	}
+	for _, dueReminder := range ant.pickupReminder.DueReminders(ant.openedOrders, time.Now()) {
+		_, err := ant.sender.Write([]byte(dueReminder.Message))
+		if err != nil {
+			glog.Printf("error from sender.Write: %v\n", err)
+			continue
+		}
+		err = ant.pickupReminder.MarkSent(dueReminder)
+		if err != nil {
+			glog.Printf("error from pickupReminder.MarkSent: %v\n", err)
+		}
+	}
+}
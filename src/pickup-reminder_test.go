@@ -0,0 +1,147 @@
+package tga
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sendDueReminders returns the messages of the due reminders, marking them as sent.
+func sendDueReminders(pickupReminder *PickupReminder, orders []Order, now time.Time) []string {
+	messages := []string{}
+	for _, dueReminder := range pickupReminder.DueReminders(orders, now) {
+		messages = append(messages, dueReminder.Message)
+		pickupReminder.MarkSent(dueReminder)
+	}
+	return messages
+}
+
+func TestPickupReminderDueReminders(t *testing.T) {
+	pickupFrom := time.Date(2023, time.May, 21, 17, 0, 0, 0, time.UTC)
+	orders := []Order{
+		{
+			Id:        "order-1",
+			StoreName: "Bakery",
+			Quantity:  2,
+			TimeZone:  "Europe/Paris",
+			PickupDetails: PickupDetails{
+				Address: "1 rue de la Paix",
+				FromGMT: pickupFrom,
+				ToGMT:   pickupFrom.Add(30 * time.Minute),
+			},
+		},
+	}
+	pickupReminder := NewPickupReminder(&PickupRemindersConfig{
+		BeforeStart: []Duration{{Duration: time.Hour}, {Duration: 15 * time.Minute}},
+		BeforeEnd:   []Duration{{Duration: 10 * time.Minute}},
+	})
+
+	for _, testCase := range []struct {
+		now              time.Time
+		expectedMessages []string
+	}{
+		{pickupFrom.Add(-2 * time.Hour), []string{}},
+		{pickupFrom.Add(-time.Hour), []string{"reminder: pickup of 2 bag(s) at Bakery starts in 1h0m0s\npickup Sun 21 May 19:00 - 19:30 (Europe/Paris)\nat 1 rue de la Paix"}},
+		{pickupFrom.Add(-30 * time.Minute), []string{}},
+		{pickupFrom.Add(-10 * time.Minute), []string{"reminder: pickup of 2 bag(s) at Bakery starts in 10m0s\npickup Sun 21 May 19:00 - 19:30 (Europe/Paris)\nat 1 rue de la Paix"}},
+		{pickupFrom.Add(-5 * time.Minute), []string{}},
+		{pickupFrom.Add(25 * time.Minute), []string{"reminder: pickup of 2 bag(s) at Bakery ends in 5m0s\npickup Sun 21 May 19:00 - 19:30 (Europe/Paris)\nat 1 rue de la Paix"}},
+		{pickupFrom.Add(28 * time.Minute), []string{}},
+		{pickupFrom.Add(time.Hour), []string{}},
+	} {
+		messages := sendDueReminders(pickupReminder, orders, testCase.now)
+		if strings.Join(messages, "|") != strings.Join(testCase.expectedMessages, "|") {
+			t.Fatalf("at %v, expected reminders %q, got %q", testCase.now, testCase.expectedMessages, messages)
+		}
+	}
+
+	if len(pickupReminder.sentReminders) != 0 {
+		t.Fatalf("expected sent reminders to be forgotten after the pickup window, got %v", pickupReminder.sentReminders)
+	}
+}
+
+func TestPickupReminderSeveralOffsetsDueAtOnce(t *testing.T) {
+	pickupFrom := time.Date(2023, time.May, 21, 17, 0, 0, 0, time.UTC)
+	orders := []Order{
+		{Id: "order-1", StoreName: "Bakery", Quantity: 1, PickupDetails: PickupDetails{FromGMT: pickupFrom, ToGMT: pickupFrom.Add(time.Hour)}},
+		{Id: "order-2", StoreName: "Grocery", Quantity: 1, PickupDetails: PickupDetails{FromGMT: pickupFrom.Add(3 * time.Hour), ToGMT: pickupFrom.Add(4 * time.Hour)}},
+	}
+	pickupReminder := NewPickupReminder(&PickupRemindersConfig{
+		BeforeStart: []Duration{{Duration: time.Hour}, {Duration: 15 * time.Minute}},
+	})
+
+	messages := sendDueReminders(pickupReminder, orders, pickupFrom.Add(-5*time.Minute))
+	if len(messages) != 1 || !strings.HasPrefix(messages[0], "reminder: pickup of 1 bag(s) at Bakery starts in 5m0s\npickup Sun 21 May 17:00 - 18:00 (UTC)") {
+		t.Fatalf("expected a single reminder for the first order, got %q", messages)
+	}
+	messages = sendDueReminders(pickupReminder, orders, pickupFrom.Add(-time.Minute))
+	if len(messages) != 0 {
+		t.Fatalf("expected no more reminder, got %q", messages)
+	}
+}
+
+func TestPickupReminderCancelDeadline(t *testing.T) {
+	pickupFrom := time.Date(2023, time.May, 21, 17, 0, 0, 0, time.UTC)
+	orders := []Order{
+		{
+			Id:            "order-1",
+			StoreName:     "Bakery",
+			Quantity:      1,
+			TimeZone:      "Europe/Paris",
+			PickupDetails: PickupDetails{FromGMT: pickupFrom, ToGMT: pickupFrom.Add(time.Hour)},
+			CancelUntil:   pickupFrom.Add(-2 * time.Hour),
+		},
+	}
+	pickupReminder := NewPickupReminder(&PickupRemindersConfig{})
+
+	messages := sendDueReminders(pickupReminder, orders, pickupFrom.Add(-2*time.Hour-20*time.Minute))
+	if len(messages) != 0 {
+		t.Fatalf("expected no warning yet, got %q", messages)
+	}
+	messages = sendDueReminders(pickupReminder, orders, pickupFrom.Add(-2*time.Hour-10*time.Minute))
+	expectedMessage := "reminder: order # order-1 of 1 bag(s) at Bakery can only be cancelled until 17:00 (in 10m0s), reply 'cancel order-1' to cancel it"
+	if len(messages) != 1 || messages[0] != expectedMessage {
+		t.Fatalf("expected warning %q, got %q", expectedMessage, messages)
+	}
+	messages = sendDueReminders(pickupReminder, orders, pickupFrom.Add(-2*time.Hour-5*time.Minute))
+	if len(messages) != 0 {
+		t.Fatalf("expected a single warning, got %q", messages)
+	}
+}
+
+func TestPickupReminderSentOnceWritten(t *testing.T) {
+	now := time.Now()
+	orders := []Order{
+		{Id: "order-1", StoreName: "Bakery", Quantity: 1, PickupDetails: PickupDetails{FromGMT: now.Add(10 * time.Minute), ToGMT: now.Add(time.Hour)}},
+	}
+	config := &PickupRemindersConfig{BeforeStart: []Duration{{Duration: 15 * time.Minute}}, BeforeCancelDeadline: []Duration{}}
+	filePath := filepath.Join(t.TempDir(), kSentRemindersFileName)
+	pickupReminder, err := LoadPickupReminder(config, filePath)
+	if err != nil {
+		t.Fatalf("error from LoadPickupReminder: %v", err)
+	}
+
+	ant := &Ant{sender: failingWriter{}, pickupReminder: pickupReminder, openedOrders: orders}
+	ant.sendPickupReminders()
+	if len(pickupReminder.sentReminders) != 0 {
+		t.Fatalf("expected a reminder not written to stay due, got sent reminders %v", pickupReminder.sentReminders)
+	}
+
+	sender := &cancelAfterWriter{nbMaxMessages: 10, cancel: func() {}}
+	ant.sender = sender
+	ant.sendPickupReminders()
+	ant.sendPickupReminders()
+	if len(sender.messages) != 1 {
+		t.Fatalf("expected a single reminder, got %q", sender.messages)
+	}
+
+	// sent reminders are kept after a restart
+	pickupReminder, err = LoadPickupReminder(config, filePath)
+	if err != nil {
+		t.Fatalf("error from LoadPickupReminder: %v", err)
+	}
+	if dueReminders := pickupReminder.DueReminders(orders, now); len(dueReminders) != 0 {
+		t.Fatalf("expected no due reminder after reload, got %v", dueReminders)
+	}
+}
@@ -6,9 +6,9 @@ import (
 )
 
 type Price struct {
-	Amount       int
-	NbDecimals   int
-	CurrencyCode string
+	Amount       int    `json:"amount"`
+	NbDecimals   int    `json:"nbDecimals"`
+	CurrencyCode string `json:"currencyCode"`
 }
 
 func (p Price) FloatAmount() float64 {
@@ -0,0 +1,295 @@
+package tga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	kReservationsFileName = "reservations.json"
+
+	kReservedOrderState = "RESERVED"
+)
+
+// TrackedReservation is an order reserved by the ant which is not paid yet.
+type TrackedReservation struct {
+	OrderId      string    `json:"orderId"`
+	StoreName    string    `json:"storeName"`
+	ReservedTime time.Time `json:"reservedTime"`
+	Email        string    `json:"email"` // account which made the reservation, empty for the current one
+}
+
+func (r *TrackedReservation) String() string {
+	return fmt.Sprintf("order # %v in %v reserved at %v", r.OrderId, r.StoreName, r.ReservedTime.Format(time.DateTime))
+}
+
+// ReservationTracker keeps the unpaid reservations made by the ant in a file, to be able to cancel them even after a restart.
+type ReservationTracker struct {
+	filePath     string
+	reservations []TrackedReservation
+}
+
+// LoadReservationTracker creates a tracker persisted in given file, loading its reservations from it if it exists.
+func LoadReservationTracker(filePath string) (*ReservationTracker, error) {
+	reservationTracker := &ReservationTracker{filePath: filePath}
+
+	fileData, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return reservationTracker, nil
+	}
+	if err != nil {
+		return reservationTracker, fmt.Errorf("error from os.ReadFile: %w", err)
+	}
+
+	err = json.Unmarshal(fileData, &reservationTracker.reservations)
+	if err != nil {
+		return reservationTracker, fmt.Errorf("error from json.Unmarshal: %w", err)
+	}
+	return reservationTracker, nil
+}
+
+func (reservationTracker *ReservationTracker) save() error {
+	if len(reservationTracker.filePath) == 0 {
+		return nil
+	}
+	fileData, err := json.MarshalIndent(reservationTracker.reservations, "", " ")
+	if err != nil {
+		return fmt.Errorf("error from json.MarshalIndent: %w", err)
+	}
+	err = writeFileAtomically(reservationTracker.filePath, fileData)
+	if err != nil {
+		return fmt.Errorf("error from writeFileAtomically: %w", err)
+	}
+	return nil
+}
+
+// Reservations returns the tracked unpaid reservations, oldest first.
+func (reservationTracker *ReservationTracker) Reservations() []TrackedReservation {
+	return append([]TrackedReservation{}, reservationTracker.reservations...)
+}
+
+// Add starts tracking given reservation.
+func (reservationTracker *ReservationTracker) Add(reservation TrackedReservation) error {
+	reservationTracker.reservations = append(reservationTracker.reservations, reservation)
+	return reservationTracker.save()
+}
+
+// Remove stops tracking given order, because it has been paid or cancelled. Returns true if it was tracked.
+func (reservationTracker *ReservationTracker) Remove(orderId string) (bool, error) {
+	for reservationPos, reservation := range reservationTracker.reservations {
+		if reservation.OrderId == orderId {
+			reservationTracker.reservations = append(reservationTracker.reservations[:reservationPos], reservationTracker.reservations[reservationPos+1:]...)
+			return true, reservationTracker.save()
+		}
+	}
+	return false, nil
+}
+
+// RemovePaid stops tracking the orders which are opened and not in reserved state anymore.
+func (reservationTracker *ReservationTracker) RemovePaid(openedOrders []Order) error {
+	for _, order := range openedOrders {
+		if order.State == kReservedOrderState {
+			continue
+		}
+		removed, err := reservationTracker.Remove(order.Id)
+		if err != nil {
+			return err
+		}
+		if removed {
+			glog.Printf("reserved order %v has been paid\n", order.Id)
+		}
+	}
+	return nil
+}
+
+// Expired returns the reservations made more than gracePeriod ago.
+func (reservationTracker *ReservationTracker) Expired(now time.Time, gracePeriod time.Duration) []TrackedReservation {
+	expiredReservations := []TrackedReservation{}
+	for _, reservation := range reservationTracker.reservations {
+		if now.Sub(reservation.ReservedTime) > gracePeriod {
+			expiredReservations = append(expiredReservations, reservation)
+		}
+	}
+	return expiredReservations
+}
+
+// ParseCancelCommand parses a message received from the user. "cancel" targets all the unpaid reservations,
+// "cancel <orderId>" a single unpaid reservation or opened order. Returns false if the message is not a cancel command.
+func ParseCancelCommand(message string) (string, bool) {
+	fields := strings.Fields(message)
+	if len(fields) == 0 || len(fields) > 2 || !strings.EqualFold(fields[0], "cancel") {
+		return "", false
+	}
+	if len(fields) == 2 {
+		return fields[1], true
+	}
+	return "", true
+}
+
+// isOfCurrentAccount returns whether given reservation has been made with the current account of the client.
+func (ant *Ant) isOfCurrentAccount(reservation TrackedReservation) bool {
+	return len(reservation.Email) == 0 || reservation.Email == ant.client.emailAccount()
+}
+
+// cancelReservation cancels given reservation, stops tracking it and notifies it.
+func (ant *Ant) cancelReservation(ctx context.Context, reservation TrackedReservation, reason string) error {
+	if !ant.isOfCurrentAccount(reservation) {
+		return fmt.Errorf("reservation %v made with account %v while current account is %v", reservation.OrderId, reservation.Email, ant.client.emailAccount())
+	}
+	err := ant.client.CancelOrder(ctx, reservation.OrderId)
+	if errors.Is(err, ErrMalformedResponse) {
+		// the server refuses to cancel this order, no need to try again
+		glog.Printf("stop tracking reservation %v: %v\n", reservation.OrderId, err)
+		_, removeErr := ant.reservationTracker.Remove(reservation.OrderId)
+		if removeErr != nil {
+			glog.Printf("error from reservationTracker.Remove: %v\n", removeErr)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error from client.CancelOrder: %w", err)
+	}
+
+	_, err = ant.reservationTracker.Remove(reservation.OrderId)
+	if err != nil {
+		glog.Printf("error from reservationTracker.Remove: %v\n", err)
+	}
+
+	_, err = ant.sender.Write([]byte(fmt.Sprintf("cancelled %v: %v", reservation.String(), reason)))
+	if err != nil {
+		glog.Printf("error from sender.Write: %v\n", err)
+	}
+	return nil
+}
+
+// cancelUnpaidReservations cancels the reservations still unpaid after the grace period,
+// and the ones targeted by the cancel commands received from the user.
+func (ant *Ant) cancelUnpaidReservations(ctx context.Context) {
+	if ant.reservationTracker == nil {
+		return
+	}
+
+	gracePeriod := ant.client.Config.PaymentConfig.UnpaidReservationGracePeriod.Duration
+	if gracePeriod > 0 {
+		ant.cancelExpiredReservations(ctx, gracePeriod)
+	}
+
+	for {
+		select {
+		case message := <-ant.commands:
+			ant.handleCancelCommand(ctx, message)
+		default:
+			return
+		}
+	}
+}
+
+// cancelExpiredReservations cancels the reservations of the current account made more than gracePeriod ago,
+// if the opened orders listed right before show that they are still unpaid.
+// The reservations of other accounts are checked once their account is in use again.
+func (ant *Ant) cancelExpiredReservations(ctx context.Context, gracePeriod time.Duration) {
+	expiredReservations := []TrackedReservation{}
+	for _, reservation := range ant.reservationTracker.Expired(time.Now(), gracePeriod) {
+		if ant.isOfCurrentAccount(reservation) {
+			expiredReservations = append(expiredReservations, reservation)
+		} else {
+			glog.Printf("reservation %v of account %v will be checked when it is in use again\n", reservation.OrderId, reservation.Email)
+		}
+	}
+	if len(expiredReservations) == 0 {
+		return
+	}
+
+	openedOrders, err := ant.client.ListOpenedOrders(ctx)
+	if err != nil {
+		glog.Printf("error from client.ListOpenedOrders: %v\n", err)
+		return
+	}
+	openedOrderStates := make(map[string]string, len(openedOrders))
+	for _, order := range openedOrders {
+		openedOrderStates[order.Id] = order.State
+	}
+
+	for _, reservation := range expiredReservations {
+		state, isOpened := openedOrderStates[reservation.OrderId]
+		if isOpened && state == kReservedOrderState {
+			err = ant.cancelReservation(ctx, reservation, fmt.Sprintf("still unpaid after %v", gracePeriod))
+			if err != nil {
+				glog.Printf("error from ant.cancelReservation: %v\n", err)
+			}
+			continue
+		}
+
+		if isOpened {
+			glog.Printf("reserved order %v has been paid\n", reservation.OrderId)
+		} else {
+			glog.Printf("reserved order %v is not opened anymore\n", reservation.OrderId)
+		}
+		_, err = ant.reservationTracker.Remove(reservation.OrderId)
+		if err != nil {
+			glog.Printf("error from reservationTracker.Remove: %v\n", err)
+		}
+	}
+}
+
+func (ant *Ant) handleCancelCommand(ctx context.Context, message string) {
+	orderId, isCancelCommand := ParseCancelCommand(message)
+	if !isCancelCommand {
+		return
+	}
+
+	if len(orderId) > 0 && !ant.isTrackedReservation(orderId) {
+		ant.cancelOpenedOrder(ctx, orderId)
+		return
+	}
+
+	nbCancelledReservations := 0
+	for _, reservation := range ant.reservationTracker.Reservations() {
+		if len(orderId) > 0 && orderId != reservation.OrderId {
+			continue
+		}
+		err := ant.cancelReservation(ctx, reservation, "cancel requested")
+		if err != nil {
+			glog.Printf("error from ant.cancelReservation: %v\n", err)
+		} else {
+			nbCancelledReservations++
+		}
+	}
+
+	if nbCancelledReservations == 0 {
+		_, err := ant.sender.Write([]byte("no unpaid reservation cancelled"))
+		if err != nil {
+			glog.Printf("error from sender.Write: %v\n", err)
+		}
+	}
+}
+
+func (ant *Ant) isTrackedReservation(orderId string) bool {
+	for _, reservation := range ant.reservationTracker.Reservations() {
+		if reservation.OrderId == orderId {
+			return true
+		}
+	}
+	return false
+}
+
+// cancelOpenedOrder cancels an opened order which is not an unpaid reservation of the ant, if it is still cancellable.
+func (ant *Ant) cancelOpenedOrder(ctx context.Context, orderId string) {
+	message := ""
+	order, err := ant.client.CancelOpenedOrder(ctx, orderId)
+	if err != nil {
+		glog.Printf("error from client.CancelOpenedOrder: %v\n", err)
+		message = fmt.Sprintf("order %v not cancelled: %v", orderId, err)
+	} else {
+		message = fmt.Sprintf("cancelled order # %v of %v bag(s) at %v", order.Id, order.Quantity, order.StoreName)
+	}
+
+	_, err = ant.sender.Write([]byte(message))
+	if err != nil {
+		glog.Printf("error from sender.Write: %v\n", err)
+	}
+}
@@ -0,0 +1,172 @@
+package tga
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func trackedOrderIds(reservations []TrackedReservation) []string {
+	orderIds := make([]string, len(reservations))
+	for reservationPos, reservation := range reservations {
+		orderIds[reservationPos] = reservation.OrderId
+	}
+	return orderIds
+}
+
+func TestReservationTracker(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), kReservationsFileName)
+	now := time.Date(2023, time.May, 21, 19, 0, 0, 0, time.UTC)
+
+	reservationTracker, err := LoadReservationTracker(filePath)
+	if err != nil {
+		t.Fatalf("error from LoadReservationTracker: %v", err)
+	}
+	for orderPos, orderId := range []string{"order-1", "order-2", "order-3"} {
+		err = reservationTracker.Add(TrackedReservation{
+			OrderId:      orderId,
+			StoreName:    "Bakery",
+			ReservedTime: now.Add(time.Duration(orderPos) * 10 * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("error from reservationTracker.Add: %v", err)
+		}
+	}
+
+	err = reservationTracker.RemovePaid([]Order{
+		{Id: "order-1", State: "ACTIVE"},
+		{Id: "order-2", State: kReservedOrderState},
+		{Id: "order-4", State: "ACTIVE"},
+	})
+	if err != nil {
+		t.Fatalf("error from reservationTracker.RemovePaid: %v", err)
+	}
+
+	reservationTracker, err = LoadReservationTracker(filePath)
+	if err != nil {
+		t.Fatalf("error from LoadReservationTracker: %v", err)
+	}
+	if expectedIds := []string{"order-2", "order-3"}; !reflect.DeepEqual(trackedOrderIds(reservationTracker.Reservations()), expectedIds) {
+		t.Fatalf("expected reservations %v, got %v", expectedIds, trackedOrderIds(reservationTracker.Reservations()))
+	}
+
+	expiredReservations := reservationTracker.Expired(now.Add(25*time.Minute), 10*time.Minute)
+	if expectedIds := []string{"order-2"}; !reflect.DeepEqual(trackedOrderIds(expiredReservations), expectedIds) {
+		t.Fatalf("expected expired reservations %v, got %v", expectedIds, trackedOrderIds(expiredReservations))
+	}
+}
+
+func TestParseCancelCommand(t *testing.T) {
+	testCases := []struct {
+		message           string
+		expectedOrderId   string
+		expectedIsCommand bool
+	}{
+		{"cancel", "", true},
+		{" Cancel ", "", true},
+		{"cancel order-1", "order-1", true},
+		{"cancel it please", "", false},
+		{"hello", "", false},
+		{"", "", false},
+	}
+	for _, testCase := range testCases {
+		orderId, isCommand := ParseCancelCommand(testCase.message)
+		if orderId != testCase.expectedOrderId || isCommand != testCase.expectedIsCommand {
+			t.Fatalf("expected (%q, %v) for %q, got (%q, %v)", testCase.expectedOrderId, testCase.expectedIsCommand, testCase.message, orderId, isCommand)
+		}
+	}
+}
+
+func TestAntCancelUnpaidReservations(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	now := time.Now()
+	for _, order := range []struct {
+		id    string
+		state string
+	}{{"order-1", kReservedOrderState}, {"order-4", "ACTIVE"}} {
+		fakeOrder := NewFakeOrder(order.id, "Bakery", now.Add(3*time.Hour), now.Add(time.Hour))
+		fakeOrder["state"] = order.state
+		server.AddOrder(fakeOrder)
+	}
+	client := newTestClient(server)
+	client.Config.PaymentConfig.UnpaidReservationGracePeriod = Duration{Duration: time.Hour}
+
+	reservationTracker := &ReservationTracker{}
+	reservationTracker.Add(TrackedReservation{OrderId: "order-1", StoreName: "Bakery", ReservedTime: now.Add(-2 * time.Hour), Email: "ant1@email.com"})
+	reservationTracker.Add(TrackedReservation{OrderId: "order-2", StoreName: "Sushi", ReservedTime: now})
+	reservationTracker.Add(TrackedReservation{OrderId: "order-3", StoreName: "Pizza", ReservedTime: now})
+	// paid, not opened anymore, and made with another account: none of them should be cancelled
+	reservationTracker.Add(TrackedReservation{OrderId: "order-4", StoreName: "Bakery", ReservedTime: now.Add(-2 * time.Hour)})
+	reservationTracker.Add(TrackedReservation{OrderId: "order-5", StoreName: "Bakery", ReservedTime: now.Add(-2 * time.Hour)})
+	reservationTracker.Add(TrackedReservation{OrderId: "order-6", StoreName: "Bakery", ReservedTime: now.Add(-2 * time.Hour), Email: "ant2@email.com"})
+
+	commands := make(chan string, 2)
+	commands <- "cancel order-3"
+	commands <- "hello"
+
+	sender := &cancelAfterWriter{nbMaxMessages: 10, cancel: func() {}}
+	ant := &Ant{
+		client:             client,
+		sender:             sender,
+		storeTracker:       NewStoreTracker(nil),
+		reservationTracker: reservationTracker,
+		commands:           commands,
+	}
+
+	ant.cancelUnpaidReservations(context.Background())
+
+	if expectedIds := []string{"order-1", "order-3"}; !reflect.DeepEqual(server.AbortedOrderIds(), expectedIds) {
+		t.Fatalf("expected aborted orders %v, got %v", expectedIds, server.AbortedOrderIds())
+	}
+	if expectedIds := []string{"order-2", "order-6"}; !reflect.DeepEqual(trackedOrderIds(reservationTracker.Reservations()), expectedIds) {
+		t.Fatalf("expected reservations %v, got %v", expectedIds, trackedOrderIds(reservationTracker.Reservations()))
+	}
+	if len(sender.messages) != 2 {
+		t.Fatalf("expected 2 messages, got %v", sender.messages)
+	}
+
+	// cancelling an order already aborted on the server side is considered successful
+	commands <- "cancel"
+	reservationTracker.Add(TrackedReservation{OrderId: "order-1", StoreName: "Bakery", ReservedTime: time.Now()})
+
+	ant.cancelUnpaidReservations(context.Background())
+
+	if expectedIds := []string{"order-6"}; !reflect.DeepEqual(trackedOrderIds(reservationTracker.Reservations()), expectedIds) {
+		t.Fatalf("expected reservations %v, got %v", expectedIds, trackedOrderIds(reservationTracker.Reservations()))
+	}
+	if len(sender.messages) != 4 {
+		t.Fatalf("expected 4 messages, got %v", sender.messages)
+	}
+}
+
+func TestAntCancelOpenedOrderCommand(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	now := time.Now()
+	server.AddOrder(NewFakeOrder("paid-1", "Bakery", now.Add(3*time.Hour), now.Add(time.Hour)))
+	server.AddOrder(NewFakeOrder("paid-2", "Sushi", now.Add(time.Hour), now.Add(-time.Minute)))
+
+	commands := make(chan string, 2)
+	commands <- "cancel paid-1"
+	commands <- "cancel paid-2"
+
+	sender := &cancelAfterWriter{nbMaxMessages: 10, cancel: func() {}}
+	ant := &Ant{
+		client:             newTestClient(server),
+		sender:             sender,
+		storeTracker:       NewStoreTracker(nil),
+		reservationTracker: &ReservationTracker{},
+		commands:           commands,
+	}
+
+	ant.cancelUnpaidReservations(context.Background())
+
+	if expectedIds := []string{"paid-1"}; !reflect.DeepEqual(server.AbortedOrderIds(), expectedIds) {
+		t.Fatalf("expected aborted orders %v, got %v", expectedIds, server.AbortedOrderIds())
+	}
+	if len(sender.messages) != 2 || !strings.HasPrefix(sender.messages[0], "cancelled order # paid-1") || !strings.HasPrefix(sender.messages[1], "order paid-2 not cancelled") {
+		t.Fatalf("unexpected messages %q", sender.messages)
+	}
+}
@@ -6,38 +6,67 @@ import (
 )
 
 type ReservedOrder struct {
-	Id       string
-	StoreId  string
-	Quantity int
+	Id       string `json:"id"`
+	StoreId  string `json:"storeId"`
+	Quantity int    `json:"quantity"`
 }
 
 func (o *ReservedOrder) String() string {
 	return fmt.Sprintf("Order # %v in store %v with %v bags", o.Id, o.StoreId, o.Quantity)
 }
 
+type createOrderResponse struct {
+	State string `json:"state"`
+	Order struct {
+		Id        string `json:"id"`
+		ItemId    string `json:"item_id"`
+		OrderLine struct {
+			Quantity int `json:"quantity"`
+		} `json:"order_line"`
+	} `json:"order"`
+}
+
 func NewReservedOrderFromCreateOrder(responseBody []byte) (ReservedOrder, error) {
 	var reservedOrder ReservedOrder
-	if len(responseBody) == 0 {
-		return reservedOrder, nil
-	}
 
-	var parsedReservedOrder map[string]interface{}
-	err := json.Unmarshal(responseBody, &parsedReservedOrder)
+	var parsedResponse createOrderResponse
+	err := json.Unmarshal(responseBody, &parsedResponse)
 	if err != nil {
 		glog.Printf("full response: %v\n", string(responseBody))
 		return reservedOrder, fmt.Errorf("error from json.Unmarshal: %w", err)
 	}
 
-	state := parsedReservedOrder["state"].(string)
-	if state != "SUCCESS" {
-		return reservedOrder, fmt.Errorf("reserved order state %v is not OK", state)
+	if parsedResponse.State != "SUCCESS" {
+		return reservedOrder, fmt.Errorf("reserved order state %v is not OK", parsedResponse.State)
+	}
+	if len(parsedResponse.Order.Id) == 0 {
+		// an order without id could neither be paid nor cancelled
+		return reservedOrder, fmt.Errorf("expected field 'order.id' in response")
 	}
 
-	reservedOrderData := parsedReservedOrder["order"].(map[string]interface{})
-
-	reservedOrder.Id = reservedOrderData["id"].(string)
-	reservedOrder.StoreId = reservedOrderData["item_id"].(string)
-	reservedOrder.Quantity = int(reservedOrderData["order_line"].(map[string]interface{})["quantity"].(float64))
+	reservedOrder.Id = parsedResponse.Order.Id
+	reservedOrder.StoreId = parsedResponse.Order.ItemId
+	reservedOrder.Quantity = parsedResponse.Order.OrderLine.Quantity
 
 	return reservedOrder, nil
 }
+
+type cancelOrderResponse struct {
+	State string `json:"state"`
+}
+
+// CheckCancelOrderResponse returns nil if the order is cancelled, including when it was already cancelled before.
+func CheckCancelOrderResponse(responseBody []byte) error {
+	var parsedResponse cancelOrderResponse
+	err := json.Unmarshal(responseBody, &parsedResponse)
+	if err != nil {
+		glog.Printf("full response: %v\n", string(responseBody))
+		return fmt.Errorf("error from json.Unmarshal: %w", err)
+	}
+
+	switch parsedResponse.State {
+	case "SUCCESS", "ALREADY_ABORTED":
+		return nil
+	}
+	return fmt.Errorf("cancel order state %v is not OK", parsedResponse.State)
+}
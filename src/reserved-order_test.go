@@ -1,12 +1,16 @@
 package tga
 
 import (
+	"context"
+	"errors"
+	"net/http"
 	"os"
 	"testing"
 )
 
 const (
 	kExampleReservedOrder = "testdata/example_reserved_order.json"
+	kExampleCancelOrder   = "testdata/example_cancel_order.json"
 )
 
 func TestReservedOrderStandardResponse(t *testing.T) {
@@ -29,3 +33,49 @@ func TestReservedOrderStandardResponse(t *testing.T) {
 		t.Fatalf("expected reserved order %v, got %v", expectedReservedOrder, reservedOrder)
 	}
 }
+
+func TestReservedOrderFromMalformedCreateOrder(t *testing.T) {
+	for _, responseBody := range []string{
+		``,
+		`[]`,
+		`{"state": "SUCCESS"}`,
+		`{"state": "SUCCESS", "order": {}}`,
+		`{"state": "SUCCESS", "order": null}`,
+		`{"state": "SUCCESS", "order": {"id": 42}}`,
+		`{"state": "SOLD_OUT"}`,
+	} {
+		_, err := NewReservedOrderFromCreateOrder([]byte(responseBody))
+		if err == nil {
+			t.Fatalf("expected an error for create order response %q", responseBody)
+		}
+	}
+
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+	server.Enqueue(kApiCreateOrder+"/1", FakeResponse{StatusCode: http.StatusOK, Body: `{"state": "SUCCESS", "order": {}}`})
+
+	_, err := newTestClient(server).ReserveOrder(context.Background(), Store{Id: "1", AvailableBags: 2}, 1)
+	if !errors.Is(err, ErrMalformedResponse) {
+		t.Fatalf("expected malformed response error for an order without id, got %v", err)
+	}
+}
+
+func TestCancelOrderResponse(t *testing.T) {
+	responseBody, err := os.ReadFile(kExampleCancelOrder)
+	if err != nil {
+		t.Fatalf("error reading file %v", kExampleCancelOrder)
+	}
+	err = CheckCancelOrderResponse(responseBody)
+	if err != nil {
+		t.Fatalf("expected already aborted order to be considered as cancelled, got %v", err)
+	}
+
+	err = CheckCancelOrderResponse([]byte(`{"state": "SUCCESS"}`))
+	if err != nil {
+		t.Fatalf("error in CheckCancelOrderResponse: %v", err)
+	}
+	err = CheckCancelOrderResponse([]byte(`{"state": "ORDER_NOT_FOUND"}`))
+	if err == nil {
+		t.Fatalf("expected an error for an unexpected cancel state")
+	}
+}
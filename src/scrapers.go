@@ -1,6 +1,7 @@
 package tga
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,10 +16,14 @@ var (
 	)
 )
 
-func GetLastApkVersion() (string, error) {
-	resp, err := http.Get("https://play.google.com/store/apps/details?id=com.app.tgtg&hl=en&gl=US")
+func GetLastApkVersion(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://play.google.com/store/apps/details?id=com.app.tgtg&hl=en&gl=US", nil)
 	if err != nil {
-		return "", fmt.Errorf("error from http.Get: %w", err)
+		return "", fmt.Errorf("error from http.NewRequestWithContext: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error from http.DefaultClient.Do: %w", err)
 	}
 	defer resp.Body.Close()
 
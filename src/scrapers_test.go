@@ -1,11 +1,12 @@
 package tga
 
 import (
+	"context"
 	"testing"
 )
 
 func TestGetLastApkVersion(t *testing.T) {
-	lastApkVersion, err := GetLastApkVersion()
+	lastApkVersion, err := GetLastApkVersion(context.Background())
 	if err != nil {
 		t.Fatalf("error in GetLastApkVersion")
 	}
@@ -9,6 +9,7 @@ import (
 	"go.mau.fi/whatsmeow"
 	waProto "go.mau.fi/whatsmeow/binary/proto"
 	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/protobuf/proto"
 )
@@ -21,16 +22,21 @@ type Sender struct {
 	from      string
 	to        string
 	targetJID types.JID
+	commands  chan string
 }
 
-func NewSender(sendConfig SendConfig) (Sender, error) {
+const (
+	kMaxNbPendingCommands = 16
+)
+
+func NewSender(ctx context.Context, sendConfig SendConfig) (Sender, error) {
 	var sender Sender
 	var err error
 	switch sendConfig.SendAction {
 	case NoSend:
 		return sender, nil
 	case SendEmail:
-		sender.GmailClient, err = NewGmailClient(sendConfig.EmailConfig)
+		sender.GmailClient, err = NewGmailClient(ctx, sendConfig.EmailConfig)
 		if err != nil {
 			return sender, fmt.Errorf("error from NewGmailService: %w", err)
 		}
@@ -70,10 +76,38 @@ func NewSender(sendConfig SendConfig) (Sender, error) {
 		} else {
 			return sender, fmt.Errorf("at least group or user should be specified for WhatsApp message destination")
 		}
+
+		sender.commands = make(chan string, kMaxNbPendingCommands)
+		sender.WhatsAppClient.AddEventHandler(sender.handleWhatsAppEvent)
 	}
 	return sender, nil
 }
 
+// handleWhatsAppEvent forwards the text messages received in the destination conversation as commands.
+func (s Sender) handleWhatsAppEvent(evt interface{}) {
+	message, isMessage := evt.(*events.Message)
+	if !isMessage || message.Info.Chat != s.targetJID {
+		return
+	}
+	text := message.Message.GetConversation()
+	if len(text) == 0 {
+		text = message.Message.GetExtendedTextMessage().GetText()
+	}
+	if len(text) == 0 {
+		return
+	}
+	select {
+	case s.commands <- text:
+	default:
+		glog.Printf("too many pending commands, ignoring message %v\n", text)
+	}
+}
+
+// Commands returns the channel of the messages received from the user, nil if the sender cannot receive messages.
+func (s Sender) Commands() <-chan string {
+	return s.commands
+}
+
 // satisfy the Writer interface
 func (s Sender) Write(p []byte) (n int, err error) {
 	nbBytesWritten := 0
@@ -0,0 +1,286 @@
+package tga
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+type StoreEventKind int
+
+const (
+	NewlyAvailable StoreEventKind = iota
+	Restocked
+	QuantityDecreased
+	SoldOut
+	PriceChanged
+)
+
+var (
+	kDefaultNotifyEvents = []StoreEventKind{NewlyAvailable, Restocked}
+)
+
+func (k StoreEventKind) String() string {
+	switch k {
+	case NewlyAvailable:
+		return "newlyAvailable"
+	case Restocked:
+		return "restocked"
+	case QuantityDecreased:
+		return "quantityDecreased"
+	case SoldOut:
+		return "soldOut"
+	case PriceChanged:
+		return "priceChanged"
+	}
+	return "<error>"
+}
+
+func NewStoreEventKind(str string) (StoreEventKind, error) {
+	for kind := NewlyAvailable; kind <= PriceChanged; kind++ {
+		if str == kind.String() {
+			return kind, nil
+		}
+	}
+	return -1, fmt.Errorf("unknown store event kind %v", str)
+}
+
+func (k StoreEventKind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.String())
+}
+
+func (k *StoreEventKind) UnmarshalJSON(b []byte) error {
+	var str string
+	err := json.Unmarshal(b, &str)
+	if err != nil {
+		return fmt.Errorf("error from json.Unmarshal: %w", err)
+	}
+	kind, err := NewStoreEventKind(str)
+	if err != nil {
+		return fmt.Errorf("error from NewStoreEventKind: %w", err)
+	}
+	*k = kind
+	return nil
+}
+
+// StoreEvent is a change of a store between two consecutive searches.
+type StoreEvent struct {
+	Kind                  StoreEventKind
+	Store                 Store
+	PreviousAvailableBags int
+	PreviousPrice         Price
+}
+
+func (e *StoreEvent) String() string {
+	switch e.Kind {
+	case NewlyAvailable:
+		return fmt.Sprintf("newly available: %v", e.Store.String())
+	case Restocked:
+		return fmt.Sprintf("restocked from %v: %v", e.PreviousAvailableBags, e.Store.String())
+	case QuantityDecreased:
+		return fmt.Sprintf("quantity decreased from %v: %v", e.PreviousAvailableBags, e.Store.String())
+	case SoldOut:
+		name := e.Store.DisplayName
+		if len(name) == 0 {
+			name = e.Store.Name
+		}
+		return fmt.Sprintf("sold out: %v", name)
+	case PriceChanged:
+		return fmt.Sprintf("price changed from %v: %v", e.PreviousPrice, e.Store.String())
+	}
+	return "<error>"
+}
+
+// StoreTracker remembers the last known state of each store, keyed by item id,
+// to compute the events between consecutive searches.
+// This state can be persisted to a file to survive restarts.
+type StoreTracker struct {
+	lastStores        map[string]Store
+	lastNotifiedTimes map[string]time.Time
+	notifyEvents      map[StoreEventKind]bool
+	filePath          string
+}
+
+// Persisted state of a tracked store
+type trackedStoreState struct {
+	Name             string    `json:"name"`
+	AvailableBags    int       `json:"availableBags"`
+	Price            Price     `json:"price"`
+	LastNotifiedTime time.Time `json:"lastNotifiedTime,omitempty"`
+}
+
+// NewStoreTracker creates a tracker reporting only given kinds of events (default ones if empty).
+func NewStoreTracker(notifyEvents []StoreEventKind) *StoreTracker {
+	if len(notifyEvents) == 0 {
+		notifyEvents = kDefaultNotifyEvents
+	}
+	tracker := &StoreTracker{
+		lastStores:        make(map[string]Store),
+		lastNotifiedTimes: make(map[string]time.Time),
+		notifyEvents:      make(map[StoreEventKind]bool),
+	}
+	for _, kind := range notifyEvents {
+		tracker.notifyEvents[kind] = true
+	}
+	return tracker
+}
+
+// LoadStoreTracker creates a tracker persisted in given file, loading its state from it if it exists.
+func LoadStoreTracker(filePath string, notifyEvents []StoreEventKind) (*StoreTracker, error) {
+	tracker := NewStoreTracker(notifyEvents)
+	tracker.filePath = filePath
+
+	fileData, err := os.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return tracker, nil
+	}
+	if err != nil {
+		return tracker, fmt.Errorf("error from os.ReadFile: %w", err)
+	}
+
+	var states map[string]trackedStoreState
+	err = json.Unmarshal(fileData, &states)
+	if err != nil {
+		return tracker, fmt.Errorf("error from json.Unmarshal: %w", err)
+	}
+
+	for storeId, state := range states {
+		tracker.lastStores[storeId] = Store{
+			Name:          state.Name,
+			Id:            storeId,
+			Price:         state.Price,
+			AvailableBags: state.AvailableBags,
+		}
+		if !state.LastNotifiedTime.IsZero() {
+			tracker.lastNotifiedTimes[storeId] = state.LastNotifiedTime
+		}
+	}
+
+	glog.Printf("loaded state of %v store(s) from %v\n", len(states), filePath)
+
+	return tracker, nil
+}
+
+// Save writes atomically the state of the tracker to its file, if any.
+func (tracker *StoreTracker) Save() error {
+	if len(tracker.filePath) == 0 {
+		return nil
+	}
+
+	states := make(map[string]trackedStoreState, len(tracker.lastStores))
+	for storeId, store := range tracker.lastStores {
+		states[storeId] = trackedStoreState{
+			Name:             store.Name,
+			AvailableBags:    store.AvailableBags,
+			Price:            store.Price,
+			LastNotifiedTime: tracker.lastNotifiedTimes[storeId],
+		}
+	}
+
+	fileData, err := json.MarshalIndent(states, "", " ")
+	if err != nil {
+		return fmt.Errorf("error from json.MarshalIndent: %w", err)
+	}
+	err = writeFileAtomically(tracker.filePath, fileData)
+	if err != nil {
+		return fmt.Errorf("error from writeFileAtomically: %w", err)
+	}
+	return nil
+}
+
+// SetNotified records the time at which given events have been notified.
+func (tracker *StoreTracker) SetNotified(events []StoreEvent, notifiedTime time.Time) {
+	for _, event := range events {
+		tracker.lastNotifiedTimes[event.Store.Id] = notifiedTime
+	}
+}
+
+// Diff returns the events to notify between the last known state and given stores, in the order of given stores,
+// without recording the new state: Commit should be called once the events are notified, so that they are computed
+// again at next search otherwise.
+// Known stores absent from given stores are considered sold out.
+func (tracker *StoreTracker) Diff(stores []Store) []StoreEvent {
+	events := []StoreEvent{}
+	addEvent := func(event StoreEvent) {
+		if tracker.notifyEvents[event.Kind] {
+			events = append(events, event)
+		}
+	}
+
+	seenIds := make(map[string]bool)
+	for _, store := range stores {
+		seenIds[store.Id] = true
+		previous, known := tracker.lastStores[store.Id]
+
+		event := StoreEvent{
+			Store:                 store,
+			PreviousAvailableBags: previous.AvailableBags,
+			PreviousPrice:         previous.Price,
+		}
+
+		switch {
+		case !known:
+			if store.AvailableBags > 0 {
+				event.Kind = NewlyAvailable
+				addEvent(event)
+			}
+			continue
+		case store.AvailableBags > previous.AvailableBags:
+			event.Kind = Restocked
+			addEvent(event)
+		case store.AvailableBags == 0 && previous.AvailableBags > 0:
+			event.Kind = SoldOut
+			addEvent(event)
+		case store.AvailableBags < previous.AvailableBags:
+			event.Kind = QuantityDecreased
+			addEvent(event)
+		}
+
+		if store.Price != previous.Price {
+			event.Kind = PriceChanged
+			addEvent(event)
+		}
+	}
+
+	for _, storeId := range tracker.disappearedIds(seenIds) {
+		previous := tracker.lastStores[storeId]
+		store := previous
+		store.AvailableBags = 0
+		addEvent(StoreEvent{
+			Kind:                  SoldOut,
+			Store:                 store,
+			PreviousAvailableBags: previous.AvailableBags,
+			PreviousPrice:         previous.Price,
+		})
+	}
+
+	return events
+}
+
+// Commit records given stores as the last known state, known stores absent from them being sold out.
+func (tracker *StoreTracker) Commit(stores []Store) {
+	seenIds := make(map[string]bool)
+	for _, store := range stores {
+		seenIds[store.Id] = true
+		tracker.lastStores[store.Id] = store
+	}
+	for _, storeId := range tracker.disappearedIds(seenIds) {
+		store := tracker.lastStores[storeId]
+		store.AvailableBags = 0
+		tracker.lastStores[storeId] = store
+	}
+}
+
+// disappearedIds returns the sorted ids of the known stores with available bags which are not in seenIds.
+func (tracker *StoreTracker) disappearedIds(seenIds map[string]bool) []string {
+	disappearedIds := []string{}
+	for storeId, previous := range tracker.lastStores {
+		if !seenIds[storeId] && previous.AvailableBags > 0 {
+			disappearedIds = append(disappearedIds, storeId)
+		}
+	}
+	sort.Strings(disappearedIds)
+	return disappearedIds
+}
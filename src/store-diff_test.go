@@ -0,0 +1,156 @@
+package tga
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newDiffTestStore(id string, availableBags int, amount int) Store {
+	return Store{
+		Name:          "Store " + id,
+		Id:            id,
+		Price:         Price{Amount: amount, NbDecimals: 2, CurrencyCode: "EUR"},
+		AvailableBags: availableBags,
+	}
+}
+
+func eventKinds(events []StoreEvent) []StoreEventKind {
+	kinds := make([]StoreEventKind, len(events))
+	for eventPos, event := range events {
+		kinds[eventPos] = event.Kind
+	}
+	return kinds
+}
+
+// diffAndCommit returns the events to notify for given stores and records them, as the harvest loop does
+// once the events are notified.
+func diffAndCommit(tracker *StoreTracker, stores []Store) []StoreEvent {
+	events := tracker.Diff(stores)
+	tracker.Commit(stores)
+	return events
+}
+
+func TestStoreTrackerAllEvents(t *testing.T) {
+	tracker := NewStoreTracker([]StoreEventKind{NewlyAvailable, Restocked, QuantityDecreased, SoldOut, PriceChanged})
+
+	events := diffAndCommit(tracker, []Store{newDiffTestStore("1", 2, 399), newDiffTestStore("2", 0, 399)})
+	if expectedKinds := []StoreEventKind{NewlyAvailable}; !reflect.DeepEqual(eventKinds(events), expectedKinds) {
+		t.Fatalf("expected events %v, got %v", expectedKinds, eventKinds(events))
+	}
+
+	// same state, different order: nothing to notify
+	events = diffAndCommit(tracker, []Store{newDiffTestStore("2", 0, 399), newDiffTestStore("1", 2, 399)})
+	if len(events) != 0 {
+		t.Fatalf("expected no events, got %v", eventKinds(events))
+	}
+
+	events = diffAndCommit(tracker, []Store{newDiffTestStore("1", 1, 399), newDiffTestStore("2", 3, 499)})
+	if expectedKinds := []StoreEventKind{QuantityDecreased, Restocked, PriceChanged}; !reflect.DeepEqual(eventKinds(events), expectedKinds) {
+		t.Fatalf("expected events %v, got %v", expectedKinds, eventKinds(events))
+	}
+	if events[0].PreviousAvailableBags != 2 || events[2].PreviousPrice.Amount != 399 {
+		t.Fatalf("unexpected previous state in events %v", events)
+	}
+
+	// store 1 is sold out, store 2 disappears from the results
+	events = diffAndCommit(tracker, []Store{newDiffTestStore("1", 0, 399)})
+	if expectedKinds := []StoreEventKind{SoldOut, SoldOut}; !reflect.DeepEqual(eventKinds(events), expectedKinds) {
+		t.Fatalf("expected events %v, got %v", expectedKinds, eventKinds(events))
+	}
+	if expectedString := "sold out: Store 2"; events[1].String() != expectedString {
+		t.Fatalf("expected %q, got %q", expectedString, events[1].String())
+	}
+
+	events = diffAndCommit(tracker, []Store{newDiffTestStore("2", 1, 499)})
+	if expectedKinds := []StoreEventKind{Restocked}; !reflect.DeepEqual(eventKinds(events), expectedKinds) {
+		t.Fatalf("expected events %v, got %v", expectedKinds, eventKinds(events))
+	}
+	if expectedString := "restocked from 0: Store 2, rated 0.0, price 4.99 EUR, 1 available"; events[0].String() != expectedString {
+		t.Fatalf("expected %q, got %q", expectedString, events[0].String())
+	}
+}
+
+func TestStoreTrackerDefaultEvents(t *testing.T) {
+	tracker := NewStoreTracker(nil)
+
+	diffAndCommit(tracker, []Store{newDiffTestStore("1", 3, 399)})
+
+	events := diffAndCommit(tracker, []Store{newDiffTestStore("1", 2, 299)})
+	if len(events) != 0 {
+		t.Fatalf("expected no events, got %v", eventKinds(events))
+	}
+	events = diffAndCommit(tracker, []Store{})
+	if len(events) != 0 {
+		t.Fatalf("expected no events, got %v", eventKinds(events))
+	}
+	events = diffAndCommit(tracker, []Store{newDiffTestStore("1", 1, 299)})
+	if expectedKinds := []StoreEventKind{Restocked}; !reflect.DeepEqual(eventKinds(events), expectedKinds) {
+		t.Fatalf("expected events %v, got %v", expectedKinds, eventKinds(events))
+	}
+}
+
+func TestStoreEventKindJson(t *testing.T) {
+	var kinds []StoreEventKind
+	err := json.Unmarshal([]byte(`["soldOut", "priceChanged"]`), &kinds)
+	if err != nil {
+		t.Fatalf("error from json.Unmarshal: %v", err)
+	}
+	if expectedKinds := []StoreEventKind{SoldOut, PriceChanged}; !reflect.DeepEqual(kinds, expectedKinds) {
+		t.Fatalf("expected %v, got %v", expectedKinds, kinds)
+	}
+	err = json.Unmarshal([]byte(`["unknown"]`), &kinds)
+	if err == nil {
+		t.Fatalf("expected an error for unknown event kind")
+	}
+}
+
+func TestStoreTrackerPersistence(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "state", kNotificationStateFileName)
+
+	tracker, err := LoadStoreTracker(filePath, nil)
+	if err != nil {
+		t.Fatalf("error from LoadStoreTracker: %v", err)
+	}
+	events := diffAndCommit(tracker, []Store{newDiffTestStore("1", 2, 399), newDiffTestStore("2", 0, 399)})
+	notifiedTime := time.Date(2023, time.May, 21, 19, 55, 0, 0, time.UTC)
+	tracker.SetNotified(events, notifiedTime)
+
+	err = tracker.Save()
+	if err != nil {
+		t.Fatalf("error from tracker.Save: %v", err)
+	}
+
+	tracker, err = LoadStoreTracker(filePath, nil)
+	if err != nil {
+		t.Fatalf("error from LoadStoreTracker: %v", err)
+	}
+	if !tracker.lastNotifiedTimes["1"].Equal(notifiedTime) || !tracker.lastNotifiedTimes["2"].IsZero() {
+		t.Fatalf("unexpected last notified times %v and %v", tracker.lastNotifiedTimes["1"], tracker.lastNotifiedTimes["2"])
+	}
+
+	// already notified bags are not notified again after a restart
+	events = diffAndCommit(tracker, []Store{newDiffTestStore("1", 2, 399), newDiffTestStore("2", 1, 399)})
+	if expectedKinds := []StoreEventKind{Restocked}; !reflect.DeepEqual(eventKinds(events), expectedKinds) {
+		t.Fatalf("expected events %v, got %v", expectedKinds, eventKinds(events))
+	}
+}
+
+func TestStoreTrackerCorruptedFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), kNotificationStateFileName)
+	err := os.WriteFile(filePath, []byte("{"), 0600)
+	if err != nil {
+		t.Fatalf("error from os.WriteFile: %v", err)
+	}
+
+	tracker, err := LoadStoreTracker(filePath, nil)
+	if err == nil {
+		t.Fatalf("expected an error for a corrupted state file")
+	}
+	if events := diffAndCommit(tracker, []Store{newDiffTestStore("1", 2, 399)}); len(events) != 1 {
+		t.Fatalf("expected a usable empty tracker, got events %v", eventKinds(events))
+	}
+}
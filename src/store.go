@@ -3,18 +3,169 @@ package tga
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 )
 
 type Store struct {
-	Name          string
-	Id            string
-	Rating        float64
-	Price         Price
-	AvailableBags int
+	Name            string        `json:"name"`
+	DisplayName     string        `json:"displayName"`
+	Id              string        `json:"id"`
+	Rating          float64       `json:"rating"`
+	Price           Price         `json:"price"`
+	Value           Price         `json:"value"`
+	AvailableBags   int           `json:"availableBags"`
+	PickupDetails   PickupDetails `json:"pickupDetails"`
+	Coordinates     Location      `json:"coordinates"`
+	TimeZone        string        `json:"timeZone"`
+	CoverPictureUrl string        `json:"coverPictureUrl"`
+	ItemCategory    string        `json:"itemCategory"`
+	DietCategories  []string      `json:"dietCategories"`
+	PackagingOption string        `json:"packagingOption"`
+	Badges          []Badge       `json:"badges"`
+	InSalesWindow   bool          `json:"inSalesWindow"`
+	Distance        float64       `json:"distance"`
+	Favorite        bool          `json:"favorite"`
+	Description     string        `json:"description"`
+	CollectionInfo  string        `json:"collectionInfo"`
+	SearchNames     []string      `json:"searchNames"` // names of the searches which found this store
+}
+
+// Badge is a rating badge of a store, for instance OVERALL_RATING_TRUST_SCORE.
+type Badge struct {
+	BadgeType   string `json:"badgeType"`
+	RatingGroup string `json:"ratingGroup"`
+	Percentage  int    `json:"percentage"`
+	UserCount   int    `json:"userCount"`
+	MonthCount  int    `json:"monthCount"`
 }
 
 func (s *Store) String() string {
-	return fmt.Sprintf("%v, rated %v, price %v, %v available", s.Name, s.Rating, s.Price, s.AvailableBags)
+	var sb strings.Builder
+
+	name := s.DisplayName
+	if len(name) == 0 {
+		name = s.Name
+	}
+	sb.WriteString(name)
+	if len(s.ItemCategory) > 0 {
+		fmt.Fprintf(&sb, " [%v]", s.ItemCategory)
+	}
+	fmt.Fprintf(&sb, ", rated %.1f, price %v", s.Rating, s.Price)
+	if s.Value.Amount > 0 {
+		fmt.Fprintf(&sb, " (worth %v)", s.Value)
+	}
+	fmt.Fprintf(&sb, ", %v available", s.AvailableBags)
+
+	if !s.PickupDetails.FromGMT.IsZero() {
+		fmt.Fprintf(&sb, "\npickup %v", FormatPickupWindow(s.PickupDetails.FromGMT, s.PickupDetails.ToGMT, s.TimeZone))
+	}
+	if len(s.PickupDetails.Address) > 0 {
+		fmt.Fprintf(&sb, "\nat %v", s.PickupDetails.Address)
+		if s.Distance > 0 {
+			fmt.Fprintf(&sb, " (%.1f km)", s.Distance)
+		}
+	}
+	if len(s.SearchNames) > 0 {
+		fmt.Fprintf(&sb, "\nfound by %v", strings.Join(s.SearchNames, ", "))
+	}
+	return sb.String()
+}
+
+// FormatPickupWindow renders a pickup window in given time zone (UTC if unknown), for instance "Sun 21 May 21:55 - 22:00 (Europe/Paris)".
+func FormatPickupWindow(from, to time.Time, timeZone string) string {
+	location, err := time.LoadLocation(timeZone)
+	if err != nil || len(timeZone) == 0 {
+		location = time.UTC
+	}
+	from = from.In(location)
+	to = to.In(location)
+
+	const kDayLayout = "Mon 2 Jan"
+	const kHourLayout = "15:04"
+
+	toLayout := kHourLayout
+	if from.YearDay() != to.YearDay() || from.Year() != to.Year() {
+		toLayout = kDayLayout + " " + kHourLayout
+	}
+	return fmt.Sprintf("%v - %v (%v)", from.Format(kDayLayout+" "+kHourLayout), to.Format(toLayout), location)
+}
+
+// NewStoreFromItemResponse creates a Store from an item/v7 entry, returning an error if a mandatory field is missing.
+func NewStoreFromItemResponse(entry ItemEntryResponse) (Store, error) {
+	var store Store
+	if entry.Item == nil {
+		return store, fmt.Errorf("missing field 'item'")
+	}
+	if len(entry.Item.ItemId) == 0 {
+		return store, fmt.Errorf("missing field 'item.item_id'")
+	}
+	if entry.Item.ItemPrice == nil {
+		return store, fmt.Errorf("missing field 'item.item_price' for item %v", entry.Item.ItemId)
+	}
+	if entry.Store == nil || len(entry.Store.StoreName) == 0 {
+		return store, fmt.Errorf("missing field 'store.store_name' for item %v", entry.Item.ItemId)
+	}
+
+	store.Id = entry.Item.ItemId
+	store.Name = entry.Store.StoreName
+	store.DisplayName = entry.DisplayName
+	store.Price = entry.Item.ItemPrice.Price()
+	if entry.Item.ValueIncludingTaxes != nil {
+		store.Value = entry.Item.ValueIncludingTaxes.Price()
+	}
+	if entry.PickupInterval != nil {
+		store.PickupDetails.FromGMT = entry.PickupInterval.Start
+		store.PickupDetails.ToGMT = entry.PickupInterval.End
+	}
+	pickupLocation := entry.PickupLocation
+	if pickupLocation == nil {
+		pickupLocation = entry.Store.StoreLocation
+	}
+	if pickupLocation != nil {
+		store.PickupDetails.Address = pickupLocation.Address.AddressLine
+		store.Coordinates = pickupLocation.Location
+	}
+	store.TimeZone = entry.Store.StoreTimeZone
+	coverPicture := entry.Item.CoverPicture
+	if coverPicture == nil {
+		coverPicture = entry.Store.CoverPicture
+	}
+	if coverPicture != nil {
+		store.CoverPictureUrl = coverPicture.CurrentUrl
+	}
+	if entry.Item.AverageOverallRating != nil {
+		store.Rating = entry.Item.AverageOverallRating.AverageOverallRating
+	}
+	store.AvailableBags = entry.ItemsAvailable
+	store.ItemCategory = entry.Item.ItemCategory
+	store.DietCategories = entry.Item.DietCategories
+	store.PackagingOption = entry.Item.PackagingOption
+	store.Badges = make([]Badge, len(entry.Item.Badges))
+	for badgePos, badge := range entry.Item.Badges {
+		store.Badges[badgePos] = badge.Badge()
+	}
+	store.InSalesWindow = entry.InSalesWindow
+	store.Distance = entry.Distance
+	store.Favorite = entry.Favorite
+	store.Description = entry.Item.Description
+	if len(store.Description) == 0 {
+		store.Description = entry.Store.Description
+	}
+	store.CollectionInfo = entry.Item.CollectionInfo
+
+	return store, nil
+}
+
+// NewStoreFromItemDetailResponse creates a Store from an item/v7/{id} response, which contains a single item entry.
+func NewStoreFromItemDetailResponse(responseBody []byte) (Store, error) {
+	var parsedItem ItemEntryResponse
+	err := json.Unmarshal(responseBody, &parsedItem)
+	if err != nil {
+		glog.Printf("full response: %v\n", string(responseBody))
+		return Store{}, fmt.Errorf("error from json.Unmarshal: %w", err)
+	}
+	return NewStoreFromItemResponse(parsedItem)
 }
 
 func NewStoresFromListStoresResponse(responseBody []byte) ([]Store, error) {
@@ -22,33 +173,20 @@ func NewStoresFromListStoresResponse(responseBody []byte) ([]Store, error) {
 		return []Store{}, nil
 	}
 
-	var parsedItems map[string][]map[string]interface{}
+	var parsedItems ListItemsResponse
 	err := json.Unmarshal(responseBody, &parsedItems)
 	if err != nil {
 		glog.Printf("full response: %v\n", string(responseBody))
 		return []Store{}, fmt.Errorf("error from json.Unmarshal: %w", err)
 	}
 
-	items := parsedItems["items"]
-
-	stores := make([]Store, len(items))
+	stores := make([]Store, len(parsedItems.Items))
 
-	for itemPos, item := range items {
-		itemParsed := item["item"].(map[string]interface{})
-
-		stores[itemPos].Id = itemParsed["item_id"].(string)
-
-		stores[itemPos].Price = NewPrice(itemParsed["item_price"].(map[string]interface{}))
-
-		rating, hasRating := itemParsed["average_overall_rating"]
-		if hasRating {
-			stores[itemPos].Rating = rating.(map[string]interface{})["average_overall_rating"].(float64)
+	for itemPos, item := range parsedItems.Items {
+		stores[itemPos], err = NewStoreFromItemResponse(item)
+		if err != nil {
+			return []Store{}, fmt.Errorf("error from NewStoreFromItemResponse for item %v: %w", itemPos, err)
 		}
-
-		storeParsed := item["store"].(map[string]interface{})
-
-		stores[itemPos].Name = storeParsed["store_name"].(string)
-		stores[itemPos].AvailableBags = int(item["items_available"].(float64))
 	}
 
 	return stores, nil
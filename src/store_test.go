@@ -4,10 +4,12 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 )
 
 const (
 	kExampleStorePath = "testdata/example_list.json"
+	kExampleItemPath  = "testdata/example_item.json"
 )
 
 func TestStoreEmptyResponse(t *testing.T) {
@@ -38,20 +40,144 @@ func TestStoreStandardResponse(t *testing.T) {
 
 	store1 := stores[0]
 	expectedStore1 := Store{
-		Name:   "Ennao",
-		Id:     "523087",
-		Rating: 0,
+		Name:        "Ennao",
+		DisplayName: "Ennao (Panier Surprise)",
+		Id:          "523087",
+		Rating:      0,
 		Price: Price{
 			Amount:       399,
 			NbDecimals:   2,
 			CurrencyCode: "EUR",
 		},
-		AvailableBags: 1,
+		Value: Price{
+			Amount:       1200,
+			NbDecimals:   2,
+			CurrencyCode: "EUR",
+		},
+		PickupDetails: PickupDetails{
+			Address: "45 Av. Reibaud, 06600 Antibes, France",
+		},
+		Coordinates: Location{
+			Latitude:  43.5844836,
+			Longitude: 7.11453,
+		},
+		TimeZone:        "Europe/Paris",
+		CoverPictureUrl: "https://images.tgtg.ninja/standard_images/GENERAL/other3.jpg",
+		AvailableBags:   1,
+		ItemCategory:    "MEAL",
+		DietCategories:  []string{},
+		PackagingOption: "MUST_BRING_PACKAGING",
+		Badges: []Badge{
+			{
+				BadgeType:   "SERVICE_RATING_SCORE",
+				RatingGroup: "LOVED",
+				Percentage:  100,
+				UserCount:   7,
+				MonthCount:  3,
+			},
+			{
+				BadgeType:   "OVERALL_RATING_TRUST_SCORE",
+				RatingGroup: "LOVED",
+				Percentage:  100,
+				UserCount:   7,
+				MonthCount:  3,
+			},
+		},
+		InSalesWindow:  false,
+		Distance:       0.12173646789241477,
+		Favorite:       true,
+		Description:    "Sauvez un panier surprise composé d'une cuisine bio, saine et gourmande.",
+		CollectionInfo: "Possibilité d'apporter son propre contenant.",
 	}
 
-	if store1 != expectedStore1 {
+	if !reflect.DeepEqual(store1, expectedStore1) {
 		t.Fatalf("expected store %v, got %v", expectedStore1, store1)
 	}
+
+	store3 := stores[2]
+	if store3.Name != "Sushi Shop - Antibes" || store3.Rating != 4.3573667711598745 || store3.ItemCategory != "MEAL" {
+		t.Fatalf("unexpected store %v", store3)
+	}
+
+	expectedFromGMT := time.Date(2023, 5, 21, 19, 55, 0, 0, time.UTC)
+	expectedToGMT := time.Date(2023, 5, 21, 20, 0, 0, 0, time.UTC)
+	if !store3.PickupDetails.FromGMT.Equal(expectedFromGMT) || !store3.PickupDetails.ToGMT.Equal(expectedToGMT) {
+		t.Fatalf("unexpected pickup details %v", store3.PickupDetails)
+	}
+
+	expectedString := "Sushi Shop - Antibes (Simple Soir) [MEAL], rated 4.4, price 8 EUR (worth 22 EUR), 0 available\n" +
+		"pickup Sun 21 May 21:55 - 22:00 (Europe/Paris)\n" +
+		"at 6 Boulevard Dugommier, 06600 Antibes, France (0.5 km)"
+	if store3.String() != expectedString {
+		t.Fatalf("expected string %q, got %q", expectedString, store3.String())
+	}
+}
+
+func TestStoreItemDetailResponse(t *testing.T) {
+	responseBody, err := os.ReadFile(kExampleItemPath)
+	if err != nil {
+		t.Fatalf("error reading file %v", kExampleItemPath)
+	}
+	store, err := NewStoreFromItemDetailResponse(responseBody)
+	if err != nil {
+		t.Fatalf("error in NewStoreFromItemDetailResponse: %v", err)
+	}
+
+	listResponseBody, err := os.ReadFile(kExampleStorePath)
+	if err != nil {
+		t.Fatalf("error reading file %v", kExampleStorePath)
+	}
+	stores, err := NewStoresFromListStoresResponse(listResponseBody)
+	if err != nil {
+		t.Fatalf("error in NewStoresFromListStoresResponse: %v", err)
+	}
+	if !reflect.DeepEqual(store, stores[2]) {
+		t.Fatalf("expected store %v, got %v", stores[2], store)
+	}
+	if len(store.Description) == 0 || len(store.CollectionInfo) == 0 || store.PickupDetails.FromGMT.IsZero() {
+		t.Fatalf("expected description, collection info and pickup window in store %v", store)
+	}
+
+	_, err = NewStoreFromItemDetailResponse([]byte(`{"display_name": "no item"}`))
+	if err == nil {
+		t.Fatalf("expected error for missing item")
+	}
+}
+
+func TestFormatPickupWindow(t *testing.T) {
+	from := time.Date(2023, 5, 21, 22, 30, 0, 0, time.UTC)
+	to := time.Date(2023, 5, 21, 23, 30, 0, 0, time.UTC)
+
+	if formatted, expected := FormatPickupWindow(from, to, "Europe/Rome"), "Mon 22 May 00:30 - 01:30 (Europe/Rome)"; formatted != expected {
+		t.Fatalf("expected %q, got %q", expected, formatted)
+	}
+	if formatted, expected := FormatPickupWindow(from, to, ""), "Sun 21 May 22:30 - 23:30 (UTC)"; formatted != expected {
+		t.Fatalf("expected %q, got %q", expected, formatted)
+	}
+	if formatted, expected := FormatPickupWindow(from, to, "America/New_York"), "Sun 21 May 18:30 - 19:30 (America/New_York)"; formatted != expected {
+		t.Fatalf("expected %q, got %q", expected, formatted)
+	}
+	if formatted, expected := FormatPickupWindow(from, to.Add(2*time.Hour), "UTC"), "Sun 21 May 22:30 - Mon 22 May 01:30 (UTC)"; formatted != expected {
+		t.Fatalf("expected %q, got %q", expected, formatted)
+	}
+}
+
+func TestStoreMissingFields(t *testing.T) {
+	responseBodies := map[string]string{
+		"missing item":       `{"items": [{"store": {"store_name": "Ennao"}, "items_available": 1}]}`,
+		"missing item id":    `{"items": [{"item": {"item_price": {"code": "EUR", "minor_units": 399, "decimals": 2}}, "store": {"store_name": "Ennao"}}]}`,
+		"missing item price": `{"items": [{"item": {"item_id": "1"}, "store": {"store_name": "Ennao"}}]}`,
+		"missing store name": `{"items": [{"item": {"item_id": "1", "item_price": {"code": "EUR", "minor_units": 399, "decimals": 2}}, "store": {}}]}`,
+		"missing store":      `{"items": [{"item": {"item_id": "1", "item_price": {"code": "EUR", "minor_units": 399, "decimals": 2}}}]}`,
+		"wrong type":         `{"items": [{"item": 42}]}`,
+	}
+
+	for testName, responseBody := range responseBodies {
+		stores, err := NewStoresFromListStoresResponse([]byte(responseBody))
+		if err == nil {
+			t.Fatalf("%v: expected error, got stores %v", testName, stores)
+		}
+	}
 }
 
 func TestStoreEqual(t *testing.T) {
@@ -79,10 +205,10 @@ func TestStoreEqual(t *testing.T) {
 		Price:  price,
 	}
 
-	if store1 != store2 {
+	if !reflect.DeepEqual(store1, store2) {
 		t.Fatalf("stores should be compared by id (expected %v == %v)\n", store1, store2)
 	}
-	if store1 == store3 {
+	if reflect.DeepEqual(store1, store3) {
 		t.Fatalf("stores should be compared by id (expected %v != %v)\n", store1, store3)
 	}
 
@@ -2,6 +2,7 @@ package tga
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"math/rand"
@@ -18,12 +19,14 @@ const (
 	kAuthByRequestPollingId = "auth/v4/authByRequestPollingId"
 	kRefreshTokenEndpoint   = "auth/v3/token/refresh"
 
-	kApiListOpenedOrders = "order/v7/active"
-	kApiCreateOrder      = "order/v7/create"
+	kApiListOpenedOrders   = "order/v7/active"
+	kApiListInactiveOrders = "order/v7/inactive"
+	kApiCreateOrder        = "order/v7/create"
 
 	kApiUserInformation = "user/v2"
 
 	kApiPaymentMethods = "paymentMethod/v1/"
+	kApiPayment        = "payment/v3"
 
 	kApiItemEndpoint = "item/v7/"
 )
@@ -40,9 +43,18 @@ type TooGooToGoClient struct {
 
 	currentAccountPos         int          `json:"-"`
 	lastQueryTimePerAccount   []time.Time  `json:"-"`
+	blockedUntilPerAccount    []time.Time  `json:"-"`
 	lastOpenedOrdersQueryTime time.Time    `json:"-"`
 	httpClient                *http.Client `json:"-"`
 	verbose                   bool         `json:"-"`
+	metrics                   *Metrics     `json:"-"`
+
+	// held by the goroutine using the client when it is shared by several ones, nil otherwise, see Ant.lockClient.
+	// It is released during the pauses of the client, so that the other goroutines can query meanwhile, unless keepSharedLock is set.
+	sharedLock     chan struct{} `json:"-"`
+	keepSharedLock bool          `json:"-"`
+
+	openBrowser func(url string) error `json:"-"`
 }
 
 func (client TooGooToGoClient) emailAccount() string {
@@ -72,18 +84,19 @@ func (client *TooGooToGoClient) incrCurrentAccountPos() {
 	if client.currentAccountPos == nbAccounts {
 		client.currentAccountPos = 0
 	}
+	client.metrics.AddAccountSwitch()
 
 	glog.Printf("switched to too good to go account %v\n", client.emailAccount())
 }
 
-func (client *TooGooToGoClient) switchToNextEmailAccount() error {
+func (client *TooGooToGoClient) switchToNextEmailAccount(ctx context.Context) error {
 
 	client.resetAuthData()
 	client.httpClient = NewHttpClient()
 	client.incrCurrentAccountPos()
 
 	var err error
-	client.UserAgent, err = getUserAgent(client.Config, client.currentAccountPos)
+	client.UserAgent, err = getUserAgent(ctx, client.Config, client.currentAccountPos)
 	if err != nil {
 		glog.Fatalf("error from getUserAgent: %v", err)
 	}
@@ -95,25 +108,44 @@ func (client *TooGooToGoClient) switchToNextEmailAccount() error {
 		if nowTime.Before(minTimeBeforeNextRequest) {
 			waitingDuration := minTimeBeforeNextRequest.Sub(nowTime)
 			glog.Printf("waiting %v as too many requests reached\n", waitingDuration)
-			time.Sleep(waitingDuration)
+			err = client.pause(ctx, waitingDuration)
+			if err != nil {
+				return fmt.Errorf("error from client.pause: %w", err)
+			}
 		}
 	}
 
-	err = client.ensureAuthDataValidity()
+	err = client.waitUntilUnblocked(ctx)
+	if err != nil {
+		return fmt.Errorf("error from client.waitUntilUnblocked: %w", err)
+	}
+
+	err = client.ensureAuthDataValidity(ctx)
 	if err != nil {
 		return fmt.Errorf("error from client.ensureAuthDataValidity: %w", err)
 	}
 	return nil
 }
 
-func getUserAgent(config *TooGoodToGoConfig, accountPos int) (string, error) {
+// waitUntilUnblocked waits for the end of the block period (from Retry-After header) of current account, if any.
+func (client *TooGooToGoClient) waitUntilUnblocked(ctx context.Context) error {
+	blockedUntil := client.blockedUntilPerAccount[client.currentAccountPos]
+	waitingDuration := time.Until(blockedUntil)
+	if waitingDuration <= 0 {
+		return nil
+	}
+	glog.Printf("waiting %v as requested by too good to go for account %v\n", waitingDuration, client.emailAccount())
+	return client.pause(ctx, waitingDuration)
+}
+
+func getUserAgent(ctx context.Context, config *TooGoodToGoConfig, accountPos int) (string, error) {
 	userAgent := config.Accounts[accountPos].UserAgent
 
 	const kUserAgentPrefix = "TGTG/"
 	const kDalvikStr = " Dalvik/"
 
-	lastApkVersion, err := GetLastApkVersion()
-	if err != nil {
+	lastApkVersion, err := GetLastApkVersion(ctx)
+	if err != nil && len(userAgent) == 0 {
 		return userAgent, fmt.Errorf("error from GetLastApkVersion: %w", err)
 	}
 
@@ -123,7 +155,10 @@ func getUserAgent(config *TooGoodToGoConfig, accountPos int) (string, error) {
 			return userAgent, fmt.Errorf("unexpected user agent '%v', should contain '%v'", userAgent, kDalvikStr)
 		}
 		apkVersion := userAgent[len(kUserAgentPrefix):dalvikIdx]
-		if apkVersion != lastApkVersion {
+		if err != nil {
+			// the last apk version is only used to warn about outdated user agents
+			glog.Printf("cannot check provided user agent apk version: %v\n", err)
+		} else if apkVersion != lastApkVersion {
 			glog.Printf("provided user agent apk version '%v' is different from last one '%v', you may want to update it\n", apkVersion, lastApkVersion)
 		}
 		return userAgent, nil
@@ -140,21 +175,26 @@ func getUserAgent(config *TooGoodToGoConfig, accountPos int) (string, error) {
 	return kUserAgents[rand.Intn(len(kUserAgents))], nil
 }
 
-func NewTooGooToGoClient(config *TooGoodToGoConfig, verbose bool) *TooGooToGoClient {
-	firstUserAgent, err := getUserAgent(config, 0)
+func NewTooGooToGoClient(ctx context.Context, config *TooGoodToGoConfig, verbose bool) *TooGooToGoClient {
+	firstUserAgent, err := getUserAgent(ctx, config, 0)
 	if err != nil {
 		glog.Fatalf("error from getUserAgent: %v", err)
 	}
 
 	lastQueryTimePerAccount := make([]time.Time, len(config.Accounts))
+	blockedUntilPerAccount := make([]time.Time, len(config.Accounts))
 
 	return &TooGooToGoClient{
 		Config:     config,
 		httpClient: NewHttpClient(),
 		UserAgent:  firstUserAgent,
 		verbose:    verbose,
+		metrics:    NewMetrics(),
+
+		openBrowser: OpenBrowser,
 
 		lastQueryTimePerAccount: lastQueryTimePerAccount,
+		blockedUntilPerAccount:  blockedUntilPerAccount,
 	}
 }
 
@@ -176,8 +216,16 @@ func (client *TooGooToGoClient) setRefreshedTokenData(responseBody []byte) error
 	if err != nil {
 		return fmt.Errorf("error from json.Unmarshal: %w", err)
 	}
-	client.AccessToken = parsedBody["access_token"].(string)
-	client.RefreshToken = parsedBody["refresh_token"].(string)
+	accessToken, hasAccessToken := parsedBody["access_token"].(string)
+	if !hasAccessToken {
+		return fmt.Errorf("expected field 'access_token' in response")
+	}
+	refreshToken, hasRefreshToken := parsedBody["refresh_token"].(string)
+	if !hasRefreshToken {
+		return fmt.Errorf("expected field 'refresh_token' in response")
+	}
+	client.AccessToken = accessToken
+	client.RefreshToken = refreshToken
 	client.LastTokenRefreshedTime = time.Now()
 
 	glog.Printf("refreshed token\n")
@@ -185,14 +233,15 @@ func (client *TooGooToGoClient) setRefreshedTokenData(responseBody []byte) error
 	return nil
 }
 
-func (client *TooGooToGoClient) refreshToken() error {
+func (client *TooGooToGoClient) refreshToken(ctx context.Context) error {
 	if client.IsTokenStillValid() {
 		return nil
 	}
+	defer client.holdSharedLock()()
 
 	jsonData := fmt.Sprintf(`{"refresh_token": "%v"}`, client.RefreshToken)
 
-	response, err := client.query("POST", kRefreshTokenEndpoint, []byte(jsonData), QueryDelayPolicy{
+	response, err := client.query(ctx, "POST", kRefreshTokenEndpoint, []byte(jsonData), QueryDelayPolicy{
 		sleepDuration: client.Config.LogInEmailValidationRequestsPeriod.Duration,
 		randomSleep:   true,
 	})
@@ -202,8 +251,9 @@ func (client *TooGooToGoClient) refreshToken() error {
 
 	err = client.setRefreshedTokenData(response.Body)
 	if err != nil {
-		return fmt.Errorf("error in client.setRefreshedTokenData: %w\n", err)
+		return NewMalformedResponseError(kRefreshTokenEndpoint, response, err)
 	}
+	client.metrics.AddTokenRefresh()
 
 	err = client.writeAuthorizationDataToFile()
 	if err != nil {
@@ -229,8 +279,13 @@ func (client *TooGooToGoClient) writeAuthorizationDataToFile() error {
 	return nil
 }
 
+// authorizationFileName returns the file storing the authorization data of given account.
+func authorizationFileName(email string) string {
+	return fmt.Sprintf("secrets/tooGoodToGoClient.%v.latest.json", email)
+}
+
 func (client *TooGooToGoClient) latestAuthorizationFileName() string {
-	return fmt.Sprintf("secrets/tooGoodToGoClient.%v.latest.json", client.emailAccount())
+	return authorizationFileName(client.emailAccount())
 }
 
 func (client *TooGooToGoClient) removeLatestAuthorizationFileName() {
@@ -267,8 +322,24 @@ func (client *TooGooToGoClient) readAuthorizationDataFromLatestFile() error {
 	return nil
 }
 
-func (client *TooGooToGoClient) logIn() error {
+// readAuthorizationData reads the authorization data saved for given account, without any query.
+func readAuthorizationData(config *TooGoodToGoConfig, email string) (*TooGooToGoClient, error) {
+	authData := &TooGooToGoClient{Config: config}
+	fileData, err := os.ReadFile(authorizationFileName(email))
+	if err != nil {
+		return authData, err
+	}
+	err = json.Unmarshal(fileData, authData)
+	if err != nil {
+		return authData, fmt.Errorf("error from json.Unmarshal: %w", err)
+	}
+	return authData, nil
+}
+
+func (client *TooGooToGoClient) logIn(ctx context.Context) error {
 	glog.Printf("too good to go log in for %v...\n", client.emailAccount())
+	// the other goroutines would log in concurrently if the client was released while waiting for the validation
+	defer client.holdSharedLock()()
 
 	jsonDataBeg := fmt.Sprintf(`{
 		"device_type": "ANDROID",
@@ -278,7 +349,7 @@ func (client *TooGooToGoClient) logIn() error {
 
 	authData := jsonDataBeg + "}"
 
-	response, err := client.query("POST", kAuthByEmailEndpoint, []byte(authData), QueryDelayPolicy{
+	response, err := client.query(ctx, "POST", kAuthByEmailEndpoint, []byte(authData), QueryDelayPolicy{
 		sleepDuration: client.Config.LogInEmailValidationRequestsPeriod.Duration,
 		randomSleep:   true,
 	})
@@ -289,12 +360,12 @@ func (client *TooGooToGoClient) logIn() error {
 	var parsedResponse map[string]string
 	err = json.Unmarshal(response.Body, &parsedResponse)
 	if err != nil {
-		return fmt.Errorf("error from json.Unmarshal: %w", err)
+		return NewMalformedResponseError(kAuthByEmailEndpoint, response, err)
 	}
 
 	state, hasState := parsedResponse["state"]
 	if !hasState {
-		return fmt.Errorf("unexpected response %v\n", parsedResponse)
+		return NewMalformedResponseError(kAuthByEmailEndpoint, response, fmt.Errorf("expected field 'state' in response"))
 	}
 
 	if state == "TERMS" {
@@ -306,16 +377,17 @@ func (client *TooGooToGoClient) logIn() error {
 
 	pollingId, hasPollingId := parsedResponse["polling_id"]
 	if !hasPollingId {
-		return fmt.Errorf("expected field 'polling_id' in response %v", parsedResponse)
+		return NewMalformedResponseError(kAuthByEmailEndpoint, response, fmt.Errorf("expected field 'polling_id' in response"))
 	}
 	jsonDataPolling := jsonDataBeg + fmt.Sprintf(`, "request_polling_id": "%v"}`, pollingId)
 
-	err = client.initiateLogin(jsonDataPolling)
+	err = client.initiateLogin(ctx, jsonDataPolling)
 	if err != nil {
 		return fmt.Errorf("error from initiateLogin: %w", err)
 	}
 
 	client.LastLogInRefreshedTime = time.Now()
+	client.metrics.AddLogIn()
 
 	glog.Printf("logged in successfully\n")
 
@@ -324,9 +396,9 @@ func (client *TooGooToGoClient) logIn() error {
 	return nil
 }
 
-func (client *TooGooToGoClient) ensureAuthDataValidity() error {
+func (client *TooGooToGoClient) ensureAuthDataValidity(ctx context.Context) error {
 	if client.IsLoggedIn() {
-		return client.refreshToken()
+		return client.refreshToken(ctx)
 	}
 
 	err := client.readAuthorizationDataFromLatestFile()
@@ -343,16 +415,16 @@ func (client *TooGooToGoClient) ensureAuthDataValidity() error {
 		client.resetAuthData()
 	}
 
-	err = client.logIn()
+	err = client.logIn(ctx)
 	if err != nil {
 		return fmt.Errorf("error from client.logIn: %w", err)
 	}
 	return nil
 }
 
-func (client *TooGooToGoClient) setUserId() error {
+func (client *TooGooToGoClient) setUserId(ctx context.Context) error {
 	// Should be logged in
-	response, err := client.query("POST", kApiUserInformation, []byte{}, QueryDelayPolicy{})
+	response, err := client.query(ctx, "POST", kApiUserInformation, []byte{}, QueryDelayPolicy{})
 	if err != nil {
 		return fmt.Errorf("error from client.query: %w", err)
 	}
@@ -360,15 +432,19 @@ func (client *TooGooToGoClient) setUserId() error {
 	var parsedBody map[string]interface{}
 	err = json.Unmarshal(response.Body, &parsedBody)
 	if err != nil {
-		return fmt.Errorf("error from json.Unmarshal: %w", err)
+		return NewMalformedResponseError(kApiUserInformation, response, err)
 	}
 
-	client.UserId = parsedBody["user_id"].(string)
+	userId, hasUserId := parsedBody["user_id"].(string)
+	if !hasUserId {
+		return NewMalformedResponseError(kApiUserInformation, response, fmt.Errorf("expected field 'user_id' in response"))
+	}
+	client.UserId = userId
 
 	return nil
 }
 
-func (client *TooGooToGoClient) initiateLogin(jsonDataPolling string) error {
+func (client *TooGooToGoClient) initiateLogin(ctx context.Context, jsonDataPolling string) error {
 	initiateLoginTime := time.Now()
 	timeoutTime := initiateLoginTime.Add(client.Config.LogInEmailValidationTimeoutDuration.Duration)
 
@@ -380,7 +456,7 @@ func (client *TooGooToGoClient) initiateLogin(jsonDataPolling string) error {
 	}
 
 	for timeoutTime.After(time.Now()) {
-		response, err := client.query("POST", kAuthByRequestPollingId, []byte(jsonDataPolling), queryDelayPolicy)
+		response, err := client.query(ctx, "POST", kAuthByRequestPollingId, []byte(jsonDataPolling), queryDelayPolicy)
 		if err != nil {
 			return fmt.Errorf("error from client.Query: %w", err)
 		}
@@ -388,10 +464,10 @@ func (client *TooGooToGoClient) initiateLogin(jsonDataPolling string) error {
 		if len(response.Body) > 0 {
 			err = client.setRefreshedTokenData(response.Body)
 			if err != nil {
-				return fmt.Errorf("error from client.setRefreshedTokenData: %w", err)
+				return NewMalformedResponseError(kAuthByRequestPollingId, response, err)
 			}
 
-			err = client.setUserId()
+			err = client.setUserId(ctx)
 			if err != nil {
 				return fmt.Errorf("error from client.setUserId: %w", err)
 			}
@@ -407,6 +483,12 @@ func (client *TooGooToGoClient) initiateLogin(jsonDataPolling string) error {
 	return fmt.Errorf("authentication validation timeout")
 }
 
+// userScopedParameters are query parameters holding the user id of current account,
+// updated before each attempt of the query as retries may happen after switching to another account.
+type userScopedParameters interface {
+	setUserId(userId string)
+}
+
 type ItemParameters struct {
 	UserId        string   `json:"user_id"`
 	Origin        Location `json:"origin"`
@@ -418,68 +500,272 @@ type ItemParameters struct {
 	WithStockOnly bool     `json:"with_stock_only"`
 }
 
-func (client *TooGooToGoClient) ListStores() ([]Store, error) {
+func (params *ItemParameters) setUserId(userId string) {
+	params.UserId = userId
+}
+
+// ListStores queries the items of all configured searches, one after the other.
+// Stores found by several searches are returned once, tagged with the names of all matching searches.
+func (client *TooGooToGoClient) ListStores(ctx context.Context) ([]Store, error) {
+	stores := []Store{}
+	storePosById := make(map[string]int)
+
+	for _, search := range client.Config.SearchConfig.searches() {
+		searchStores, err := client.SearchStores(ctx, search)
+		if err != nil {
+			return []Store{}, fmt.Errorf("error from client.SearchStores for search '%v': %w", search.Name, err)
+		}
+		for _, store := range searchStores {
+			storePos, found := storePosById[store.Id]
+			if !found {
+				storePos = len(stores)
+				storePosById[store.Id] = storePos
+				stores = append(stores, store)
+			}
+			if len(search.Name) > 0 {
+				stores[storePos].SearchNames = append(stores[storePos].SearchNames, search.Name)
+			}
+		}
+	}
+
+	if len(stores) > 0 {
+		glog.Printf("found %v store(s), first is %v\n", len(stores), stores[0].Name)
+	}
+
+	return stores, nil
+}
+
+// SearchStores queries the items around the origin of given search, page after page until the results are exhausted
+// or the configured maximum number of pages is reached. Stores are de-duplicated by item id.
+func (client *TooGooToGoClient) SearchStores(ctx context.Context, search Search) ([]Store, error) {
 	searchConfig := &client.Config.SearchConfig
 
 	params := ItemParameters{
 		UserId:        client.UserId,
-		Origin:        searchConfig.Origin,
-		Radius:        searchConfig.RadiusInKm,
+		Origin:        search.Origin,
+		Radius:        search.RadiusInKm,
 		PageSize:      searchConfig.NbMaxResults,
-		Page:          1,
 		Discover:      false,
-		FavoritesOnly: searchConfig.FavoritesOnly,
-		WithStockOnly: searchConfig.WithStockOnly,
+		FavoritesOnly: search.FavoritesOnly,
+		WithStockOnly: search.WithStockOnly,
+	}
+
+	nbMaxPages := searchConfig.nbMaxPages()
+
+	stores := []Store{}
+	storeIds := make(map[string]bool)
+
+	for page := 1; page <= nbMaxPages; page++ {
+		params.Page = page
+
+		response, err := client.postQueryWithRandomSleep(ctx, kApiItemEndpoint, &params)
+		if err != nil {
+			return []Store{}, fmt.Errorf("error from client.postQueryWithRandomSleep: %w", err)
+		}
+
+		pageStores, err := NewStoresFromListStoresResponse(response.Body)
+		if err != nil {
+			return []Store{}, NewMalformedResponseError(kApiItemEndpoint, response, err)
+		}
+
+		for _, store := range pageStores {
+			if !storeIds[store.Id] {
+				storeIds[store.Id] = true
+				stores = append(stores, store)
+			}
+		}
+
+		if params.PageSize <= 0 || len(pageStores) < params.PageSize {
+			break
+		}
+		if page == nbMaxPages {
+			glog.Printf("reached maximum number of pages %v, next stores are ignored\n", nbMaxPages)
+		}
 	}
 
-	response, err := client.postQueryWithRandomSleep(kApiItemEndpoint, params)
+	return stores, nil
+}
+
+type ItemDetailParameters struct {
+	UserId string   `json:"user_id"`
+	Origin Location `json:"origin"`
+}
+
+func (params *ItemDetailParameters) setUserId(userId string) {
+	params.UserId = userId
+}
+
+// GetItem returns the store selling given item, with its current stock and pickup window.
+// It is much cheaper than listing all the stores around to follow a few specific ones.
+func (client *TooGooToGoClient) GetItem(ctx context.Context, itemId string) (Store, error) {
+	params := ItemDetailParameters{
+		UserId: client.UserId,
+		Origin: client.Config.SearchConfig.origin(),
+	}
+
+	path := kApiItemEndpoint + itemId
+
+	response, err := client.postQueryWithRandomSleep(ctx, path, &params)
 	if err != nil {
-		return []Store{}, fmt.Errorf("error from client.postQueryWithRandomSleep: %w", err)
+		return Store{}, fmt.Errorf("error from client.postQueryWithRandomSleep: %w", err)
 	}
 
-	stores, err := NewStoresFromListStoresResponse(response.Body)
+	store, err := NewStoreFromItemDetailResponse(response.Body)
 	if err != nil {
-		return stores, fmt.Errorf("error from NewStoresFromListStoresResponse: %w", err)
+		return store, NewMalformedResponseError(path, response, err)
 	}
 
-	if len(stores) > 0 {
-		glog.Printf("found %v store(s), first is %v\n", len(stores), stores[0].Name)
+	glog.Printf("item %v of %v has %v available bag(s)\n", itemId, store.Name, store.AvailableBags)
+
+	return store, nil
+}
+
+type SetFavoriteParameters struct {
+	IsFavorite bool `json:"is_favorite"`
+}
+
+// SetFavorite adds given item to the favorites of current account, or removes it if isFavorite is false.
+func (client *TooGooToGoClient) SetFavorite(ctx context.Context, itemId string, isFavorite bool) error {
+	params := SetFavoriteParameters{
+		IsFavorite: isFavorite,
 	}
 
-	return stores, err
+	path := fmt.Sprintf("%v%v/setFavorite", kApiItemEndpoint, itemId)
+
+	account := client.emailAccount()
+	_, err := client.postQueryWithRandomSleep(ctx, path, params)
+	if err != nil {
+		return fmt.Errorf("error from client.postQueryWithRandomSleep: %w", err)
+	}
+	err = client.checkSameAccount(account, "setting favorite "+itemId)
+	if err != nil {
+		return err
+	}
+
+	glog.Printf("set favorite %v of item %v for account %v\n", isFavorite, itemId, client.emailAccount())
+
+	return nil
+}
+
+// ListFavorites returns the favorite stores of current account, with or without stock,
+// within kFavoritesSearchRadiusInKm of the origin of the first configured search.
+func (client *TooGooToGoClient) ListFavorites(ctx context.Context) ([]Store, error) {
+	account := client.emailAccount()
+	favorites, err := client.SearchStores(ctx, Search{
+		Origin:        client.Config.SearchConfig.origin(),
+		RadiusInKm:    kFavoritesSearchRadiusInKm,
+		FavoritesOnly: true,
+		WithStockOnly: false,
+	})
+	if err != nil {
+		return favorites, fmt.Errorf("error from client.SearchStores: %w", err)
+	}
+	err = client.checkSameAccount(account, "listing favorites")
+	if err != nil {
+		return []Store{}, err
+	}
+	return favorites, nil
 }
 
 type OpenedOrdersParameters struct {
 	UserId string `json:"user_id"`
 }
 
-func (client *TooGooToGoClient) ListOpenedOrders() ([]Order, error) {
-	if !client.canListOpenedOrders() {
-		return []Order{}, nil
-	}
+func (params *OpenedOrdersParameters) setUserId(userId string) {
+	params.UserId = userId
+}
 
+func (client *TooGooToGoClient) ListOpenedOrders(ctx context.Context) ([]Order, error) {
+	account := client.emailAccount()
 	params := OpenedOrdersParameters{
 		UserId: client.UserId,
 	}
 
-	response, err := client.postQueryWithRandomSleep(kApiListOpenedOrders, params)
+	response, err := client.postQueryWithRandomSleep(ctx, kApiListOpenedOrders, &params)
 	if err != nil {
 		return []Order{}, fmt.Errorf("error from client.postQueryWithRandomSleep: %w", err)
 	}
+	err = client.checkSameAccount(account, "listing opened orders")
+	if err != nil {
+		return []Order{}, err
+	}
 
 	openedOrders, err := NewOrdersFromListOrdersResponse(response.Body)
 	if err != nil {
-		return openedOrders, fmt.Errorf("error from NewOrdersFromListOrdersResponse: %w", err)
+		return openedOrders, NewMalformedResponseError(kApiListOpenedOrders, response, err)
 	}
 
-	if len(openedOrders) > 0 {
-		glog.Printf("you have %v order(s) to pickup, don't forget them:\n", len(openedOrders))
-		for orderPos, openedOrder := range openedOrders {
-			glog.Printf("- Order %v - %v\n", orderPos+1, openedOrder)
+	glog.Printf("found %v opened order(s)\n", len(openedOrders))
+
+	return openedOrders, nil
+}
+
+type Paging struct {
+	Page int `json:"page"`
+	Size int `json:"size"`
+}
+
+type InactiveOrdersParameters struct {
+	Paging Paging `json:"paging"`
+	UserId string `json:"user_id"`
+}
+
+func (params *InactiveOrdersParameters) setUserId(userId string) {
+	params.UserId = userId
+}
+
+type inactiveOrdersPageResponse struct {
+	HasMore bool `json:"has_more"`
+}
+
+// ListOrderHistory returns the past orders of current account, most recent first.
+// Pages are queried until the last one, or until a page contains an order for which isKnown returns true,
+// so that the history can be updated incrementally.
+func (client *TooGooToGoClient) ListOrderHistory(ctx context.Context, isKnown func(orderId string) bool) ([]Order, error) {
+	account := client.emailAccount()
+	pastOrders := []Order{}
+
+	for page := 0; ; page++ {
+		params := InactiveOrdersParameters{
+			Paging: Paging{Page: page, Size: kOrderHistoryPageSize},
+			UserId: client.UserId,
+		}
+
+		response, err := client.postQueryWithRandomSleep(ctx, kApiListInactiveOrders, &params)
+		if err != nil {
+			return pastOrders, fmt.Errorf("error from client.postQueryWithRandomSleep: %w", err)
+		}
+		err = client.checkSameAccount(account, "listing order history")
+		if err != nil {
+			return pastOrders, err
+		}
+
+		orders, err := NewOrdersFromListOrdersResponse(response.Body)
+		if err != nil {
+			return pastOrders, NewMalformedResponseError(kApiListInactiveOrders, response, err)
+		}
+		var pageResponse inactiveOrdersPageResponse
+		err = json.Unmarshal(response.Body, &pageResponse)
+		if err != nil {
+			return pastOrders, NewMalformedResponseError(kApiListInactiveOrders, response, err)
+		}
+
+		reachedKnownOrder := false
+		for _, order := range orders {
+			if isKnown(order.Id) {
+				reachedKnownOrder = true
+			} else {
+				pastOrders = append(pastOrders, order)
+			}
+		}
+		if reachedKnownOrder || !pageResponse.HasMore || len(orders) == 0 {
+			break
 		}
 	}
 
-	return openedOrders, err
+	glog.Printf("found %v new past order(s) for account %v\n", len(pastOrders), account)
+
+	return pastOrders, nil
 }
 
 type PaymentMethodsParameters struct {
@@ -491,7 +777,7 @@ type PaymentMethodRequestItem struct {
 	PaymentTypes    []PaymentType   `json:"payment_types"`
 }
 
-func (client *TooGooToGoClient) PaymentMethods(paymentProvider PaymentProvider) ([]PaymentMethod, error) {
+func (client *TooGooToGoClient) PaymentMethods(ctx context.Context, paymentProvider PaymentProvider) ([]PaymentMethod, error) {
 	params := PaymentMethodsParameters{
 		PaymentMethodRequestItem: []PaymentMethodRequestItem{
 			{
@@ -505,14 +791,19 @@ func (client *TooGooToGoClient) PaymentMethods(paymentProvider PaymentProvider)
 		},
 	}
 
-	response, err := client.postQueryWithRandomSleep(kApiPaymentMethods, params)
+	account := client.emailAccount()
+	response, err := client.postQueryWithRandomSleep(ctx, kApiPaymentMethods, params)
 	if err != nil {
 		return []PaymentMethod{}, fmt.Errorf("error from client.postQueryWithRandomSleep: %w", err)
 	}
+	err = client.checkSameAccount(account, "listing payment methods")
+	if err != nil {
+		return []PaymentMethod{}, err
+	}
 
 	paymentMethods, err := NewPaymentMethodsFromPaymentMethodsResponse(response.Body)
 	if err != nil {
-		return paymentMethods, fmt.Errorf("error from NewPaymentMethodsFromPaymentMethodsResponse: %w", err)
+		return paymentMethods, NewMalformedResponseError(kApiPaymentMethods, response, err)
 	}
 
 	if len(paymentMethods) > 0 {
@@ -529,10 +820,10 @@ type CreateOrderParameters struct {
 	NbBags int `json:"item_count"`
 }
 
-func (client *TooGooToGoClient) ReserveOrder(store Store, nbBags int) (ReservedOrder, error) {
+func (client *TooGooToGoClient) ReserveOrder(ctx context.Context, store Store, nbBags int) (ReservedOrder, error) {
 	var reservedOrder ReservedOrder
 	if store.AvailableBags < nbBags {
-		return reservedOrder, fmt.Errorf("not enough available bags for %v", store)
+		return reservedOrder, fmt.Errorf("%w for %v", ErrNotEnoughBags, store)
 	}
 	params := CreateOrderParameters{
 		NbBags: nbBags,
@@ -540,14 +831,19 @@ func (client *TooGooToGoClient) ReserveOrder(store Store, nbBags int) (ReservedO
 
 	path := fmt.Sprintf("%v/%v", kApiCreateOrder, store.Id)
 
-	response, err := client.postQueryWithoutSleep(path, params)
+	account := client.emailAccount()
+	response, err := client.postQueryWithoutSleep(ctx, path, params)
 	if err != nil {
 		return reservedOrder, fmt.Errorf("error from client.postQueryWithoutSleep: %w", err)
 	}
+	err = client.checkSameAccount(account, "reserving store "+store.Id)
+	if err != nil {
+		return reservedOrder, err
+	}
 
 	reservedOrder, err = NewReservedOrderFromCreateOrder(response.Body)
 	if err != nil {
-		return reservedOrder, fmt.Errorf("error from NewReservedOrderFromCreateOrder: %w", err)
+		return reservedOrder, NewMalformedResponseError(path, response, err)
 	}
 
 	return reservedOrder, nil
@@ -557,25 +853,64 @@ type CancelOrderParameters struct {
 	CancelReason int `json:"cancel_reason_id"`
 }
 
-func (client *TooGooToGoClient) CancelOrder(orderId string) error {
+func (client *TooGooToGoClient) CancelOrder(ctx context.Context, orderId string) error {
 	params := CancelOrderParameters{
 		CancelReason: 1,
 	}
 
 	path := fmt.Sprintf("order/v7/%v/abort", orderId)
 
-	response, err := client.postQueryWithRandomSleep(path, params)
+	account := client.emailAccount()
+	response, err := client.postQueryWithRandomSleep(ctx, path, params)
 	if err != nil {
-		return fmt.Errorf("error from client.postQueryWithoutSleep: %w", err)
+		return fmt.Errorf("error from client.postQueryWithRandomSleep: %w", err)
+	}
+	err = client.checkSameAccount(account, "cancelling order "+orderId)
+	if err != nil {
+		return err
 	}
 
-	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("http error %v, with body: %v", response.StatusCode, response.Body)
+	err = CheckCancelOrderResponse(response.Body)
+	if err != nil {
+		return NewMalformedResponseError(path, response, err)
 	}
 
+	glog.Printf("order %v cancelled\n", orderId)
+
 	return nil
 }
 
+// CancelOpenedOrder cancels given opened order if its cancellation deadline has not passed yet.
+// The returned error wraps ErrOrderNotCancellable if the order is not opened or cannot be cancelled anymore.
+func (client *TooGooToGoClient) CancelOpenedOrder(ctx context.Context, orderId string) (Order, error) {
+	account := client.emailAccount()
+	openedOrders, err := client.ListOpenedOrders(ctx)
+	if err != nil {
+		return Order{}, fmt.Errorf("error from client.ListOpenedOrders: %w", err)
+	}
+
+	for _, order := range openedOrders {
+		if order.Id != orderId {
+			continue
+		}
+		if !order.IsCancellable(time.Now()) {
+			return order, fmt.Errorf("%w: order %v could only be cancelled until %v", ErrOrderNotCancellable, orderId, order.CancelUntil)
+		}
+		// the opened orders may have been listed after a switch to another account
+		err = client.checkSameAccount(account, "cancelling order "+orderId)
+		if err != nil {
+			return order, err
+		}
+		err = client.CancelOrder(ctx, orderId)
+		if err != nil {
+			return order, fmt.Errorf("error from client.CancelOrder: %w", err)
+		}
+		return order, nil
+	}
+
+	return Order{}, fmt.Errorf("%w: order %v is not opened", ErrOrderNotCancellable, orderId)
+}
+
 type PayOrderParameters struct {
 	Authorization Authorization `json:"authorization"`
 }
@@ -594,7 +929,7 @@ type AuthorizationPayload struct {
 	SavePaymentMethod string      `json:"save_payment_method,omitempty"`
 }
 
-func (client *TooGooToGoClient) PayOrder(orderId string, paymentMethod PaymentMethod) (OrderPayment, error) {
+func (client *TooGooToGoClient) PayOrder(ctx context.Context, orderId string, paymentMethod PaymentMethod) (OrderPayment, error) {
 	params := PayOrderParameters{
 		Authorization: Authorization{
 			AuthorizationPayload: AuthorizationPayload{
@@ -612,38 +947,83 @@ func (client *TooGooToGoClient) PayOrder(orderId string, paymentMethod PaymentMe
 
 	var orderPayment OrderPayment
 
-	response, err := client.postQueryWithRandomSleep(path, params)
+	account := client.emailAccount()
+	response, err := client.postQueryWithRandomSleep(ctx, path, params)
 	if err != nil {
 		return orderPayment, fmt.Errorf("error from client.postQueryWithoutSleep: %w", err)
 	}
+	err = client.checkSameAccount(account, "paying order "+orderId)
+	if err != nil {
+		return orderPayment, err
+	}
 
 	orderPayment, err = NewOrderPaymentFromPayOrderResponse(response.Body)
 	if err != nil {
-		return orderPayment, fmt.Errorf("error from NewOrderPaymentFromPayOrderResponse: %w", err)
+		return orderPayment, NewMalformedResponseError(path, response, err)
 	}
 
 	glog.Printf("order payment %v created\n", orderPayment)
 
-	paymentInfoResponse, err := client.postQueryWithRandomSleep(fmt.Sprintf("payment/v3/%v", orderPayment.Id), []byte{})
+	return orderPayment, nil
+}
+
+// PaymentStatus queries the current status of given payment.
+func (client *TooGooToGoClient) PaymentStatus(ctx context.Context, paymentId string) (PaymentStatus, error) {
+	path := fmt.Sprintf("%v/%v", kApiPayment, paymentId)
+
+	account := client.emailAccount()
+	response, err := client.postQueryWithoutSleep(ctx, path, []byte{})
 	if err != nil {
-		glog.Printf("error from client.postQueryWithRandomSleep: %v\n", err)
-		err = nil
+		return PaymentStatus{}, fmt.Errorf("error from client.postQueryWithoutSleep: %w", err)
+	}
+	err = client.checkSameAccount(account, "querying payment "+paymentId)
+	if err != nil {
+		return PaymentStatus{}, err
 	}
 
-	glog.Printf("payment information from order payment is %v\n", paymentInfoResponse)
+	paymentStatus, err := NewPaymentStatusFromPaymentResponse(response.Body)
+	if err != nil {
+		return paymentStatus, NewMalformedResponseError(path, response, err)
+	}
 
-	return orderPayment, nil
+	return paymentStatus, nil
+}
+
+// WaitForPayment polls the status of given payment until it is terminal or timeout expires.
+// Returns an error wrapping ErrPaymentFailed if the payment is not successful, or ErrPaymentTimeout if it is still pending.
+func (client *TooGooToGoClient) WaitForPayment(ctx context.Context, paymentId string, timeout time.Duration) (PaymentStatus, error) {
+	pollingPeriod := client.Config.PaymentConfig.paymentPollingPeriod()
+	deadline := time.Now().Add(timeout)
+	for {
+		paymentStatus, err := client.PaymentStatus(ctx, paymentId)
+		if err != nil {
+			return paymentStatus, fmt.Errorf("error from client.PaymentStatus: %w", err)
+		}
+		if paymentStatus.State.IsSuccessful() {
+			return paymentStatus, nil
+		}
+		if paymentStatus.State.IsTerminal() {
+			return paymentStatus, fmt.Errorf("%w: %v", ErrPaymentFailed, paymentStatus.String())
+		}
+		if !time.Now().Add(pollingPeriod).Before(deadline) {
+			return paymentStatus, fmt.Errorf("%w: %v after %v", ErrPaymentTimeout, paymentStatus.String(), timeout)
+		}
+		err = client.pause(ctx, pollingPeriod)
+		if err != nil {
+			return paymentStatus, err
+		}
+	}
 }
 
-func (client *TooGooToGoClient) postQueryWithRandomSleep(path string, paramObject any) (QueryResponse, error) {
-	return client.postQuery(path, paramObject, QueryDelayPolicy{
+func (client *TooGooToGoClient) postQueryWithRandomSleep(ctx context.Context, path string, paramObject any) (QueryResponse, error) {
+	return client.postQuery(ctx, path, paramObject, QueryDelayPolicy{
 		sleepDuration: client.Config.AverageRequestsPeriod.Duration,
 		randomSleep:   true,
 	})
 }
 
-func (client *TooGooToGoClient) postQueryWithoutSleep(path string, paramObject any) (QueryResponse, error) {
-	return client.postQuery(path, paramObject, QueryDelayPolicy{})
+func (client *TooGooToGoClient) postQueryWithoutSleep(ctx context.Context, path string, paramObject any) (QueryResponse, error) {
+	return client.postQuery(ctx, path, paramObject, QueryDelayPolicy{})
 }
 
 type QueryDelayPolicy struct {
@@ -651,19 +1031,25 @@ type QueryDelayPolicy struct {
 	randomSleep   bool
 }
 
-func (client *TooGooToGoClient) postQuery(path string, paramObject any, queryDelayPolicy QueryDelayPolicy) (QueryResponse, error) {
+func (client *TooGooToGoClient) postQuery(ctx context.Context, path string, paramObject any, queryDelayPolicy QueryDelayPolicy) (QueryResponse, error) {
 	var ret QueryResponse
-	err := client.ensureAuthDataValidity()
+	err := client.ensureAuthDataValidity(ctx)
 	if err != nil {
 		return ret, fmt.Errorf("error from client.LoginOrRefreshToken: %w", err)
 	}
 
-	jsonParams, err := json.Marshal(paramObject)
-	if err != nil {
-		return ret, fmt.Errorf("error from json.Marshal: %w", err)
+	newBody := func() ([]byte, error) {
+		if userScopedParams, isUserScoped := paramObject.(userScopedParameters); isUserScoped {
+			userScopedParams.setUserId(client.UserId)
+		}
+		jsonParams, err := json.Marshal(paramObject)
+		if err != nil {
+			return nil, fmt.Errorf("error from json.Marshal: %w", err)
+		}
+		return jsonParams, nil
 	}
 
-	ret, err = client.query("POST", path, jsonParams, queryDelayPolicy)
+	ret, err = client.queryWithBody(ctx, "POST", path, newBody, queryDelayPolicy)
 	if err != nil {
 		return ret, fmt.Errorf("error from client.Query: %w", err)
 	}
@@ -688,6 +1074,7 @@ func (client *TooGooToGoClient) addHeaders(req *http.Request) {
 type QueryResponse struct {
 	Body       []byte
 	StatusCode int
+	Header     http.Header
 }
 
 func printHeaders(url *url.URL, title string, header *http.Header) {
@@ -697,22 +1084,71 @@ func printHeaders(url *url.URL, title string, header *http.Header) {
 	}
 }
 
-func (client *TooGooToGoClient) query(method, path string, body []byte, queryDelayPolicy QueryDelayPolicy) (QueryResponse, error) {
-	url, err := url.JoinPath(kBaseUrl, path)
+func (client *TooGooToGoClient) baseUrl() string {
+	if len(client.Config.BaseUrl) > 0 {
+		return client.Config.BaseUrl
+	}
+	return kBaseUrl
+}
+
+// query sends the request with given body, see queryWithBody.
+func (client *TooGooToGoClient) query(ctx context.Context, method, path string, body []byte, queryDelayPolicy QueryDelayPolicy) (QueryResponse, error) {
+	return client.queryWithBody(ctx, method, path, func() ([]byte, error) { return body, nil }, queryDelayPolicy)
+}
+
+// queryWithBody sends the request and handles the recoverable errors (expired log in, captcha, too many requests),
+// retrying up to the configured maximum number of retries.
+// The body is built by newBody before each attempt, as the account may have been switched by the previous one.
+// Callers of account scoped operations should check that the account did not change, see checkSameAccount.
+func (client *TooGooToGoClient) queryWithBody(ctx context.Context, method, path string, newBody func() ([]byte, error), queryDelayPolicy QueryDelayPolicy) (QueryResponse, error) {
+	maxRetries := client.Config.RetryConfig.maxRetries()
+	for nbRetries := 0; ; nbRetries++ {
+		// before building the request, as another goroutine may switch the account during the pause of a shared client
+		err := client.sleep(ctx, queryDelayPolicy)
+		if err != nil {
+			return QueryResponse{}, fmt.Errorf("error from client.sleep: %w", err)
+		}
+		body, err := newBody()
+		if err != nil {
+			return QueryResponse{}, err
+		}
+		ret, err := client.queryOnce(ctx, method, path, body)
+		if err != nil {
+			return ret, err
+		}
+
+		// captcha challenges are usually returned with a 403 status code, check them first
+		retry, err := client.checkCaptcha(ctx, path, ret, nbRetries)
+		if !retry && err == nil {
+			retry, err = client.checkStatusCode(ctx, path, ret, nbRetries)
+		}
+		if !retry {
+			if err == nil {
+				client.setCookie(&ret.Header)
+			}
+			return ret, err
+		}
+		if nbRetries == maxRetries {
+			glog.Printf("giving up %v after %v retries\n", path, nbRetries)
+			return ret, err
+		}
+	}
+}
+
+func (client *TooGooToGoClient) queryOnce(ctx context.Context, method, path string, body []byte) (QueryResponse, error) {
+	url, err := url.JoinPath(client.baseUrl(), path)
 	var ret QueryResponse
 	if err != nil {
 		return ret, fmt.Errorf("error from url.JoinPath: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
 	if err != nil {
 		return ret, fmt.Errorf("error from http.NewRequest: %w", err)
 	}
 
 	client.addHeaders(req)
 
-	client.sleep(queryDelayPolicy)
-
 	if client.verbose && len(req.Header) > 0 {
 		printHeaders(req.URL, "request", &req.Header)
 	}
@@ -720,36 +1156,36 @@ func (client *TooGooToGoClient) query(method, path string, body []byte, queryDel
 	glog.Printf("%v %v\n", req.Method, req.URL)
 	res, err := client.httpClient.Do(req)
 	if err != nil {
+		client.metrics.AddRequest(path, 0)
 		return ret, fmt.Errorf("error from client.Client.Do: %w", err)
 	}
 	defer res.Body.Close()
+	client.metrics.AddRequest(path, res.StatusCode)
 
 	if client.verbose {
 		printHeaders(req.URL, "response", &res.Header)
 	}
 
-	retry, err := client.checkStatusCode(res.StatusCode)
-	if retry {
-		return client.query(method, path, body, queryDelayPolicy)
-	}
-
 	ret.StatusCode = res.StatusCode
+	ret.Header = res.Header
 
 	ret.Body, err = DecompressAllBody(res)
 	if err != nil {
 		return ret, fmt.Errorf("error from DecompressAllBody: %w", err)
 	}
 
-	retry, err = client.checkCaptcha(ret.Body)
-	if retry {
-		return client.query(method, path, body, queryDelayPolicy)
-	}
-
-	client.setCookie(&res.Header)
-
 	return ret, nil
 }
 
+// checkSameAccount returns an error wrapping ErrAccountSwitched if current account is not given one anymore,
+// which happens when a query backs off, so that account scoped operations abort instead of mixing accounts.
+func (client *TooGooToGoClient) checkSameAccount(account, operation string) error {
+	if client.emailAccount() != account {
+		return fmt.Errorf("%w from %v to %v while %v", ErrAccountSwitched, account, client.emailAccount(), operation)
+	}
+	return nil
+}
+
 func (client *TooGooToGoClient) setCookie(header *http.Header) {
 	cookies, hasSetCookie := (*header)["Set-Cookie"]
 	if hasSetCookie {
@@ -757,27 +1193,66 @@ func (client *TooGooToGoClient) setCookie(header *http.Header) {
 	}
 }
 
-func (client *TooGooToGoClient) checkStatusCode(statusCode int) (bool, error) {
-	switch statusCode {
+// checkStatusCode returns whether the query should be retried, and the error describing the response if not OK.
+func (client *TooGooToGoClient) checkStatusCode(ctx context.Context, path string, response QueryResponse, nbRetries int) (bool, error) {
+	switch response.StatusCode {
 	case http.StatusOK:
 		return false, nil
 	case http.StatusUnauthorized:
-		glog.Printf("http status %v received, login again\n", statusCode)
+		glog.Printf("http status %v received, login again\n", response.StatusCode)
 		client.removeLatestAuthorizationFileName()
 		// force re-login
-		err := client.logIn()
+		err := client.logIn(ctx)
+		if err != nil {
+			return false, NewApiError(ErrUnauthorized, path, response, err)
+		}
+		return true, NewApiError(ErrUnauthorized, path, response, nil)
+	case http.StatusTooManyRequests, http.StatusForbidden:
+		apiErr := NewApiError(ErrorKindFromStatusCode(response.StatusCode), path, response, nil)
+		glog.Printf("http status %v received for account %v\n", response.StatusCode, client.emailAccount())
+		retryAfter := parseRetryAfter(response.Header, time.Now())
+		if retryAfter > 0 {
+			client.blockedUntilPerAccount[client.currentAccountPos] = time.Now().Add(retryAfter)
+		}
+		err := client.backOff(ctx, nbRetries)
 		if err != nil {
-			return false, fmt.Errorf("error from client.logIn: %w", err)
+			apiErr.Err = err
+			return false, apiErr
 		}
-		return true, nil
+		return true, apiErr
 	default:
-		return false, fmt.Errorf("http status %v received\n", statusCode)
+		return false, NewApiError(ErrorKindFromStatusCode(response.StatusCode), path, response, nil)
+	}
+}
+
+// backOff switches to the next account (or waits for the end of the block period if there is only one),
+// then waits the exponential backoff corresponding to nbRetries.
+func (client *TooGooToGoClient) backOff(ctx context.Context, nbRetries int) error {
+	var err error
+	if len(client.Config.Accounts) > 1 {
+		err = client.switchToNextEmailAccount(ctx)
+		if err != nil {
+			return fmt.Errorf("error from client.switchToNextEmailAccount: %w", err)
+		}
+	} else {
+		err = client.waitUntilUnblocked(ctx)
+		if err != nil {
+			return fmt.Errorf("error from client.waitUntilUnblocked: %w", err)
+		}
+	}
+
+	backoffDuration := client.Config.RetryConfig.backoffDuration(nbRetries)
+	glog.Printf("backing off %v before retry %v\n", backoffDuration, nbRetries+1)
+	err = client.pause(ctx, backoffDuration)
+	if err != nil {
+		return fmt.Errorf("error from client.pause: %w", err)
 	}
+	return nil
 }
 
-func (client *TooGooToGoClient) checkCaptcha(uncompressedResponse []byte) (bool, error) {
+func (client *TooGooToGoClient) checkCaptcha(ctx context.Context, path string, response QueryResponse, nbRetries int) (bool, error) {
 	var parsedResponse map[string]string
-	err := json.Unmarshal(uncompressedResponse, &parsedResponse)
+	err := json.Unmarshal(response.Body, &parsedResponse)
 	if err != nil {
 		return false, nil
 	}
@@ -785,26 +1260,31 @@ func (client *TooGooToGoClient) checkCaptcha(uncompressedResponse []byte) (bool,
 	urlCaptcha, hasUrlCaptcha := parsedResponse["url"]
 	if hasUrlCaptcha && strings.HasPrefix(urlCaptcha, "https://geo.captcha-delivery.com") {
 		glog.Printf("captcha detected\n")
-		err = OpenBrowser(urlCaptcha)
+		client.metrics.AddCaptcha()
+		err = client.openBrowser(urlCaptcha)
 		if err != nil {
-			return false, fmt.Errorf("error from OpenBrowser: %w", err)
+			// no browser available (headless server for instance), switching account is enough
+			glog.Printf("error from client.openBrowser: %v\n", err)
 		}
-		err = client.switchToNextEmailAccount()
+		err = client.backOff(ctx, nbRetries)
 		if err != nil {
-			return false, fmt.Errorf("error from client.switchToNextEmailAccount: %w\n", err)
+			return false, NewApiError(ErrCaptcha, path, response, err)
 		}
-		return true, nil
+		return true, NewApiError(ErrCaptcha, path, response, nil)
 	}
 
 	return false, nil
 }
 
 func randomizeDuration(dur time.Duration) time.Duration {
-	const kMinRequestsPeriod = time.Second
-	maxRequestsPeriod := 2*dur - kMinRequestsPeriod
+	if dur <= 0 {
+		return 0
+	}
+	minRequestsPeriod := min(time.Second, dur)
+	maxRequestsPeriod := 2*dur - minRequestsPeriod
 	randomExtraDuration := time.Duration(rand.Int63n(maxRequestsPeriod.Nanoseconds()))
-	if randomExtraDuration < kMinRequestsPeriod {
-		return kMinRequestsPeriod
+	if randomExtraDuration < minRequestsPeriod {
+		return minRequestsPeriod
 	}
 	return randomExtraDuration
 }
@@ -813,37 +1293,100 @@ func (client *TooGooToGoClient) lastQueryTime() *time.Time {
 	return &client.lastQueryTimePerAccount[client.currentAccountPos]
 }
 
-func (client *TooGooToGoClient) sleep(queryDelayPolicy QueryDelayPolicy) {
-	nowTime := time.Now()
-	lastQueryTime := client.lastQueryTime()
-	if !lastQueryTime.IsZero() {
-		elapsedTimeSinceLastQuery := nowTime.Sub(*lastQueryTime)
-		queryDelay := queryDelayPolicy.sleepDuration
-		if queryDelayPolicy.randomSleep {
-			queryDelay = randomizeDuration(queryDelay)
+// sleep waits for the query delay since the last query of current account.
+func (client *TooGooToGoClient) sleep(ctx context.Context, queryDelayPolicy QueryDelayPolicy) error {
+	queryDelay := queryDelayPolicy.sleepDuration
+	if queryDelayPolicy.randomSleep {
+		queryDelay = randomizeDuration(queryDelay)
+	}
+	for {
+		// evaluated again after each pause, as other goroutines may have queried or switched the account meanwhile
+		lastQueryTime := client.lastQueryTime()
+		waitingTime := queryDelay - time.Since(*lastQueryTime)
+		if lastQueryTime.IsZero() || waitingTime <= 0 {
+			*lastQueryTime = time.Now()
+			return nil
 		}
-		waitingTime := queryDelay - elapsedTimeSinceLastQuery
-		if waitingTime > 0 {
-			time.Sleep(waitingTime)
-			nowTime = nowTime.Add(waitingTime)
+		err := client.pause(ctx, waitingTime)
+		if err != nil {
+			return fmt.Errorf("error from client.pause: %w", err)
 		}
 	}
-	*lastQueryTime = nowTime
 }
 
+// holdSharedLock keeps the shared lock of the client during its pauses, until the returned function is called.
+func (client *TooGooToGoClient) holdSharedLock() func() {
+	previousKeepSharedLock := client.keepSharedLock
+	client.keepSharedLock = true
+	return func() {
+		client.keepSharedLock = previousKeepSharedLock
+	}
+}
+
+// pause waits for given duration, unless ctx is done first.
+// A shared client is released meanwhile, and held again before returning even if ctx is done, as the caller releases it afterwards.
+func (client *TooGooToGoClient) pause(ctx context.Context, duration time.Duration) error {
+	if client.sharedLock == nil || client.keepSharedLock {
+		return sleepContext(ctx, duration)
+	}
+	<-client.sharedLock
+	err := sleepContext(ctx, duration)
+	client.sharedLock <- struct{}{}
+	return err
+}
+
+// canListOpenedOrders returns true at most once per ActiveOrdersReminderPeriod, to limit the opened orders queries.
 func (client *TooGooToGoClient) canListOpenedOrders() bool {
 	nowTime := time.Now()
-	if client.lastOpenedOrdersQueryTime.IsZero() {
-		client.lastOpenedOrdersQueryTime = nowTime
-		return false
-	}
-	if client.lastOpenedOrdersQueryTime.Add(client.Config.ActiveOrdersReminderPeriod.Duration).Before(nowTime) {
+	if client.lastOpenedOrdersQueryTime.IsZero() || client.lastOpenedOrdersQueryTime.Add(client.Config.ActiveOrdersReminderPeriod.Duration).Before(nowTime) {
 		client.lastOpenedOrdersQueryTime = nowTime
 		return true
 	}
 	return false
 }
 
+// AccountHealth is the state of a configured account, as known by the client.
+type AccountHealth struct {
+	Email              string    `json:"email"`
+	IsCurrent          bool      `json:"isCurrent"`
+	IsLoggedIn         bool      `json:"isLoggedIn"`
+	LastQueryTime      time.Time `json:"lastQueryTime"`      // zero if not queried yet
+	BlockedUntil       time.Time `json:"blockedUntil"`       // zero if not blocked
+	TokenRefreshedTime time.Time `json:"tokenRefreshedTime"` // zero if unknown
+	TokenValidUntil    time.Time `json:"tokenValidUntil"`    // zero if unknown
+}
+
+// AccountsHealth returns the state of all the configured accounts. The authorization data of the accounts not in use
+// are read from their files.
+func (client *TooGooToGoClient) AccountsHealth() []AccountHealth {
+	nowTime := time.Now()
+	accountsHealth := make([]AccountHealth, len(client.Config.Accounts))
+	for accountPos, account := range client.Config.Accounts {
+		accountHealth := &accountsHealth[accountPos]
+		accountHealth.Email = account.Email
+		accountHealth.IsCurrent = accountPos == client.currentAccountPos
+		accountHealth.LastQueryTime = client.lastQueryTimePerAccount[accountPos]
+		if blockedUntil := client.blockedUntilPerAccount[accountPos]; blockedUntil.After(nowTime) {
+			accountHealth.BlockedUntil = blockedUntil
+		}
+
+		authData := client
+		if !accountHealth.IsCurrent {
+			var err error
+			authData, err = readAuthorizationData(client.Config, account.Email)
+			if err != nil {
+				continue
+			}
+		}
+		accountHealth.IsLoggedIn = authData.IsLoggedIn() && authData.IsLogInStillValid()
+		if !authData.LastTokenRefreshedTime.IsZero() {
+			accountHealth.TokenRefreshedTime = authData.LastTokenRefreshedTime
+			accountHealth.TokenValidUntil = authData.LastTokenRefreshedTime.Add(client.Config.TokenValidityDuration.Duration)
+		}
+	}
+	return accountsHealth
+}
+
 func (client *TooGooToGoClient) Close() error {
 	client.httpClient.CloseIdleConnections()
 
@@ -0,0 +1,711 @@
+package tga
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	kTestUserAgent = "TGTG/23.5.2 Dalvik/2.1.0 (Linux; Android 12; SM-G973F Build/SP1A.210812.016; wv)"
+)
+
+func newTestConfig(server *FakeTooGoodToGoServer) *TooGoodToGoConfig {
+	return &TooGoodToGoConfig{
+		BaseUrl: server.BaseUrl(),
+		Accounts: []TooGoodToGoAccount{
+			{
+				Email:     "ant1@email.com",
+				UserAgent: kTestUserAgent,
+			},
+			{
+				Email:     "ant2@email.com",
+				UserAgent: kTestUserAgent,
+			},
+		},
+		Language:                            "en-UK",
+		AverageRequestsPeriod:               Duration{Duration: time.Millisecond},
+		TooManyRequestsPausePeriod:          Duration{Duration: time.Millisecond},
+		ActiveOrdersReminderPeriod:          Duration{Duration: time.Millisecond},
+		LogInEmailValidationRequestsPeriod:  Duration{Duration: time.Millisecond},
+		LogInEmailValidationTimeoutDuration: Duration{Duration: time.Minute},
+		LogInValidityDuration:               Duration{Duration: time.Hour},
+		TokenValidityDuration:               Duration{Duration: time.Hour},
+		RetryConfig: RetryConfig{
+			InitialBackoff: Duration{Duration: time.Millisecond},
+			MaxBackoff:     Duration{Duration: 10 * time.Millisecond},
+		},
+		PaymentConfig: PaymentConfig{
+			PaymentPollingPeriod: Duration{Duration: time.Millisecond},
+		},
+		SearchConfig: SearchConfig{
+			Origin: Location{
+				Latitude:  41.902782,
+				Longitude: 12.496366,
+			},
+			RadiusInKm:    3,
+			NbMaxResults:  20,
+			WithStockOnly: true,
+		},
+	}
+}
+
+func newTestClient(server *FakeTooGoodToGoServer) *TooGooToGoClient {
+	client := NewTooGooToGoClient(context.Background(), newTestConfig(server), false)
+	client.openBrowser = func(url string) error { return nil }
+	return client
+}
+
+func storeIds(stores []Store) []string {
+	ids := make([]string, len(stores))
+	for storePos, store := range stores {
+		ids[storePos] = store.Id
+	}
+	return ids
+}
+
+func TestClientLogInAndListStores(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.NbPendingPolls = 2
+	server.SetItems(
+		NewFakeItem("1", "Bakery", 2),
+		NewFakeItem("2", "Sushi", 0),
+		NewFakeItem("3", "Grocery", 1),
+	)
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	stores, err := client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+
+	if expectedIds := []string{"1", "3"}; !reflect.DeepEqual(storeIds(stores), expectedIds) {
+		t.Fatalf("expected stores %v, got %v", expectedIds, storeIds(stores))
+	}
+	if stores[0].Name != "Bakery" || stores[0].AvailableBags != 2 {
+		t.Fatalf("unexpected store %v", stores[0])
+	}
+	if expectedEmails := []string{"ant1@email.com"}; !reflect.DeepEqual(server.LoggedInEmails(), expectedEmails) {
+		t.Fatalf("expected log ins %v, got %v", expectedEmails, server.LoggedInEmails())
+	}
+	if nbPolls := server.NbRequests(kAuthByRequestPollingId); nbPolls != 3 {
+		t.Fatalf("expected 3 polling requests, got %v", nbPolls)
+	}
+	if client.UserId != "user-ant1@email.com" {
+		t.Fatalf("unexpected user id %v", client.UserId)
+	}
+
+	server.SetItemsAvailable("2", 4)
+
+	stores, err = client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+	if expectedIds := []string{"1", "2", "3"}; !reflect.DeepEqual(storeIds(stores), expectedIds) {
+		t.Fatalf("expected stores %v, got %v", expectedIds, storeIds(stores))
+	}
+	if len(server.LoggedInEmails()) != 1 {
+		t.Fatalf("expected no new log in, got %v", server.LoggedInEmails())
+	}
+}
+
+func TestClientListStoresPagination(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(
+		NewFakeItem("1", "Bakery", 2),
+		NewFakeItem("2", "Sushi", 1),
+		NewFakeItem("3", "Grocery", 1),
+		NewFakeItem("1", "Bakery", 2),
+		NewFakeItem("4", "Pizza", 3),
+		NewFakeItem("5", "Coffee", 1),
+		NewFakeItem("6", "Burger", 1),
+	)
+
+	client := newTestClient(server)
+	client.Config.SearchConfig.NbMaxResults = 2
+	ctx := context.Background()
+
+	stores, err := client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+	if expectedIds := []string{"1", "2", "3", "4", "5", "6"}; !reflect.DeepEqual(storeIds(stores), expectedIds) {
+		t.Fatalf("expected stores %v, got %v", expectedIds, storeIds(stores))
+	}
+	if nbItemRequests := server.NbRequests(kApiItemEndpoint); nbItemRequests != 4 {
+		t.Fatalf("expected 4 item requests, got %v", nbItemRequests)
+	}
+
+	client.Config.SearchConfig.NbMaxPages = 2
+
+	stores, err = client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+	if expectedIds := []string{"1", "2", "3"}; !reflect.DeepEqual(storeIds(stores), expectedIds) {
+		t.Fatalf("expected stores %v, got %v", expectedIds, storeIds(stores))
+	}
+	if nbItemRequests := server.NbRequests(kApiItemEndpoint); nbItemRequests != 6 {
+		t.Fatalf("expected 6 item requests, got %v", nbItemRequests)
+	}
+}
+
+func TestClientListStoresOfSeveralSearches(t *testing.T) {
+	homeOrigin := Location{Latitude: 48.85, Longitude: 2.35}
+	officeOrigin := Location{Latitude: 48.89, Longitude: 2.24}
+
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItemsAt(homeOrigin, NewFakeItem("1", "Bakery", 2), NewFakeItem("2", "Sushi", 1))
+	server.SetItemsAt(officeOrigin, NewFakeItem("2", "Sushi", 1), NewFakeItem("3", "Grocery", 1))
+
+	client := newTestClient(server)
+	client.Config.SearchConfig.Searches = []Search{
+		{Name: "home", Origin: homeOrigin, RadiusInKm: 1, WithStockOnly: true},
+		{Name: "office", Origin: officeOrigin, RadiusInKm: 2, WithStockOnly: true},
+	}
+
+	stores, err := client.ListStores(context.Background())
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+	if expectedIds := []string{"1", "2", "3"}; !reflect.DeepEqual(storeIds(stores), expectedIds) {
+		t.Fatalf("expected stores %v, got %v", expectedIds, storeIds(stores))
+	}
+	expectedSearchNames := [][]string{{"home"}, {"home", "office"}, {"office"}}
+	for storePos, store := range stores {
+		if !reflect.DeepEqual(store.SearchNames, expectedSearchNames[storePos]) {
+			t.Fatalf("expected search names %v for store %v, got %v", expectedSearchNames[storePos], store.Id, store.SearchNames)
+		}
+	}
+	if expectedString := "Sushi, rated 4.5, price 3.99 EUR, 1 available\nfound by home, office"; stores[1].String() != expectedString {
+		t.Fatalf("expected %q, got %q", expectedString, stores[1].String())
+	}
+}
+
+func TestClientGetItem(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 0), NewFakeItem("2", "Sushi", 3))
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	store, err := client.GetItem(ctx, "2")
+	if err != nil {
+		t.Fatalf("error from GetItem: %v", err)
+	}
+	if store.Id != "2" || store.Name != "Sushi" || store.AvailableBags != 3 {
+		t.Fatalf("unexpected store %v", store)
+	}
+
+	_, err = client.GetItem(ctx, "3")
+	if !errors.Is(err, ErrUnexpectedStatus) {
+		t.Fatalf("expected unexpected status error for unknown item, got %v", err)
+	}
+}
+
+func TestClientGetItemOfSeveralSearches(t *testing.T) {
+	homeOrigin := Location{Latitude: 48.85, Longitude: 2.35}
+
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+
+	client := newTestClient(server)
+	// only named searches, the legacy origin is not set
+	client.Config.SearchConfig.Origin = Location{}
+	client.Config.SearchConfig.Searches = []Search{
+		{Name: "home", Origin: homeOrigin, RadiusInKm: 1},
+		{Name: "office", Origin: Location{Latitude: 48.89, Longitude: 2.24}, RadiusInKm: 2},
+	}
+
+	_, err := client.GetItem(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("error from GetItem: %v", err)
+	}
+	if origins := server.ItemOrigins(); !reflect.DeepEqual(origins, []Location{homeOrigin}) {
+		t.Fatalf("expected item detail requested around %v, got %v", homeOrigin, origins)
+	}
+}
+
+func TestClientLogInAgainOnUnauthorized(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	_, err := client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+
+	server.InvalidateTokens()
+
+	stores, err := client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+	if len(stores) != 1 {
+		t.Fatalf("expected 1 store, got %v", len(stores))
+	}
+	if expectedEmails := []string{"ant1@email.com", "ant1@email.com"}; !reflect.DeepEqual(server.LoggedInEmails(), expectedEmails) {
+		t.Fatalf("expected log ins %v, got %v", expectedEmails, server.LoggedInEmails())
+	}
+}
+
+func TestClientSwitchAccountOnCaptcha(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	var openedUrls []string
+	client.openBrowser = func(url string) error {
+		openedUrls = append(openedUrls, url)
+		return nil
+	}
+
+	_, err := client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+
+	server.EnqueueCaptcha(kApiItemEndpoint)
+
+	stores, err := client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+	if len(stores) != 1 {
+		t.Fatalf("expected 1 store, got %v", len(stores))
+	}
+	if client.emailAccount() != "ant2@email.com" {
+		t.Fatalf("expected account switch, current account is %v", client.emailAccount())
+	}
+	if expectedEmails := []string{"ant1@email.com", "ant2@email.com"}; !reflect.DeepEqual(server.LoggedInEmails(), expectedEmails) {
+		t.Fatalf("expected log ins %v, got %v", expectedEmails, server.LoggedInEmails())
+	}
+	if expectedUrls := []string{kFakeCaptchaUrl}; !reflect.DeepEqual(openedUrls, expectedUrls) {
+		t.Fatalf("expected opened urls %v, got %v", expectedUrls, openedUrls)
+	}
+}
+
+func TestClientAbortAccountScopedQueryOnAccountSwitch(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	now := time.Now()
+	server.AddOrder(NewFakeOrder("order-1", "Bakery", now.Add(2*time.Hour), now.Add(time.Hour)))
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	server.EnqueueCaptcha(kApiListOpenedOrders)
+
+	_, err := client.ListOpenedOrders(ctx)
+	if !errors.Is(err, ErrAccountSwitched) {
+		t.Fatalf("expected account switched error, got %v", err)
+	}
+	if client.emailAccount() != "ant2@email.com" {
+		t.Fatalf("expected account switch, current account is %v", client.emailAccount())
+	}
+
+	// next query is made for the new account
+	orders, err := client.ListOpenedOrders(ctx)
+	if err != nil || len(orders) != 1 {
+		t.Fatalf("expected 1 opened order, got %v, %v", orders, err)
+	}
+}
+
+func TestClientTypedErrors(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	server.EnqueueStatus(kApiItemEndpoint, http.StatusBadGateway)
+
+	_, err := client.ListStores(ctx)
+	var apiErr *ApiError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected ApiError, got %v", err)
+	}
+	if !errors.Is(err, ErrServer) || apiErr.StatusCode != http.StatusBadGateway || apiErr.Endpoint != kApiItemEndpoint {
+		t.Fatalf("unexpected error %v", apiErr)
+	}
+
+	server.Enqueue(kApiItemEndpoint, FakeResponse{StatusCode: http.StatusOK, Body: `{"items": [{"item"`})
+
+	_, err = client.ListStores(ctx)
+	if !errors.As(err, &apiErr) || !errors.Is(err, ErrMalformedResponse) {
+		t.Fatalf("expected malformed response error, got %v", err)
+	}
+	if apiErr.BodySnippet != `{"items": [{"item"` {
+		t.Fatalf("unexpected body snippet %v", apiErr.BodySnippet)
+	}
+}
+
+func TestClientRotateAccountOnTooManyRequests(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	_, err := client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+
+	server.Enqueue(kApiItemEndpoint, FakeResponse{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"120"}},
+	})
+
+	stores, err := client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+	if len(stores) != 1 {
+		t.Fatalf("expected 1 store, got %v", len(stores))
+	}
+	if expectedEmails := []string{"ant1@email.com", "ant2@email.com"}; !reflect.DeepEqual(server.LoggedInEmails(), expectedEmails) {
+		t.Fatalf("expected log ins %v, got %v", expectedEmails, server.LoggedInEmails())
+	}
+	if client.emailAccount() != "ant2@email.com" {
+		t.Fatalf("expected account switch, current account is %v", client.emailAccount())
+	}
+	if nbItemRequests := server.NbRequests(kApiItemEndpoint); nbItemRequests != 3 {
+		t.Fatalf("expected 3 item requests, got %v", nbItemRequests)
+	}
+	blockedDuration := time.Until(client.blockedUntilPerAccount[0])
+	if blockedDuration < 0 || blockedDuration > 2*time.Minute {
+		t.Fatalf("expected first account to be blocked for up to 2 minutes, got %v", blockedDuration)
+	}
+}
+
+func TestClientBoundedRetries(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+
+	client := newTestClient(server)
+	client.Config.Accounts = client.Config.Accounts[:1]
+	client.Config.RetryConfig.MaxRetries = 2
+	ctx := context.Background()
+
+	_, err := client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		server.EnqueueStatus(kApiItemEndpoint, http.StatusTooManyRequests)
+	}
+
+	_, err = client.ListStores(ctx)
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected rate limited error, got %v", err)
+	}
+	if nbItemRequests := server.NbRequests(kApiItemEndpoint); nbItemRequests != 4 {
+		t.Fatalf("expected 4 item requests, got %v", nbItemRequests)
+	}
+	if len(server.LoggedInEmails()) != 1 {
+		t.Fatalf("expected no new log in with a single account, got %v", server.LoggedInEmails())
+	}
+}
+
+func TestClientReserveAndCancelOrder(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	stores, err := client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+
+	reservedOrder, err := client.ReserveOrder(ctx, stores[0], 2)
+	if err != nil {
+		t.Fatalf("error from ReserveOrder: %v", err)
+	}
+	expectedReservedOrder := ReservedOrder{
+		Id:       "order-1",
+		StoreId:  "1",
+		Quantity: 2,
+	}
+	if reservedOrder != expectedReservedOrder {
+		t.Fatalf("expected reserved order %v, got %v", expectedReservedOrder, reservedOrder)
+	}
+
+	stores, err = client.ListStores(ctx)
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+	if len(stores) != 0 {
+		t.Fatalf("expected no more available stores, got %v", stores)
+	}
+
+	err = client.CancelOrder(ctx, reservedOrder.Id)
+	if err != nil {
+		t.Fatalf("error from CancelOrder: %v", err)
+	}
+}
+
+// cancelAfterWriter records written messages and cancels the context after nbMaxMessages messages.
+type cancelAfterWriter struct {
+	messages      []string
+	nbMaxMessages int
+	cancel        context.CancelFunc
+	onWrite       func(nbMessages int)
+}
+
+func (w *cancelAfterWriter) Write(p []byte) (int, error) {
+	w.messages = append(w.messages, string(p))
+	if w.onWrite != nil {
+		w.onWrite(len(w.messages))
+	}
+	if len(w.messages) >= w.nbMaxMessages {
+		w.cancel()
+	}
+	return len(p), nil
+}
+
+func TestHarvestNotifiesAgainAfterFailedNotification(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+
+	ant := &Ant{
+		client:       newTestClient(server),
+		sender:       failingWriter{},
+		storeTracker: NewStoreTracker(nil),
+	}
+	ant.harvestOnce(context.Background())
+
+	sender := &cancelAfterWriter{nbMaxMessages: 10, cancel: func() {}}
+	ant.sender = sender
+	ant.harvestOnce(context.Background())
+	if len(sender.messages) != 1 || !strings.Contains(sender.messages[0], "newly available") {
+		t.Fatalf("expected the failed notification to be sent again, got %v", sender.messages)
+	}
+}
+
+func TestHarvestNotifiesStoreChanges(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(
+		NewFakeItem("1", "Bakery", 2),
+		NewFakeItem("2", "Sushi", 0),
+	)
+
+	client := newTestClient(server)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sender := &cancelAfterWriter{
+		nbMaxMessages: 2,
+		cancel:        cancel,
+		onWrite: func(nbMessages int) {
+			if nbMessages == 1 {
+				server.SetItemsAvailable("2", 1)
+				server.InvalidateTokens()
+			}
+		},
+	}
+
+	ant := &Ant{
+		client:       client,
+		sender:       sender,
+		storeTracker: NewStoreTracker(nil),
+	}
+	ant.harvest(ctx)
+
+	if len(sender.messages) != 2 {
+		t.Fatalf("expected 2 messages, got %v", sender.messages)
+	}
+	if expectedMessage := "newly available: Bakery, rated 4.5, price 3.99 EUR, 2 available\n\n"; sender.messages[0] != expectedMessage {
+		t.Fatalf("expected message %q, got %q", expectedMessage, sender.messages[0])
+	}
+	if expectedMessage := "newly available: Sushi, rated 4.5, price 3.99 EUR, 1 available\n\n"; sender.messages[1] != expectedMessage {
+		t.Fatalf("expected message %q, got %q", expectedMessage, sender.messages[1])
+	}
+	if len(server.LoggedInEmails()) != 2 {
+		t.Fatalf("expected a new log in after tokens invalidation, got %v", server.LoggedInEmails())
+	}
+}
+
+func TestHarvestAutoReserves(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+
+	client := newTestClient(server)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sender := &cancelAfterWriter{
+		nbMaxMessages: 2,
+		cancel:        cancel,
+	}
+	autoReserver, err := NewAutoReserver([]ReserveRule{{Name: "any"}})
+	if err != nil {
+		t.Fatalf("error from NewAutoReserver: %v", err)
+	}
+
+	ant := &Ant{
+		client:       client,
+		sender:       sender,
+		storeTracker: NewStoreTracker(nil),
+		autoReserver: autoReserver,
+	}
+	ant.harvest(ctx)
+
+	if len(sender.messages) != 2 {
+		t.Fatalf("expected 2 messages, got %v", sender.messages)
+	}
+	if expectedMessage := "auto reserved by rule 'any': Order # order-1 in store 1 with 1 bags\nBakery, rated 4.5, price 3.99 EUR, 2 available"; sender.messages[0] != expectedMessage {
+		t.Fatalf("expected message %q, got %q", expectedMessage, sender.messages[0])
+	}
+}
+
+func TestHarvestKeepsGoingAfterErrors(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+	server.EnqueueStatus(kApiItemEndpoint, http.StatusInternalServerError)
+	server.EnqueueStatus(kApiItemEndpoint, http.StatusTooManyRequests)
+
+	client := newTestClient(server)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sender := &cancelAfterWriter{
+		nbMaxMessages: 1,
+		cancel:        cancel,
+	}
+
+	ant := &Ant{
+		client:       client,
+		sender:       sender,
+		storeTracker: NewStoreTracker(nil),
+	}
+	ant.harvest(ctx)
+
+	if len(sender.messages) != 1 {
+		t.Fatalf("expected 1 message, got %v", sender.messages)
+	}
+	if client.emailAccount() != "ant2@email.com" {
+		t.Fatalf("expected account switch after rate limit, current account is %v", client.emailAccount())
+	}
+}
+
+func TestClientCancelRequestsDelay(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+
+	client := newTestClient(server)
+	client.Config.AverageRequestsPeriod = Duration{Duration: time.Hour}
+
+	_, err := client.ListStores(context.Background())
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	begTime := time.Now()
+	_, err = client.ListStores(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded error, got %v", err)
+	}
+	if elapsedTime := time.Since(begTime); elapsedTime > 5*time.Second {
+		t.Fatalf("expected ListStores to return shortly after cancellation, took %v", elapsedTime)
+	}
+}
+
+func TestClientCancelTooManyRequestsPause(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+
+	client := newTestClient(server)
+	client.Config.TooManyRequestsPausePeriod = Duration{Duration: 90 * time.Minute}
+
+	_, err := client.ListStores(context.Background())
+	if err != nil {
+		t.Fatalf("error from ListStores: %v", err)
+	}
+
+	// first captcha switches to second account, second one goes back to the first account and waits for the pause period
+	server.EnqueueCaptcha(kApiItemEndpoint)
+	server.EnqueueCaptcha(kApiItemEndpoint)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = client.ListStores(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded error, got %v", err)
+	}
+}
+
+func TestClientCancelOpenedOrder(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	now := time.Now()
+	server.AddOrder(NewFakeOrder("order-1", "Bakery", now.Add(3*time.Hour), now.Add(time.Hour)))
+	server.AddOrder(NewFakeOrder("order-2", "Grocery", now.Add(time.Hour), now.Add(-time.Minute)))
+
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	order, err := client.CancelOpenedOrder(ctx, "order-1")
+	if err != nil {
+		t.Fatalf("error from CancelOpenedOrder: %v", err)
+	}
+	if order.StoreName != "Bakery" {
+		t.Fatalf("expected cancelled order of Bakery, got %v", order)
+	}
+
+	_, err = client.CancelOpenedOrder(ctx, "order-2")
+	if !errors.Is(err, ErrOrderNotCancellable) {
+		t.Fatalf("expected not cancellable error after the deadline, got %v", err)
+	}
+	_, err = client.CancelOpenedOrder(ctx, "order-1")
+	if !errors.Is(err, ErrOrderNotCancellable) {
+		t.Fatalf("expected not cancellable error for an order which is not opened anymore, got %v", err)
+	}
+
+	if expectedIds := []string{"order-1"}; !reflect.DeepEqual(server.AbortedOrderIds(), expectedIds) {
+		t.Fatalf("expected aborted orders %v, got %v", expectedIds, server.AbortedOrderIds())
+	}
+}
+
+func TestClientWaitForPayment(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	client := newTestClient(server)
+	ctx := context.Background()
+
+	server.SetPaymentStates("AUTHORIZATION_INITIATED", "AUTHORIZED")
+	paymentStatus, err := client.WaitForPayment(ctx, "payment-order-1", time.Second)
+	if err != nil {
+		t.Fatalf("error from WaitForPayment: %v", err)
+	}
+	if paymentStatus.State != Authorized || paymentStatus.OrderId != "order-1" || paymentStatus.Amount.Amount != 399 {
+		t.Fatalf("unexpected payment status %v", paymentStatus)
+	}
+
+	server.SetPaymentStates("AUTHORIZATION_INITIATED", "FAILED")
+	paymentStatus, err = client.WaitForPayment(ctx, "payment-order-2", time.Second)
+	if !errors.Is(err, ErrPaymentFailed) || paymentStatus.State != PaymentFailed {
+		t.Fatalf("expected payment failed error, got %v with state %v", err, paymentStatus.State)
+	}
+
+	server.SetPaymentStates("AUTHORIZATION_INITIATED")
+	paymentStatus, err = client.WaitForPayment(ctx, "payment-order-3", 10*time.Millisecond)
+	if !errors.Is(err, ErrPaymentTimeout) || paymentStatus.State != AuthorizationInitiated {
+		t.Fatalf("expected payment timeout error, got %v with state %v", err, paymentStatus.State)
+	}
+}
@@ -0,0 +1,697 @@
+package tga
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	kFakeServerApiPath = "/api/"
+	kFakeCaptchaUrl    = "https://geo.captcha-delivery.com/captcha/?initialCid=fake"
+)
+
+// FakeResponse is a scripted response returned by FakeTooGoodToGoServer instead of the default behavior.
+type FakeResponse struct {
+	StatusCode int
+	Body       string
+	Header     http.Header
+}
+
+// FakeTooGoodToGoServer is an in-process implementation of the subset of the Too Good To Go API used by the client.
+// Its state (items, orders, tokens) can be modified at any time by tests, and responses can be scripted per endpoint.
+type FakeTooGoodToGoServer struct {
+	*httptest.Server
+
+	mutex sync.Mutex
+
+	items          []map[string]interface{}
+	itemsPerOrigin map[Location][]map[string]interface{}
+	itemOrigins    []Location
+	orders         []map[string]interface{}
+	pastOrders     map[string][]map[string]interface{} // email -> inactive orders, most recent first
+	favorites      map[string]map[string]bool          // email -> favorite item ids
+
+	scriptedResponses map[string][]FakeResponse
+
+	// number of polling requests answered with an empty body before validating the log in
+	NbPendingPolls int
+
+	nbPendingPollsPerEmail map[string]int
+	accessTokens           map[string]string // access token -> email
+	refreshTokens          map[string]string // refresh token -> email
+	loggedInEmails         []string
+	requestedPaths         []string
+	nbIssuedTokens         int
+	nbCreatedOrders        int
+	abortedOrderIds        map[string]bool
+	paymentStates          []string
+}
+
+// NewFakeTooGoodToGoServer starts a fake server which is closed at the end of the test.
+func NewFakeTooGoodToGoServer(t *testing.T) *FakeTooGoodToGoServer {
+	server := &FakeTooGoodToGoServer{
+		itemsPerOrigin:         make(map[Location][]map[string]interface{}),
+		pastOrders:             make(map[string][]map[string]interface{}),
+		favorites:              make(map[string]map[string]bool),
+		scriptedResponses:      make(map[string][]FakeResponse),
+		nbPendingPollsPerEmail: make(map[string]int),
+		accessTokens:           make(map[string]string),
+		refreshTokens:          make(map[string]string),
+		abortedOrderIds:        make(map[string]bool),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/auth/v4/authByEmail", server.handleAuthByEmail)
+	mux.HandleFunc("POST /api/auth/v4/authByRequestPollingId", server.handleAuthByRequestPollingId)
+	mux.HandleFunc("POST /api/auth/v3/token/refresh", server.handleRefreshToken)
+	mux.HandleFunc("POST /api/user/v2", server.withAuthorization(server.handleUserInformation))
+	mux.HandleFunc("POST /api/item/v7/{$}", server.withAuthorization(server.handleListItems))
+	mux.HandleFunc("POST /api/item/v7/{itemId}", server.withAuthorization(server.handleGetItem))
+	mux.HandleFunc("POST /api/item/v7/{itemId}/setFavorite", server.withAuthorization(server.handleSetFavorite))
+	mux.HandleFunc("POST /api/order/v7/active", server.withAuthorization(server.handleListOpenedOrders))
+	mux.HandleFunc("POST /api/order/v7/inactive", server.withAuthorization(server.handleListInactiveOrders))
+	mux.HandleFunc("POST /api/order/v7/create/{itemId}", server.withAuthorization(server.handleCreateOrder))
+	mux.HandleFunc("POST /api/order/v7/{orderId}/{action}", server.withAuthorization(server.handleOrderAction))
+	mux.HandleFunc("POST /api/paymentMethod/v1/{$}", server.withAuthorization(server.handlePaymentMethods))
+	mux.HandleFunc("POST /api/payment/v3/{paymentId}", server.withAuthorization(server.handlePayment))
+
+	server.Server = httptest.NewServer(server.withScriptedResponses(mux))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// BaseUrl is the url to set in TooGoodToGoConfig to target this server.
+func (server *FakeTooGoodToGoServer) BaseUrl() string {
+	return server.URL + kFakeServerApiPath
+}
+
+// NewFakeItem builds a minimal item/v7 entry.
+func NewFakeItem(itemId, storeName string, itemsAvailable int) map[string]interface{} {
+	return map[string]interface{}{
+		"item": map[string]interface{}{
+			"item_id": itemId,
+			"item_price": map[string]interface{}{
+				"code":        "EUR",
+				"minor_units": 399,
+				"decimals":    2,
+			},
+			"average_overall_rating": map[string]interface{}{
+				"average_overall_rating": 4.5,
+			},
+		},
+		"store": map[string]interface{}{
+			"store_id":   "store-" + itemId,
+			"store_name": storeName,
+		},
+		"display_name":    storeName,
+		"items_available": itemsAvailable,
+	}
+}
+
+// NewFakeOrder builds a minimal order/v7/active entry, picked up during the hour following pickupStart.
+func NewFakeOrder(orderId, storeName string, pickupStart, cancelUntil time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"order_id":     orderId,
+		"state":        "ACTIVE",
+		"store_id":     "store-" + orderId,
+		"store_name":   storeName,
+		"quantity":     1,
+		"cancel_until": cancelUntil.Format(time.RFC3339),
+		"pickup_interval": map[string]interface{}{
+			"start": pickupStart.Format(time.RFC3339),
+			"end":   pickupStart.Add(time.Hour).Format(time.RFC3339),
+		},
+		"pickup_location": map[string]interface{}{
+			"address": map[string]interface{}{
+				"address_line": "1 rue de la Paix",
+			},
+		},
+		"item_price": map[string]interface{}{
+			"code":        "EUR",
+			"minor_units": 399,
+			"decimals":    2,
+		},
+	}
+}
+
+// SetItems replaces the items known by the server.
+func (server *FakeTooGoodToGoServer) SetItems(items ...map[string]interface{}) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.items = items
+}
+
+// SetItemsAt replaces the items returned for searches around given origin.
+// Searches around other origins return the items set by SetItems.
+func (server *FakeTooGoodToGoServer) SetItemsAt(origin Location, items ...map[string]interface{}) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.itemsPerOrigin[origin] = items
+}
+
+// ItemOrigins returns the origins sent with the item detail requests, in order.
+func (server *FakeTooGoodToGoServer) ItemOrigins() []Location {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return append([]Location{}, server.itemOrigins...)
+}
+
+// SetPaymentStates sets the successive states returned when polling a payment, the last one being repeated.
+// Payments are CAPTURED by default.
+func (server *FakeTooGoodToGoServer) SetPaymentStates(states ...string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.paymentStates = states
+}
+
+// AbortedOrderIds returns the ids of the aborted orders, sorted.
+func (server *FakeTooGoodToGoServer) AbortedOrderIds() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	orderIds := []string{}
+	for orderId := range server.abortedOrderIds {
+		orderIds = append(orderIds, orderId)
+	}
+	sort.Strings(orderIds)
+	return orderIds
+}
+
+// SetItemsAvailable changes the stock of given item.
+func (server *FakeTooGoodToGoServer) SetItemsAvailable(itemId string, itemsAvailable int) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	item := server.findItem(itemId)
+	if item != nil {
+		item["items_available"] = itemsAvailable
+	}
+}
+
+// AddOrder adds an order (with the same format as order/v7/active entries) to the opened orders, until it is aborted.
+func (server *FakeTooGoodToGoServer) AddOrder(order map[string]interface{}) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.orders = append(server.orders, order)
+}
+
+// AddPastOrder adds an order (with the same format as order/v7/inactive entries) to the history of given account,
+// which is listed most recent first.
+func (server *FakeTooGoodToGoServer) AddPastOrder(email string, order map[string]interface{}) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.pastOrders[email] = append([]map[string]interface{}{order}, server.pastOrders[email]...)
+}
+
+// Favorites returns the favorite item ids of given account, sorted.
+func (server *FakeTooGoodToGoServer) Favorites(email string) []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	itemIds := []string{}
+	for itemId := range server.favorites[email] {
+		itemIds = append(itemIds, itemId)
+	}
+	sort.Strings(itemIds)
+	return itemIds
+}
+
+// Enqueue scripts the next response for given path (relative to the api base url, for instance "item/v7/").
+// Several responses can be enqueued for the same path, they are returned in order.
+func (server *FakeTooGoodToGoServer) Enqueue(path string, response FakeResponse) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.scriptedResponses[path] = append(server.scriptedResponses[path], response)
+}
+
+// EnqueueStatus scripts an empty response with given status code for the next query of path.
+func (server *FakeTooGoodToGoServer) EnqueueStatus(path string, statusCode int) {
+	server.Enqueue(path, FakeResponse{StatusCode: statusCode})
+}
+
+// EnqueueCaptcha scripts a captcha challenge for the next query of path.
+func (server *FakeTooGoodToGoServer) EnqueueCaptcha(path string) {
+	server.Enqueue(path, FakeResponse{
+		StatusCode: http.StatusForbidden,
+		Body:       fmt.Sprintf(`{"url":"%v"}`, kFakeCaptchaUrl),
+	})
+}
+
+// InvalidateTokens revokes all issued tokens, next authorized queries will receive a 401.
+func (server *FakeTooGoodToGoServer) InvalidateTokens() {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.accessTokens = make(map[string]string)
+	server.refreshTokens = make(map[string]string)
+}
+
+// LoggedInEmails returns the emails of all successful log ins, in order.
+func (server *FakeTooGoodToGoServer) LoggedInEmails() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return append([]string{}, server.loggedInEmails...)
+}
+
+// RequestedPaths returns the paths of all received requests (relative to the api base url), in order.
+func (server *FakeTooGoodToGoServer) RequestedPaths() []string {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	return append([]string{}, server.requestedPaths...)
+}
+
+// NbRequests returns the number of received requests for given path.
+func (server *FakeTooGoodToGoServer) NbRequests(path string) int {
+	nbRequests := 0
+	for _, requestedPath := range server.RequestedPaths() {
+		if requestedPath == path {
+			nbRequests++
+		}
+	}
+	return nbRequests
+}
+
+func (server *FakeTooGoodToGoServer) withScriptedResponses(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		path := strings.TrimPrefix(req.URL.Path, kFakeServerApiPath)
+
+		server.mutex.Lock()
+		server.requestedPaths = append(server.requestedPaths, path)
+		responses := server.scriptedResponses[path]
+		var scriptedResponse *FakeResponse
+		if len(responses) > 0 {
+			scriptedResponse = &responses[0]
+			server.scriptedResponses[path] = responses[1:]
+		}
+		server.mutex.Unlock()
+
+		if scriptedResponse == nil {
+			next.ServeHTTP(res, req)
+			return
+		}
+
+		for headerName, headerValues := range scriptedResponse.Header {
+			for _, headerValue := range headerValues {
+				res.Header().Add(headerName, headerValue)
+			}
+		}
+		res.WriteHeader(scriptedResponse.StatusCode)
+		res.Write([]byte(scriptedResponse.Body))
+	})
+}
+
+func (server *FakeTooGoodToGoServer) withAuthorization(next func(http.ResponseWriter, *http.Request, string)) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		accessToken := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+
+		server.mutex.Lock()
+		email, isValidToken := server.accessTokens[accessToken]
+		server.mutex.Unlock()
+
+		if !isValidToken {
+			res.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next(res, req, email)
+	}
+}
+
+func (server *FakeTooGoodToGoServer) findItem(itemId string) map[string]interface{} {
+	for _, item := range server.items {
+		if item["item"].(map[string]interface{})["item_id"] == itemId {
+			return item
+		}
+	}
+	return nil
+}
+
+func writeFakeJson(res http.ResponseWriter, object any) {
+	res.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(res).Encode(object)
+	if err != nil {
+		res.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func readFakeJson(req *http.Request, object any) error {
+	body, err := io.ReadAll(req.Body)
+	if err != nil || len(body) == 0 {
+		return err
+	}
+	return json.Unmarshal(body, object)
+}
+
+type fakeAuthRequest struct {
+	Email            string `json:"email"`
+	RequestPollingId string `json:"request_polling_id"`
+}
+
+func (server *FakeTooGoodToGoServer) handleAuthByEmail(res http.ResponseWriter, req *http.Request) {
+	var authRequest fakeAuthRequest
+	err := readFakeJson(req, &authRequest)
+	if err != nil || len(authRequest.Email) == 0 {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	server.mutex.Lock()
+	server.nbPendingPollsPerEmail[authRequest.Email] = server.NbPendingPolls
+	server.mutex.Unlock()
+
+	writeFakeJson(res, map[string]string{
+		"state":      "WAIT",
+		"polling_id": "polling-" + authRequest.Email,
+	})
+}
+
+// issueTokens should be called with the mutex locked.
+func (server *FakeTooGoodToGoServer) issueTokens(email string) map[string]interface{} {
+	server.nbIssuedTokens++
+	accessToken := fmt.Sprintf("access-token-%v", server.nbIssuedTokens)
+	refreshToken := fmt.Sprintf("refresh-token-%v", server.nbIssuedTokens)
+	server.accessTokens[accessToken] = email
+	server.refreshTokens[refreshToken] = email
+
+	return map[string]interface{}{
+		"access_token":             accessToken,
+		"access_token_ttl_seconds": 172800,
+		"refresh_token":            refreshToken,
+	}
+}
+
+func (server *FakeTooGoodToGoServer) handleAuthByRequestPollingId(res http.ResponseWriter, req *http.Request) {
+	var authRequest fakeAuthRequest
+	err := readFakeJson(req, &authRequest)
+	if err != nil || authRequest.RequestPollingId != "polling-"+authRequest.Email {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if server.nbPendingPollsPerEmail[authRequest.Email] > 0 {
+		server.nbPendingPollsPerEmail[authRequest.Email]--
+		res.WriteHeader(http.StatusOK)
+		return
+	}
+
+	server.loggedInEmails = append(server.loggedInEmails, authRequest.Email)
+
+	writeFakeJson(res, server.issueTokens(authRequest.Email))
+}
+
+func (server *FakeTooGoodToGoServer) handleRefreshToken(res http.ResponseWriter, req *http.Request) {
+	var refreshRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	err := readFakeJson(req, &refreshRequest)
+	if err != nil {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	email, isValidToken := server.refreshTokens[refreshRequest.RefreshToken]
+	if !isValidToken {
+		res.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	delete(server.refreshTokens, refreshRequest.RefreshToken)
+
+	writeFakeJson(res, server.issueTokens(email))
+}
+
+func (server *FakeTooGoodToGoServer) handleUserInformation(res http.ResponseWriter, req *http.Request, email string) {
+	writeFakeJson(res, map[string]interface{}{
+		"user_id": fakeUserId(email),
+		"name":    "Too good Ant",
+		"email":   email,
+	})
+}
+
+func fakeUserId(email string) string {
+	return "user-" + email
+}
+
+func (server *FakeTooGoodToGoServer) handleListItems(res http.ResponseWriter, req *http.Request, email string) {
+	var params ItemParameters
+	err := readFakeJson(req, &params)
+	if err != nil || params.UserId != fakeUserId(email) {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	originItems, found := server.itemsPerOrigin[params.Origin]
+	if !found {
+		originItems = server.items
+	}
+
+	items := []map[string]interface{}{}
+	for _, item := range originItems {
+		if params.WithStockOnly && item["items_available"].(int) == 0 {
+			continue
+		}
+		if params.FavoritesOnly && !server.favorites[email][item["item"].(map[string]interface{})["item_id"].(string)] {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	if params.Page > 0 && params.PageSize > 0 {
+		begPos := min((params.Page-1)*params.PageSize, len(items))
+		endPos := min(begPos+params.PageSize, len(items))
+		items = items[begPos:endPos]
+	}
+
+	writeFakeJson(res, map[string]interface{}{
+		"items": items,
+	})
+}
+
+func (server *FakeTooGoodToGoServer) handleGetItem(res http.ResponseWriter, req *http.Request, email string) {
+	var params ItemDetailParameters
+	err := readFakeJson(req, &params)
+	if err != nil || params.UserId != fakeUserId(email) {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	server.itemOrigins = append(server.itemOrigins, params.Origin)
+	item := server.findItem(req.PathValue("itemId"))
+	if item == nil {
+		res.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeFakeJson(res, item)
+}
+
+func (server *FakeTooGoodToGoServer) handleSetFavorite(res http.ResponseWriter, req *http.Request, email string) {
+	var params SetFavoriteParameters
+	err := readFakeJson(req, &params)
+	if err != nil {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	itemId := req.PathValue("itemId")
+	if server.findItem(itemId) == nil {
+		res.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if server.favorites[email] == nil {
+		server.favorites[email] = make(map[string]bool)
+	}
+	if params.IsFavorite {
+		server.favorites[email][itemId] = true
+	} else {
+		delete(server.favorites[email], itemId)
+	}
+	res.WriteHeader(http.StatusOK)
+}
+
+func (server *FakeTooGoodToGoServer) handleListOpenedOrders(res http.ResponseWriter, req *http.Request, email string) {
+	var params OpenedOrdersParameters
+	err := readFakeJson(req, &params)
+	if err != nil || params.UserId != fakeUserId(email) {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	orders := []map[string]interface{}{}
+	for _, order := range server.orders {
+		if !server.abortedOrderIds[order["order_id"].(string)] {
+			orders = append(orders, order)
+		}
+	}
+	writeFakeJson(res, map[string]interface{}{
+		"has_more": false,
+		"orders":   orders,
+	})
+}
+
+func (server *FakeTooGoodToGoServer) handleListInactiveOrders(res http.ResponseWriter, req *http.Request, email string) {
+	var params InactiveOrdersParameters
+	err := readFakeJson(req, &params)
+	if err != nil || params.Paging.Size <= 0 || params.UserId != fakeUserId(email) {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	pastOrders := server.pastOrders[email]
+	begPos := min(params.Paging.Page*params.Paging.Size, len(pastOrders))
+	endPos := min(begPos+params.Paging.Size, len(pastOrders))
+	writeFakeJson(res, map[string]interface{}{
+		"has_more": endPos < len(pastOrders),
+		"orders":   append([]map[string]interface{}{}, pastOrders[begPos:endPos]...),
+	})
+}
+
+func (server *FakeTooGoodToGoServer) handleCreateOrder(res http.ResponseWriter, req *http.Request, email string) {
+	var params CreateOrderParameters
+	err := readFakeJson(req, &params)
+	if err != nil {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	itemId := req.PathValue("itemId")
+	item := server.findItem(itemId)
+	if item == nil {
+		res.WriteHeader(http.StatusNotFound)
+		return
+	}
+	itemsAvailable := item["items_available"].(int)
+	if itemsAvailable < params.NbBags {
+		writeFakeJson(res, map[string]string{"state": "SOLD_OUT"})
+		return
+	}
+	item["items_available"] = itemsAvailable - params.NbBags
+
+	server.nbCreatedOrders++
+
+	writeFakeJson(res, map[string]interface{}{
+		"state": "SUCCESS",
+		"order": map[string]interface{}{
+			"id":      fmt.Sprintf("order-%v", server.nbCreatedOrders),
+			"item_id": itemId,
+			"user_id": fakeUserId(email),
+			"state":   "RESERVED",
+			"order_line": map[string]interface{}{
+				"quantity": params.NbBags,
+			},
+		},
+	})
+}
+
+func (server *FakeTooGoodToGoServer) handleOrderAction(res http.ResponseWriter, req *http.Request, email string) {
+	switch req.PathValue("action") {
+	case "abort":
+		server.handleAbortOrder(res, req, email)
+	case "pay":
+		server.handlePayOrder(res, req, email)
+	default:
+		res.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (server *FakeTooGoodToGoServer) handleAbortOrder(res http.ResponseWriter, req *http.Request, email string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	orderId := req.PathValue("orderId")
+	if server.abortedOrderIds[orderId] {
+		writeFakeJson(res, map[string]string{"state": "ALREADY_ABORTED"})
+		return
+	}
+	server.abortedOrderIds[orderId] = true
+
+	writeFakeJson(res, map[string]string{"state": "SUCCESS"})
+}
+
+func (server *FakeTooGoodToGoServer) handlePayOrder(res http.ResponseWriter, req *http.Request, email string) {
+	orderId := req.PathValue("orderId")
+
+	writeFakeJson(res, map[string]string{
+		"payment_id":       "payment-" + orderId,
+		"order_id":         orderId,
+		"payment_provider": "ADYEN",
+		"state":            "AUTHORIZATION_INITIATED",
+		"user_id":          fakeUserId(email),
+	})
+}
+
+func (server *FakeTooGoodToGoServer) handlePaymentMethods(res http.ResponseWriter, req *http.Request, email string) {
+	responseBody, err := os.ReadFile(kExamplePaymentMethod3)
+	if err != nil {
+		res.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	res.Write(responseBody)
+}
+
+func (server *FakeTooGoodToGoServer) handlePayment(res http.ResponseWriter, req *http.Request, email string) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	state := "CAPTURED"
+	if len(server.paymentStates) > 0 {
+		state = server.paymentStates[0]
+		if len(server.paymentStates) > 1 {
+			server.paymentStates = server.paymentStates[1:]
+		}
+	}
+
+	paymentId := req.PathValue("paymentId")
+
+	writeFakeJson(res, map[string]interface{}{
+		"payment_id":       paymentId,
+		"order_id":         strings.TrimPrefix(paymentId, "payment-"),
+		"payment_provider": "ADYEN",
+		"state":            state,
+		"amount": map[string]interface{}{
+			"code":        "EUR",
+			"minor_units": 399,
+			"decimals":    2,
+		},
+	})
+}
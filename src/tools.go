@@ -1,12 +1,18 @@
 package tga
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"syscall"
+	"time"
+
+	// embedded time zone database, store time zones should be resolved even on minimal systems
+	_ "time/tzdata"
 )
 
 func OpenBrowser(url string) error {
@@ -25,18 +31,70 @@ func OpenBrowser(url string) error {
 	return err
 }
 
-func GracefulShutdownHook(stopSignalReceived *bool) {
+// GracefulShutdownHook calls cancel at the first SIGINT or SIGTERM received, and force exits at the second one.
+func GracefulShutdownHook(cancel context.CancelFunc) {
 	signalChan := make(chan os.Signal, 1)
 	signal.Notify(signalChan, os.Interrupt)
 	signal.Notify(signalChan, syscall.SIGTERM)
 	go func() {
+		stopSignalReceived := false
 		for sig := range signalChan {
-			if *stopSignalReceived {
+			if stopSignalReceived {
 				glog.Printf("%v signal received again, force exiting\n", sig)
 				os.Exit(130)
 			}
-			glog.Printf("%v signal received, ending current loop (interrupt again to force stop)\n", sig)
-			*stopSignalReceived = true
+			glog.Printf("%v signal received, stopping (interrupt again to force stop)\n", sig)
+			stopSignalReceived = true
+			cancel()
 		}
 	}()
 }
+
+// sleepContext pauses the current goroutine for given duration, or less if ctx is done before.
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	if duration <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// writeFileAtomically writes data to a temporary file next to filePath, then renames it,
+// so that filePath is never left partially written. Parent directories are created if needed.
+func writeFileAtomically(filePath string, data []byte) error {
+	dirPath := filepath.Dir(filePath)
+	err := os.MkdirAll(dirPath, 0700)
+	if err != nil {
+		return fmt.Errorf("error from os.MkdirAll: %w", err)
+	}
+
+	tmpFile, err := os.CreateTemp(dirPath, filepath.Base(filePath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error from os.CreateTemp: %w", err)
+	}
+	defer os.Remove(tmpFile.Name()) // no-op after a successful rename
+
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	closeErr := tmpFile.Close()
+	if err != nil {
+		return fmt.Errorf("error from tmpFile.Write: %w", err)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("error from tmpFile.Close: %w", closeErr)
+	}
+
+	err = os.Rename(tmpFile.Name(), filePath)
+	if err != nil {
+		return fmt.Errorf("error from os.Rename: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package tga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	kDefaultWatchlistPollingPeriod = time.Minute
+)
+
+func (searchConfig *SearchConfig) watchlistPollingPeriod() time.Duration {
+	if searchConfig.WatchlistPollingPeriod.Duration <= 0 {
+		return kDefaultWatchlistPollingPeriod
+	}
+	return searchConfig.WatchlistPollingPeriod.Duration
+}
+
+// Watchlist polls a few specific items with the item detail endpoint, independently of the searches,
+// and reports the ones which become available.
+type Watchlist struct {
+	itemIds           []string
+	pollingPeriod     time.Duration
+	lastAvailableBags map[string]int
+}
+
+func NewWatchlist(config *SearchConfig) *Watchlist {
+	return &Watchlist{
+		itemIds:           config.Watchlist,
+		pollingPeriod:     config.watchlistPollingPeriod(),
+		lastAvailableBags: make(map[string]int),
+	}
+}
+
+// Poll queries each watched item with getItem. Items which could not be queried are skipped, their errors are joined in the returned error.
+func (watchlist *Watchlist) Poll(ctx context.Context, getItem func(ctx context.Context, itemId string) (Store, error)) ([]Store, error) {
+	stores := []Store{}
+	var errs []error
+	for _, itemId := range watchlist.itemIds {
+		store, err := getItem(ctx, itemId)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error from client.GetItem for item %v: %w", itemId, err))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		stores = append(stores, store)
+	}
+	return stores, errors.Join(errs...)
+}
+
+// Update records the stock of given watched stores and returns the ones which were sold out (or unknown) and are now available.
+func (watchlist *Watchlist) Update(stores []Store) []Store {
+	availableStores := []Store{}
+	for _, store := range stores {
+		lastAvailableBags := watchlist.lastAvailableBags[store.Id]
+		watchlist.lastAvailableBags[store.Id] = store.AvailableBags
+		if lastAvailableBags == 0 && store.AvailableBags > 0 {
+			availableStores = append(availableStores, store)
+		}
+	}
+	return availableStores
+}
+
+// watch polls the watched items every polling period until ctx is done, independently of the pace of the harvest loop.
+func (ant *Ant) watch(ctx context.Context) {
+	if ant.watchlist == nil || len(ant.watchlist.itemIds) == 0 {
+		return
+	}
+	ticker := time.NewTicker(ant.watchlist.pollingPeriod)
+	defer ticker.Stop()
+
+	for {
+		ant.pollWatchlist(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// getLockedItem queries given item, holding the client only during the query so that the harvest loop can interleave.
+func (ant *Ant) getLockedItem(ctx context.Context, itemId string) (Store, error) {
+	err := ant.lockClient(ctx)
+	if err != nil {
+		return Store{}, err
+	}
+	defer ant.unlockClient()
+	return ant.client.GetItem(ctx, itemId)
+}
+
+// pollWatchlist polls the watched items, reserves the ones matching the auto reserve rules
+// and notifies the ones which became available.
+func (ant *Ant) pollWatchlist(ctx context.Context) {
+	stores, err := ant.watchlist.Poll(ctx, ant.getLockedItem)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		// blocked accounts are rotated by the harvest loop, which uses the same client, the items are polled again at next tick
+		glog.Printf("error from watchlist.Poll: %v\n", err)
+	}
+
+	// the sender is also only written by the harvest loop while it holds the client
+	if ant.lockClient(ctx) != nil {
+		return
+	}
+	defer ant.unlockClient()
+
+	ant.reserveMatchingStores(ctx, stores)
+
+	for _, store := range ant.watchlist.Update(stores) {
+		_, err = ant.sender.Write([]byte(fmt.Sprintf("watched store available: %v", store.String())))
+		if err != nil {
+			glog.Printf("error from sender.Write: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package tga
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAntWatch(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 0))
+
+	client := newTestClient(server)
+	ant := &Ant{
+		client:       client,
+		sender:       &cancelAfterWriter{nbMaxMessages: 1, cancel: func() {}},
+		storeTracker: NewStoreTracker(nil),
+		watchlist:    NewWatchlist(&client.Config.SearchConfig),
+	}
+
+	// empty watchlist
+	ant.watch(context.Background())
+	if nbRequests := len(server.RequestedPaths()); nbRequests != 0 {
+		t.Fatalf("expected no request for an empty watchlist, got %v", nbRequests)
+	}
+
+	client.Config.SearchConfig.Watchlist = []string{"1"}
+	client.Config.SearchConfig.WatchlistPollingPeriod = Duration{Duration: time.Millisecond}
+	ant.watchlist = NewWatchlist(&client.Config.SearchConfig)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ant.sender = &cancelAfterWriter{nbMaxMessages: 1, cancel: cancel}
+
+	// the harvest loop holds the client, the watched item is polled as soon as it is released
+	err := ant.lockClient(ctx)
+	if err != nil {
+		t.Fatalf("error from lockClient: %v", err)
+	}
+	watchDone := make(chan struct{})
+	go func() {
+		ant.watch(ctx)
+		close(watchDone)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if nbRequests := server.NbRequests("item/v7/1"); nbRequests != 0 {
+		t.Fatalf("expected no request while the client is held, got %v", nbRequests)
+	}
+	ant.unlockClient()
+
+	for server.NbRequests("item/v7/1") < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	server.SetItemsAvailable("1", 2)
+
+	select {
+	case <-watchDone:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the watched store to be notified")
+	}
+}
+
+func TestAntWatchWhileHarvestPauses(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 2))
+	server.EnqueueStatus(kApiItemEndpoint, http.StatusTooManyRequests)
+
+	client := newTestClient(server)
+	// a single account, which backs off without switching
+	client.Config.Accounts = client.Config.Accounts[:1]
+	client.Config.RetryConfig = RetryConfig{InitialBackoff: Duration{Duration: time.Hour}, MaxBackoff: Duration{Duration: time.Hour}}
+	client.Config.SearchConfig.Watchlist = []string{"1"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sender := &cancelAfterWriter{nbMaxMessages: 1, cancel: cancel}
+	ant := &Ant{
+		client:       client,
+		sender:       sender,
+		storeTracker: NewStoreTracker(nil),
+		watchlist:    NewWatchlist(&client.Config.SearchConfig),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ant.harvest(ctx)
+		done <- struct{}{}
+	}()
+	// the harvest loop holds the client until its search is rate limited, then backs off for an hour
+	for server.NbRequests(kApiItemEndpoint) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	go func() {
+		ant.watch(ctx)
+		done <- struct{}{}
+	}()
+
+	for range 2 {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected the watched store to be notified while the harvest loop backs off")
+		}
+	}
+	if len(sender.messages) != 1 || !strings.HasPrefix(sender.messages[0], "watched store available") {
+		t.Fatalf("expected the watched store notification, got %v", sender.messages)
+	}
+}
+
+func TestWatchlistUpdate(t *testing.T) {
+	watchlist := NewWatchlist(&SearchConfig{Watchlist: []string{"1", "2"}})
+
+	for _, testCase := range []struct {
+		availableBags    []int
+		expectedStoreIds []string
+	}{
+		{[]int{0, 2}, []string{"2"}},
+		{[]int{0, 3}, []string{}},
+		{[]int{1, 0}, []string{"1"}},
+		{[]int{1, 1}, []string{"2"}},
+	} {
+		stores := []Store{
+			{Id: "1", Name: "Bakery", AvailableBags: testCase.availableBags[0]},
+			{Id: "2", Name: "Sushi", AvailableBags: testCase.availableBags[1]},
+		}
+		if availableStoreIds := storeIds(watchlist.Update(stores)); !reflect.DeepEqual(availableStoreIds, testCase.expectedStoreIds) {
+			t.Fatalf("for available bags %v, expected available stores %v, got %v", testCase.availableBags, testCase.expectedStoreIds, availableStoreIds)
+		}
+	}
+}
+
+func TestAntPollWatchlist(t *testing.T) {
+	server := NewFakeTooGoodToGoServer(t)
+	server.SetItems(NewFakeItem("1", "Bakery", 0), NewFakeItem("2", "Sushi", 0), NewFakeItem("3", "Pizza", 4))
+
+	client := newTestClient(server)
+	client.Config.SearchConfig.Watchlist = []string{"1", "2"}
+
+	sender := &cancelAfterWriter{nbMaxMessages: 10, cancel: func() {}}
+	ant := &Ant{
+		client:       client,
+		sender:       sender,
+		storeTracker: NewStoreTracker(nil),
+		watchlist:    NewWatchlist(&client.Config.SearchConfig),
+	}
+	ctx := context.Background()
+
+	ant.pollWatchlist(ctx)
+	if len(sender.messages) != 0 {
+		t.Fatalf("expected no message while watched items are sold out, got %q", sender.messages)
+	}
+
+	server.SetItemsAvailable("2", 1)
+	ant.pollWatchlist(ctx)
+	if len(sender.messages) != 1 || !strings.HasPrefix(sender.messages[0], "watched store available: Sushi") {
+		t.Fatalf("expected watched store available message, got %q", sender.messages)
+	}
+	if nbRequests := server.NbRequests("item/v7/"); nbRequests != 0 {
+		t.Fatalf("expected no search request, got %v", nbRequests)
+	}
+}